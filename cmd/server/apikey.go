@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"hitalent-go-task/internal/auth"
+)
+
+const apiKeyUsage = "usage: apikey create -name NAME [-role viewer|editor|admin] [-scope DEPARTMENT_ID] | apikey list | apikey revoke -name NAME"
+
+// runAPIKey manages API keys. The plain key is printed once on creation and
+// is not recoverable afterwards.
+func runAPIKey(store auth.APIKeyStore, args []string) int {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, apiKeyUsage)
+		return 2
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "create":
+		apiKey, ok := parseAPIKeyCreate(args[1:])
+		if !ok {
+			return 2
+		}
+		key, keyHash, err := auth.GenerateAPIKey()
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "generate api key: %v\n", err)
+			return 1
+		}
+		if _, err := store.Create(ctx, apiKey, keyHash); err != nil {
+			fmt.Fprintf(os.Stderr, "create api key: %v\n", err)
+			return 1
+		}
+		fmt.Println(key)
+		return 0
+
+	case "list":
+		keys, err := store.List(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "list api keys: %v\n", err)
+			return 1
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "NAME\tROLE\tSCOPE\tCREATED\tREVOKED")
+		for _, key := range keys {
+			scope := "*"
+			if key.ScopeDepartmentID != nil {
+				scope = strconv.FormatUint(uint64(*key.ScopeDepartmentID), 10)
+			}
+			revoked := "-"
+			if key.RevokedAt != nil {
+				revoked = key.RevokedAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(writer, "%s\t%s\t%s\t%s\t%s\n", key.Name, key.Role, scope, key.CreatedAt.Format(time.RFC3339), revoked)
+		}
+		_ = writer.Flush()
+		return 0
+
+	case "revoke":
+		flags := flag.NewFlagSet("apikey revoke", flag.ContinueOnError)
+		rawName := flags.String("name", "", "api key name")
+		if err := flags.Parse(args[1:]); err != nil {
+			return 2
+		}
+		name, ok := validateAPIKeyName(*rawName)
+		if !ok {
+			return 2
+		}
+		if err := store.Revoke(ctx, name); err != nil {
+			if errors.Is(err, auth.ErrAPIKeyNotFound) {
+				fmt.Fprintf(os.Stderr, "active api key %q not found\n", name)
+			} else {
+				fmt.Fprintf(os.Stderr, "revoke api key: %v\n", err)
+			}
+			return 1
+		}
+		return 0
+	}
+
+	fmt.Fprintln(os.Stderr, apiKeyUsage)
+	return 2
+}
+
+func parseAPIKeyCreate(args []string) (auth.APIKey, bool) {
+	flags := flag.NewFlagSet("apikey create", flag.ContinueOnError)
+	rawName := flags.String("name", "", "api key name")
+	rawRole := flags.String("role", string(auth.RoleViewer), "viewer, editor or admin")
+	scope := flags.Uint("scope", 0, "limit the key to this department and its subtree")
+	if err := flags.Parse(args); err != nil {
+		return auth.APIKey{}, false
+	}
+
+	name, ok := validateAPIKeyName(*rawName)
+	if !ok {
+		return auth.APIKey{}, false
+	}
+	role, err := auth.ParseRole(*rawRole)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return auth.APIKey{}, false
+	}
+
+	apiKey := auth.APIKey{Name: name, Role: role}
+	if *scope != 0 {
+		scopeID := *scope
+		apiKey.ScopeDepartmentID = &scopeID
+	}
+	return apiKey, true
+}
+
+func validateAPIKeyName(raw string) (string, bool) {
+	name := strings.TrimSpace(raw)
+	if name == "" || len(name) > 100 {
+		fmt.Fprintln(os.Stderr, "-name is required and must be at most 100 characters")
+		return "", false
+	}
+	return name, true
+}
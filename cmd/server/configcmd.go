@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"hitalent-go-task/internal/config"
+)
+
+const configUsage = "usage: config print"
+
+// runConfig shows the effective configuration with secrets redacted.
+func runConfig(cfg config.Config, args []string) int {
+	if len(args) != 1 || args[0] != "print" {
+		fmt.Fprintln(os.Stderr, configUsage)
+		return 2
+	}
+	if err := config.Print(os.Stdout, cfg); err != nil {
+		fmt.Fprintf(os.Stderr, "print config: %v\n", err)
+		return 1
+	}
+	return 0
+}
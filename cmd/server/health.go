@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/pressly/goose/v3"
+	"gorm.io/gorm"
+)
+
+const readinessTimeout = 2 * time.Second
+
+// livez reports that the process is running. It never touches the database,
+// so an outage does not make the orchestrator restart healthy replicas.
+func livez(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+	_, _ = w.Write([]byte("ok"))
+}
+
+// readiness reports whether the replica should receive traffic: the database
+// answers, every embedded migration is applied and shutdown has not begun.
+// The endpoint is unauthenticated, so failures are logged and reported only
+// as "unavailable".
+type readiness struct {
+	database *gorm.DB
+	migrator *goose.Provider
+	logger   *slog.Logger
+	draining atomic.Bool
+}
+
+func (h *readiness) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	checks := map[string]string{"database": "ok", "migrations": "ok"}
+	ready := true
+	fail := func(check string, message string) {
+		checks[check] = message
+		ready = false
+	}
+
+	if h.draining.Load() {
+		fail("shutdown", "draining")
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), readinessTimeout)
+	defer cancel()
+	if err := h.ping(ctx); err != nil {
+		h.logger.WarnContext(ctx, "readiness: database unavailable", "error", err)
+		fail("database", "unavailable")
+		fail("migrations", "unknown")
+	} else if current, target, err := h.migrator.GetVersions(ctx); err != nil {
+		h.logger.WarnContext(ctx, "readiness: migration status unavailable", "error", err)
+		fail("migrations", "unavailable")
+	} else if current < target {
+		h.logger.WarnContext(ctx, "readiness: migrations pending", "version", current, "expected", target)
+		fail("migrations", "pending")
+	}
+
+	status, statusCode := "ok", http.StatusOK
+	if !ready {
+		status, statusCode = "unavailable", http.StatusServiceUnavailable
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(statusCode)
+	_ = json.NewEncoder(w).Encode(map[string]any{"status": status, "checks": checks})
+}
+
+func (h *readiness) ping(ctx context.Context) error {
+	sqlDB, err := h.database.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
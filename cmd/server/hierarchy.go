@@ -0,0 +1,44 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"log/slog"
+
+	"hitalent-go-task/internal/repository/gormrepo"
+)
+
+// runCheckHierarchy compares the closure table with parent_id links and
+// optionally rebuilds it. It returns the process exit code.
+func runCheckHierarchy(store *gormrepo.Store, logger *slog.Logger, args []string) int {
+	flags := flag.NewFlagSet("check-hierarchy", flag.ContinueOnError)
+	repair := flags.Bool("repair", false, "rebuild the closure table when inconsistencies are found")
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	ctx := context.Background()
+	report, err := store.CheckHierarchy(ctx)
+	if err != nil {
+		logger.Error("hierarchy check failed", "error", err)
+		return 1
+	}
+
+	logger.Info("hierarchy checked",
+		"departments", report.Departments, "missing_closure_rows", report.Missing, "stale_closure_rows", report.Stale)
+	if report.Consistent() {
+		logger.Info("hierarchy is consistent")
+		return 0
+	}
+	if !*repair {
+		logger.Warn("hierarchy is inconsistent, run with -repair to rebuild the closure table")
+		return 1
+	}
+
+	if err := store.RebuildHierarchy(ctx); err != nil {
+		logger.Error("hierarchy repair failed", "error", err)
+		return 1
+	}
+	logger.Info("closure table rebuilt")
+	return 0
+}
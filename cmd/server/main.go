@@ -1,65 +1,437 @@
 package main
 
 import (
+	"context"
+	"crypto/rand"
+	"database/sql"
+	"encoding/hex"
 	"errors"
-	"log"
+	"flag"
+	"fmt"
+	"log/slog"
 	"net/http"
 	"os"
+	"os/signal"
+	"regexp"
+	"sync"
+	"syscall"
 	"time"
 
+	"hitalent-go-task/internal/auth"
+	"hitalent-go-task/internal/cache"
 	"hitalent-go-task/internal/config"
 	"hitalent-go-task/internal/db"
 	"hitalent-go-task/internal/httpapi"
+	"hitalent-go-task/internal/idempotency"
+	"hitalent-go-task/internal/logging"
+	"hitalent-go-task/internal/metrics"
+	"hitalent-go-task/internal/outbox"
+	"hitalent-go-task/internal/ratelimit"
+	"hitalent-go-task/internal/repository"
+	"hitalent-go-task/internal/repository/gormrepo"
 	"hitalent-go-task/internal/service"
+	"hitalent-go-task/internal/tracing"
+	"hitalent-go-task/internal/webhook"
 )
 
-func loggingMiddleware(logger *log.Logger, next http.Handler) http.Handler {
+const requestIDHeader = "X-Request-ID"
+
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// requestIDMiddleware keeps a well-formed X-Request-ID from the client or
+// generates one, echoes it in the response and stores it in the context so
+// that every log line of the request carries it.
+func requestIDMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get(requestIDHeader)
+		if !validRequestID.MatchString(requestID) {
+			requestID = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, requestID)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), requestID)))
+	})
+}
+
+// sessionMiddleware makes every request a read-your-writes session: after it
+// commits a change, its remaining reads skip the replica.
+func sessionMiddleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		next.ServeHTTP(w, r.WithContext(repository.WithSession(r.Context())))
+	})
+}
+
+func newRequestID() string {
+	id := make([]byte, 16)
+	_, _ = rand.Read(id)
+	return hex.EncodeToString(id)
+}
+
+func loggingMiddleware(logger *slog.Logger, next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
-		next.ServeHTTP(w, r)
-		logger.Printf("%s %s %s", r.Method, r.URL.RequestURI(), time.Since(start))
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+		next.ServeHTTP(recorder, r)
+		logger.InfoContext(r.Context(), "request",
+			"method", r.Method,
+			"uri", r.URL.RequestURI(),
+			"status", recorder.status,
+			"bytes", recorder.bytes,
+			"duration", time.Since(start),
+		)
 	})
 }
 
-func healthcheck(w http.ResponseWriter, r *http.Request) {
-	w.WriteHeader(http.StatusOK)
-	_, _ = w.Write([]byte("ok"))
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (w *statusRecorder) WriteHeader(status int) {
+	w.status = status
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *statusRecorder) Write(data []byte) (int, error) {
+	n, err := w.ResponseWriter.Write(data)
+	w.bytes += n
+	return n, err
+}
+
+func (w *statusRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+func purgeExpiredIdempotencyKeys(ctx context.Context, store idempotency.Store, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := store.DeleteExpired(ctx, time.Now()); err != nil {
+				logger.Error("idempotency cleanup failed", "error", err)
+			}
+		}
+	}
+}
+
+func fatal(logger *slog.Logger, msg string, args ...any) {
+	logger.Error(msg, args...)
+	os.Exit(1)
 }
 
 func main() {
+	// -- Configs preload --
+	cfg, args, err := config.Load(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		os.Exit(0)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid configuration:\n%v\n", err)
+		os.Exit(2)
+	}
+	if len(args) > 0 && args[0] == "config" {
+		os.Exit(runConfig(cfg, args[1:]))
+	}
+
 	// -- Logger --
-	logger := log.New(os.Stdout, "", log.LstdFlags)
+	level, err := logging.ParseLevel(cfg.LogLevel)
+	if err != nil {
+		fatal(slog.Default(), "logger configuration error", "error", err)
+	}
+	logLevel := new(slog.LevelVar)
+	logLevel.Set(level)
+	logger, err := logging.New(os.Stderr, logLevel, cfg.LogFormat)
+	if err != nil {
+		fatal(slog.Default(), "logger configuration error", "error", err)
+	}
+	slog.SetDefault(logger)
 
-	// -- Configs preload --
-	cfg, err := config.Load()
+	// -- Tracing --
+	shutdownTracing, err := tracing.Setup(context.Background(), tracing.Config{
+		Exporter:     cfg.Tracing.Exporter,
+		ServiceName:  cfg.Tracing.ServiceName,
+		OTLPEndpoint: cfg.Tracing.OTLPEndpoint,
+		File:         cfg.Tracing.File,
+		SampleRatio:  cfg.Tracing.SampleRatio,
+	})
 	if err != nil {
-		logger.Fatalf("config error: %v", err)
+		fatal(logger, "tracing configuration error", "error", err)
 	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		if err := shutdownTracing(ctx); err != nil {
+			logger.Error("tracing shutdown failed", "error", err)
+		}
+	}()
 
 	// -- Connect to DB --
-	database, err := db.Connect(cfg)
+	database, err := db.Connect(cfg, logger)
+	if err != nil {
+		fatal(logger, "database connection error", "error", err)
+	}
+
+	store := gormrepo.New(database)
+
+	// Migrations and hierarchy rebuilds run without the statement timeout.
+	maintenanceDB, err := db.ConnectMaintenance(database, cfg, logger)
 	if err != nil {
-		logger.Fatalf("database connection error: %v", err)
+		fatal(logger, "database connection error", "error", err)
+	}
+	migrator, err := db.NewMigrator(maintenanceDB, cfg.DatabaseDriver)
+	if err != nil {
+		fatal(logger, "migrations error", "error", err)
+	}
+
+	// -- Subcommands --
+	if len(args) > 0 {
+		switch args[0] {
+		case "migrate":
+			os.Exit(runMigrate(migrator, logger, args[1:]))
+		case "check-hierarchy":
+			os.Exit(runCheckHierarchy(gormrepo.New(maintenanceDB), logger, args[1:]))
+		case "apikey":
+			os.Exit(runAPIKey(auth.NewGormAPIKeyStore(database), args[1:]))
+		default:
+			fatal(logger, "unknown command, available: config, migrate, check-hierarchy, apikey", "command", args[0])
+		}
+	}
+
+	if cfg.AutoMigrate {
+		if err := migrateUp(context.Background(), migrator, logger); err != nil {
+			fatal(logger, "migration failed", "error", err)
+		}
+	}
+
+	// -- Read replica --
+	var replicaDB *sql.DB
+	if cfg.Database.ReplicaURL != "" {
+		replicaDB, err = db.UseReplica(database, cfg)
+		if err != nil {
+			fatal(logger, "read replica error", "error", err)
+		}
+		logger.Info("reading from replica", "read_your_writes", cfg.Database.ReadYourWrites)
+	}
+
+	serviceOptions := []service.Option{
+		service.WithMaxDepth(int(cfg.TreeMaxDepth)),
+		service.WithMaxFieldLength(cfg.MaxFieldLength),
+	}
+	if cfg.EmployeeFieldPolicy != "" {
+		policy, err := service.ParseEmployeeFieldPolicy(cfg.EmployeeFieldPolicy)
+		if err != nil {
+			fatal(logger, "employee_field_policy error", "error", err)
+		}
+		serviceOptions = append(serviceOptions, service.WithEmployeeFieldPolicy(policy))
+	}
+	var treeCache *cache.Instrumented
+	if cfg.Cache.TreeEntries > 0 {
+		treeCache = cache.Instrument(cache.NewLRU(cfg.Cache.TreeEntries))
+		serviceOptions = append(serviceOptions, service.WithTreeCache(treeCache, cfg.Cache.TreeTTL))
+	}
+	departmentService := service.NewDepartmentService(store, serviceOptions...)
+	idempotencyStore := idempotency.NewGormStore(database)
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+	go purgeExpiredIdempotencyKeys(backgroundCtx, idempotencyStore, time.Hour, logger)
+	go purgeOutboxEvents(backgroundCtx, database, cfg.Outbox, time.Hour, logger)
+	go purgeWebhookDeliveries(backgroundCtx, database, cfg.Webhooks, time.Hour, logger)
+
+	// -- Outbox relay and webhooks --
+	// The relay always runs: it queues events for the webhook subscriptions
+	// and additionally publishes them to the configured sink.
+	sinks := outbox.MultiSink{webhook.NewFanout(database)}
+	closeSink := func() error { return nil }
+	if cfg.Outbox.Sink != "none" {
+		var sink outbox.Sink
+		sink, closeSink, err = newOutboxSink(cfg.Outbox)
+		if err != nil {
+			fatal(logger, "outbox sink error", "error", err)
+		}
+		sinks = append(sinks, sink)
+		logger.Info("publishing change events", "sink", cfg.Outbox.Sink)
+	}
+	relay := outbox.NewRelay(database, sinks, logger,
+		outbox.WithBatchSize(cfg.Outbox.BatchSize),
+		outbox.WithInterval(cfg.Outbox.Interval),
+		outbox.WithMaxBackoff(cfg.Outbox.MaxBackoff),
+	)
+	dispatcher := webhook.NewDispatcher(database, webhook.NewClient(cfg.Webhooks.Timeout, cfg.Webhooks.AllowPrivateNetworks), logger,
+		webhook.WithBatchSize(cfg.Outbox.BatchSize),
+		webhook.WithInterval(cfg.Outbox.Interval),
+		webhook.WithMaxAttempts(cfg.Webhooks.MaxAttempts),
+		webhook.WithMaxBackoff(cfg.Webhooks.MaxBackoff),
+	)
+	feed := outbox.NewFeed(database, logger)
+	var delivery sync.WaitGroup
+	delivery.Add(3)
+	go func() {
+		defer delivery.Done()
+		relay.Run(backgroundCtx)
+	}()
+	go func() {
+		defer delivery.Done()
+		dispatcher.Run(backgroundCtx)
+	}()
+	go func() {
+		defer delivery.Done()
+		feed.Run(backgroundCtx)
+	}()
+
+	// -- Rate limiting --
+	// Applied by the handler after authentication; probes and /metrics are
+	// not limited.
+	readLimiter := ratelimit.NewLimiter(cfg.RateLimit.ReadRate, cfg.RateLimit.ReadBurst)
+	writeLimiter := ratelimit.NewLimiter(cfg.RateLimit.WriteRate, cfg.RateLimit.WriteBurst)
+
+	events, changes := service.NewTracedEventService(service.NewEventService(departmentService, feed))
+	directory, exporter := service.NewTracedDirectory(service.NewDirectoryService(departmentService))
+	webhooks := service.NewTracedWebhookManager(service.NewWebhookService(store, service.WithPrivateWebhookTargets(cfg.Webhooks.AllowPrivateNetworks)))
+	handlerOptions := []httpapi.Option{
+		httpapi.WithRateLimit(readLimiter, writeLimiter),
+		httpapi.WithTrustedProxies(cfg.RateLimit.TrustedProxies),
+		httpapi.WithIdempotency(idempotencyStore, cfg.IdempotencyTTL, cfg.IdempotencyLease),
+		httpapi.WithWebhooks(webhooks),
+		httpapi.WithEvents(events),
+		httpapi.WithChanges(changes),
+		httpapi.WithSCIM(directory),
+		httpapi.WithLDIFExport(exporter, cfg.Directory.BaseDN),
 	}
 
-	departmentService := service.NewDepartmentService(database)
-	handler := httpapi.NewHandler(departmentService, logger)
+	// -- Authentication --
+	if cfg.Auth.Disabled {
+		logger.Warn("authentication is disabled")
+	} else {
+		authenticators := auth.Chain{auth.NewAPIKeyAuthenticator(auth.NewGormAPIKeyStore(database))}
+		if cfg.Auth.JWTEnabled() {
+			jwtAuthenticator, err := auth.NewJWTAuthenticator(auth.JWTConfig{
+				HMACSecret: []byte(cfg.Auth.JWTHMACSecret),
+				JWKSFile:   cfg.Auth.JWKSFile,
+				Issuer:     cfg.Auth.JWTIssuer,
+				Audience:   cfg.Auth.JWTAudience,
+			})
+			if err != nil {
+				fatal(logger, "jwt configuration error", "error", err)
+			}
+			authenticators = append(authenticators, jwtAuthenticator)
+		}
+		handlerOptions = append(handlerOptions, httpapi.WithAuthenticator(authenticators))
+	}
+
+	handler := httpapi.NewHandler(service.NewTracedManager(departmentService), logger, handlerOptions...)
+
+	// -- Metrics --
+	appMetrics := metrics.New()
+	sqlDB, err := database.DB()
+	if err != nil {
+		fatal(logger, "database handle error", "error", err)
+	}
+	appMetrics.RegisterDB(sqlDB, cfg.DatabaseDriver)
+	if replicaDB != nil {
+		appMetrics.RegisterDB(replicaDB, "replica")
+	}
+	appMetrics.RegisterCollector(metrics.NewDomainCollector(store.Counts))
+	if treeCache != nil {
+		appMetrics.RegisterCollector(metrics.NewCacheCollector("department_tree", treeCache.Stats))
+	}
 
 	// -- Router --
 	mux := http.NewServeMux()
 	mux.Handle("/departments", handler)
 	mux.Handle("/departments/", handler)
-	mux.HandleFunc("/healthcheck", healthcheck)
+	mux.Handle("/webhooks", handler)
+	mux.Handle("/webhooks/", handler)
+	mux.Handle("/events/stream", handler)
+	mux.Handle("/changes", handler)
+	mux.Handle("/scim/v2/", handler)
+	mux.Handle("/directory/ldif", handler)
+	ready := &readiness{database: database, migrator: migrator, logger: logger}
+	mux.Handle("/livez", metrics.Route("/livez", http.HandlerFunc(livez)))
+	mux.Handle("/readyz", metrics.Route("/readyz", ready))
+	// Kept for existing probes; same as /livez.
+	mux.Handle("/healthcheck", metrics.Route("/healthcheck", http.HandlerFunc(livez)))
+	mux.Handle("/metrics", metrics.Route("/metrics", appMetrics.Handler()))
+
+	// -- Config reload --
+	go reloadOnSIGHUP(backgroundCtx, cfg, reloadable{
+		logLevel:     logLevel,
+		readLimiter:  readLimiter,
+		writeLimiter: writeLimiter,
+	}, logger)
+
+	var routes http.Handler = mux
+	if replicaDB != nil && cfg.Database.ReadYourWrites {
+		routes = sessionMiddleware(routes)
+	}
 
 	server := &http.Server{
 		Addr:              ":" + cfg.Port,
-		Handler:           loggingMiddleware(logger, mux),
-		ReadHeaderTimeout: 5 * time.Second,
+		Handler:           tracing.Middleware(requestIDMiddleware(appMetrics.Middleware(loggingMiddleware(logger, routes)))),
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout,
+		WriteTimeout:      cfg.WriteTimeout,
 	}
 
 	// -- Startup --
-	logger.Printf("starting server, listening to port %s...", cfg.Port)
-	if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
-		logger.Fatalf("server failed: %v", err)
+	serverErr := make(chan error, 1)
+	go func() {
+		logger.Info("starting server", "port", cfg.Port)
+		serverErr <- server.ListenAndServe()
+	}()
+
+	signals, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stopSignals()
+
+	select {
+	case err := <-serverErr:
+		if !errors.Is(err, http.ErrServerClosed) {
+			fatal(logger, "server failed", "error", err)
+		}
+		return
+	case <-signals.Done():
+	}
+
+	// -- Shutdown --
+	// A second signal kills the process immediately.
+	stopSignals()
+	logger.Info("shutting down", "delay", cfg.ShutdownDelay, "timeout", cfg.ShutdownTimeout)
+	ready.draining.Store(true)
+	// Keep serving while /readyz fails, until load balancers have taken the
+	// instance out of rotation; closing the listener first would refuse their
+	// new connections instead.
+	time.Sleep(cfg.ShutdownDelay)
+	stopBackground()
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout)
+	defer cancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
+		// Cancelling the remaining requests rolls back their transactions.
+		logger.Warn("drain timeout exceeded, closing remaining connections", "error", err)
+		_ = server.Close()
+	}
+	// The relay and the dispatcher were cancelled with the background work;
+	// events and deliveries they did not get to stay pending for the next start.
+	// Cancelling the feed also ended the event streams, so they do not hold up
+	// the drain.
+	delivery.Wait()
+	if err := closeSink(); err != nil {
+		logger.Error("outbox sink close failed", "error", err)
+	}
+	if err := sqlDB.Close(); err != nil {
+		logger.Error("database close failed", "error", err)
+	}
+	if maintenanceDB != database {
+		if maintenanceSQLDB, err := maintenanceDB.DB(); err == nil {
+			_ = maintenanceSQLDB.Close()
+		}
+	}
+	if replicaDB != nil {
+		if err := replicaDB.Close(); err != nil {
+			logger.Error("replica close failed", "error", err)
+		}
 	}
+	logger.Info("server stopped")
 }
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"text/tabwriter"
+
+	"github.com/pressly/goose/v3"
+)
+
+const migrateUsage = "usage: migrate up | down | status | redo"
+
+// runMigrate applies or rolls back the migrations embedded in the binary.
+func runMigrate(migrator *goose.Provider, logger *slog.Logger, args []string) int {
+	if len(args) != 1 {
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+
+	ctx := context.Background()
+	switch args[0] {
+	case "up":
+		if err := migrateUp(ctx, migrator, logger); err != nil {
+			logger.Error("migration failed", "error", err)
+			return 1
+		}
+		return 0
+
+	case "down":
+		result, err := migrator.Down(ctx)
+		if err != nil {
+			logger.Error("rollback failed", "error", err)
+			return 1
+		}
+		logMigration(logger, result)
+		return 0
+
+	case "redo":
+		// Roll back and re-apply the latest migration.
+		result, err := migrator.Down(ctx)
+		if err != nil {
+			logger.Error("rollback failed", "error", err)
+			return 1
+		}
+		logMigration(logger, result)
+		result, err = migrator.UpByOne(ctx)
+		if err != nil {
+			logger.Error("migration failed", "error", err)
+			return 1
+		}
+		logMigration(logger, result)
+		return 0
+
+	case "status":
+		statuses, err := migrator.Status(ctx)
+		if err != nil {
+			logger.Error("migration status failed", "error", err)
+			return 1
+		}
+		writer := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(writer, "VERSION\tSTATE\tAPPLIED\tFILE")
+		for _, status := range statuses {
+			applied := "-"
+			if status.State == goose.StateApplied {
+				applied = status.AppliedAt.UTC().Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(writer, "%d\t%s\t%s\t%s\n", status.Source.Version, status.State, applied, status.Source.Path)
+		}
+		_ = writer.Flush()
+		return 0
+
+	default:
+		fmt.Fprintln(os.Stderr, migrateUsage)
+		return 2
+	}
+}
+
+// migrateUp applies all pending migrations; it is also used on start when
+// AUTO_MIGRATE is enabled.
+func migrateUp(ctx context.Context, migrator *goose.Provider, logger *slog.Logger) error {
+	results, err := migrator.Up(ctx)
+	var partial *goose.PartialError
+	if errors.As(err, &partial) {
+		results = partial.Applied
+	}
+	for _, result := range results {
+		logMigration(logger, result)
+	}
+	if err != nil {
+		return err
+	}
+	version, err := migrator.GetDBVersion(ctx)
+	if err != nil {
+		return err
+	}
+	logger.Info("database is up to date", "version", version)
+	return nil
+}
+
+func logMigration(logger *slog.Logger, result *goose.MigrationResult) {
+	logger.Info("migration finished",
+		"version", result.Source.Version,
+		"direction", result.Direction,
+		"file", result.Source.Path,
+		"duration", result.Duration,
+	)
+}
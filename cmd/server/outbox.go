@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/config"
+	"hitalent-go-task/internal/outbox"
+	"hitalent-go-task/internal/webhook"
+)
+
+// newOutboxSink builds the configured sink and the function that releases it
+// once the relay has stopped.
+func newOutboxSink(cfg config.OutboxConfig) (outbox.Sink, func() error, error) {
+	switch cfg.Sink {
+	case "file":
+		fileSink, err := outbox.NewFileSink(cfg.File)
+		if err != nil {
+			return nil, nil, err
+		}
+		return fileSink, fileSink.Close, nil
+	case "webhook":
+		client := &http.Client{Timeout: 10 * time.Second}
+		return outbox.NewWebhookSink(cfg.WebhookURL, client), func() error { return nil }, nil
+	default:
+		return nil, nil, fmt.Errorf("unsupported outbox sink %q", cfg.Sink)
+	}
+}
+
+func purgeOutboxEvents(ctx context.Context, database *gorm.DB, cfg config.OutboxConfig, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			before := time.Now().Add(-cfg.Retention)
+			if _, err := outbox.Purge(ctx, database, before, false); err != nil {
+				logger.Error("outbox cleanup failed", "error", err)
+			}
+		}
+	}
+}
+
+func purgeWebhookDeliveries(ctx context.Context, database *gorm.DB, cfg config.WebhooksConfig, interval time.Duration, logger *slog.Logger) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if _, err := webhook.Purge(ctx, database, time.Now().Add(-cfg.Retention)); err != nil {
+				logger.Error("webhook delivery cleanup failed", "error", err)
+			}
+		}
+	}
+}
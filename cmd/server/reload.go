@@ -0,0 +1,66 @@
+package main
+
+import (
+	"context"
+	"log/slog"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"hitalent-go-task/internal/config"
+	"hitalent-go-task/internal/logging"
+	"hitalent-go-task/internal/ratelimit"
+)
+
+// reloadable holds the parts of the running server that accept new settings
+// without a restart.
+type reloadable struct {
+	logLevel     *slog.LevelVar
+	readLimiter  *ratelimit.Limiter
+	writeLimiter *ratelimit.Limiter
+}
+
+func (r reloadable) apply(cfg config.Config) {
+	if level, err := logging.ParseLevel(cfg.LogLevel); err == nil {
+		r.logLevel.Set(level)
+	}
+	r.readLimiter.SetLimit(cfg.RateLimit.ReadRate, cfg.RateLimit.ReadBurst)
+	r.writeLimiter.SetLimit(cfg.RateLimit.WriteRate, cfg.RateLimit.WriteBurst)
+}
+
+// reloadOnSIGHUP re-reads the configuration file and environment on SIGHUP.
+// An invalid configuration is rejected as a whole. Changes are compared with
+// the startup configuration, so settings that need a restart are reported on
+// every reload until the server is restarted.
+func reloadOnSIGHUP(ctx context.Context, cfg config.Config, target reloadable, logger *slog.Logger) {
+	hangups := make(chan os.Signal, 1)
+	signal.Notify(hangups, syscall.SIGHUP)
+	defer signal.Stop(hangups)
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hangups:
+		}
+
+		next, err := config.Reload(cfg)
+		if err != nil {
+			logger.Error("config reload rejected", "error", err)
+			continue
+		}
+		var applied, ignored []string
+		for _, key := range config.Changed(cfg, next) {
+			if config.Reloadable(key) {
+				applied = append(applied, key)
+			} else {
+				ignored = append(ignored, key)
+			}
+		}
+		if len(ignored) > 0 {
+			logger.Warn("config changes need a restart", "keys", ignored)
+		}
+		target.apply(next)
+		logger.Info("config reloaded", "applied", applied)
+	}
+}
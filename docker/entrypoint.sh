@@ -6,8 +6,7 @@ until pg_isready -d "$DATABASE_URL" >/dev/null 2>&1; do
   sleep 1
 done
 
-echo "[STAGE 2] applying migrations..."
-goose -dir /app/migrations postgres "$DATABASE_URL" up
-
-echo "[STAGE 1] starting API server..."
-exec /app/bin/server
+# Migrations are embedded in the binary and applied on start when
+# AUTO_MIGRATE=true; arguments run a subcommand instead, e.g. "migrate status".
+echo "[STAGE 2] starting API server..."
+exec /app/bin/server "$@"
@@ -9,6 +9,10 @@ const (
 	CodeNotFound   Code = "not_found"
 	CodeConflict   Code = "conflict"
 	CodeInternal   Code = "internal"
+
+	CodePreconditionFailed Code = "precondition_failed"
+	CodeForbidden          Code = "forbidden"
+	CodeGone               Code = "gone"
 )
 
 type Error struct {
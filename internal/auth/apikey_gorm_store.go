@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/models"
+)
+
+type GormAPIKeyStore struct {
+	db *gorm.DB
+}
+
+func NewGormAPIKeyStore(db *gorm.DB) *GormAPIKeyStore {
+	return &GormAPIKeyStore{db: db}
+}
+
+func (s *GormAPIKeyStore) Create(ctx context.Context, key APIKey, keyHash string) (APIKey, error) {
+	var count int64
+	if err := s.db.WithContext(ctx).Model(&models.APIKey{}).Where("name = ?", key.Name).Count(&count).Error; err != nil {
+		return APIKey{}, fmt.Errorf("check api key name: %w", err)
+	}
+	if count > 0 {
+		return APIKey{}, ErrAPIKeyExists
+	}
+
+	row := models.APIKey{
+		Name:              key.Name,
+		KeyHash:           keyHash,
+		Role:              string(key.Role),
+		ScopeDepartmentID: key.ScopeDepartmentID,
+	}
+	if err := s.db.WithContext(ctx).Create(&row).Error; err != nil {
+		return APIKey{}, fmt.Errorf("create api key: %w", err)
+	}
+	return apiKeyFromRow(row), nil
+}
+
+func (s *GormAPIKeyStore) FindActive(ctx context.Context, keyHash string) (APIKey, error) {
+	var row models.APIKey
+	err := s.db.WithContext(ctx).Where("key_hash = ? AND revoked_at IS NULL", keyHash).First(&row).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return APIKey{}, fmt.Errorf("load api key: %w", err)
+	}
+	return apiKeyFromRow(row), nil
+}
+
+func (s *GormAPIKeyStore) List(ctx context.Context) ([]APIKey, error) {
+	var rows []models.APIKey
+	if err := s.db.WithContext(ctx).Order("name ASC").Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("list api keys: %w", err)
+	}
+
+	keys := make([]APIKey, 0, len(rows))
+	for _, row := range rows {
+		keys = append(keys, apiKeyFromRow(row))
+	}
+	return keys, nil
+}
+
+func (s *GormAPIKeyStore) Revoke(ctx context.Context, name string) error {
+	result := s.db.WithContext(ctx).
+		Model(&models.APIKey{}).
+		Where("name = ? AND revoked_at IS NULL", name).
+		Update("revoked_at", time.Now().UTC())
+	if result.Error != nil {
+		return fmt.Errorf("revoke api key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAPIKeyNotFound
+	}
+	return nil
+}
+
+func apiKeyFromRow(row models.APIKey) APIKey {
+	return APIKey{
+		Name:              row.Name,
+		Role:              Role(row.Role),
+		ScopeDepartmentID: row.ScopeDepartmentID,
+		CreatedAt:         row.CreatedAt,
+		RevokedAt:         row.RevokedAt,
+	}
+}
@@ -0,0 +1,74 @@
+package auth
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+)
+
+type MemoryAPIKeyStore struct {
+	mu     sync.Mutex
+	hashes map[string]string
+	keys   map[string]APIKey
+}
+
+func NewMemoryAPIKeyStore() *MemoryAPIKeyStore {
+	return &MemoryAPIKeyStore{
+		hashes: make(map[string]string),
+		keys:   make(map[string]APIKey),
+	}
+}
+
+func (s *MemoryAPIKeyStore) Create(ctx context.Context, key APIKey, keyHash string) (APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.keys[key.Name]; ok {
+		return APIKey{}, ErrAPIKeyExists
+	}
+	key.CreatedAt = time.Now()
+	key.RevokedAt = nil
+	s.keys[key.Name] = key
+	s.hashes[keyHash] = key.Name
+	return key, nil
+}
+
+func (s *MemoryAPIKeyStore) FindActive(ctx context.Context, keyHash string) (APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	name, ok := s.hashes[keyHash]
+	if !ok || s.keys[name].RevokedAt != nil {
+		return APIKey{}, ErrAPIKeyNotFound
+	}
+	return s.keys[name], nil
+}
+
+func (s *MemoryAPIKeyStore) List(ctx context.Context) ([]APIKey, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	keys := make([]APIKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].Name < keys[j].Name
+	})
+	return keys, nil
+}
+
+func (s *MemoryAPIKeyStore) Revoke(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	key, ok := s.keys[name]
+	if !ok || key.RevokedAt != nil {
+		return ErrAPIKeyNotFound
+	}
+	now := time.Now()
+	key.RevokedAt = &now
+	s.keys[name] = key
+	return nil
+}
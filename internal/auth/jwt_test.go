@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"fmt"
+	"math/big"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestJWTAuthenticatorWithJWKS(t *testing.T) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatalf("generate key: %v", err)
+	}
+	encode := func(value *big.Int) string {
+		return base64.RawURLEncoding.EncodeToString(value.Bytes())
+	}
+	jwks := fmt.Sprintf(`{"keys":[{"kty":"RSA","kid":"main","use":"sig","n":%q,"e":%q}]}`,
+		encode(key.N), encode(big.NewInt(int64(key.E))))
+	path := filepath.Join(t.TempDir(), "jwks.json")
+	if err := os.WriteFile(path, []byte(jwks), 0o600); err != nil {
+		t.Fatalf("write JWKS: %v", err)
+	}
+
+	authenticator, err := NewJWTAuthenticator(JWTConfig{JWKSFile: path, Issuer: "https://idp.example", Audience: "departments"})
+	if err != nil {
+		t.Fatalf("jwt authenticator: %v", err)
+	}
+
+	sign := func(kid string, claims jwt.MapClaims) string {
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, claims)
+		token.Header["kid"] = kid
+		signed, err := token.SignedString(key)
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		return signed
+	}
+	claims := jwt.MapClaims{
+		"sub":           "alice",
+		"role":          "editor",
+		"department_id": 7,
+		"iss":           "https://idp.example",
+		"aud":           "departments",
+		"exp":           time.Now().Add(time.Hour).Unix(),
+	}
+
+	authenticate := func(token string) (Principal, error) {
+		req := httptest.NewRequest("GET", "/departments/1", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		return authenticator.Authenticate(req)
+	}
+
+	principal, err := authenticate(sign("main", claims))
+	if err != nil {
+		t.Fatalf("authenticate: %v", err)
+	}
+	if principal.Subject != "alice" || principal.Method != MethodJWT || principal.Role != RoleEditor ||
+		principal.ScopeDepartmentID == nil || *principal.ScopeDepartmentID != 7 {
+		t.Fatalf("unexpected principal %+v", principal)
+	}
+
+	if _, err := authenticate(sign("rotated", claims)); err == nil {
+		t.Fatalf("expected unknown key id to be rejected")
+	}
+
+	claims["role"] = "owner"
+	if _, err := authenticate(sign("main", claims)); err == nil {
+		t.Fatalf("expected unknown role to be rejected")
+	}
+
+	claims["role"] = "editor"
+	claims["aud"] = "other"
+	if _, err := authenticate(sign("main", claims)); err == nil {
+		t.Fatalf("expected wrong audience to be rejected")
+	}
+}
@@ -0,0 +1,93 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+type Method string
+
+const (
+	MethodAPIKey Method = "api_key"
+	MethodJWT    Method = "jwt"
+)
+
+var (
+	// ErrNoCredentials means the request carries no credentials for this
+	// authenticator, so the next one may be tried.
+	ErrNoCredentials      = errors.New("authentication required")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+type Role string
+
+const (
+	RoleViewer Role = "viewer"
+	RoleEditor Role = "editor"
+	RoleAdmin  Role = "admin"
+)
+
+var roleRanks = map[Role]int{
+	RoleViewer: 1,
+	RoleEditor: 2,
+	RoleAdmin:  3,
+}
+
+func ParseRole(raw string) (Role, error) {
+	role := Role(raw)
+	if _, ok := roleRanks[role]; !ok {
+		return "", fmt.Errorf("unknown role %q (expected viewer, editor or admin)", raw)
+	}
+	return role, nil
+}
+
+// Includes reports whether r grants at least the rights of required.
+func (r Role) Includes(required Role) bool {
+	return roleRanks[r] >= roleRanks[required]
+}
+
+// Principal is the authenticated caller.
+type Principal struct {
+	Subject string
+	Method  Method
+	Role    Role
+	// ScopeDepartmentID limits access to this department and its subtree;
+	// nil means the whole tree.
+	ScopeDepartmentID *uint
+}
+
+// String identifies the principal in audit records, e.g. "api_key:importer".
+func (p Principal) String() string {
+	return string(p.Method) + ":" + p.Subject
+}
+
+type Authenticator interface {
+	Authenticate(r *http.Request) (Principal, error)
+}
+
+// Chain tries authenticators in order until one finds credentials in the request.
+type Chain []Authenticator
+
+func (c Chain) Authenticate(r *http.Request) (Principal, error) {
+	for _, authenticator := range c {
+		principal, err := authenticator.Authenticate(r)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return principal, err
+	}
+	return Principal{}, ErrNoCredentials
+}
+
+type principalContextKey struct{}
+
+func WithPrincipal(ctx context.Context, principal Principal) context.Context {
+	return context.WithValue(ctx, principalContextKey{}, principal)
+}
+
+func PrincipalFromContext(ctx context.Context) (Principal, bool) {
+	principal, ok := ctx.Value(principalContextKey{}).(Principal)
+	return principal, ok
+}
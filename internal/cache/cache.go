@@ -0,0 +1,47 @@
+// Package cache provides byte caches for computed responses.
+package cache
+
+import (
+	"context"
+	"sync/atomic"
+	"time"
+)
+
+// Cache stores opaque values by key. The methods mirror the Redis GET,
+// SET with EX and DEL commands, so a shared Redis can replace the
+// in-process LRU without changes to the callers.
+type Cache interface {
+	// Get returns the value stored under key and whether it was found.
+	Get(ctx context.Context, key string) ([]byte, bool, error)
+	// Set stores value under key. A non-positive ttl keeps it until evicted.
+	Set(ctx context.Context, key string, value []byte, ttl time.Duration) error
+	// Delete removes the keys; missing keys are ignored.
+	Delete(ctx context.Context, keys ...string) error
+}
+
+// Instrumented counts hits and misses of the wrapped cache. Errors count as
+// misses.
+type Instrumented struct {
+	Cache
+	hits   atomic.Uint64
+	misses atomic.Uint64
+}
+
+func Instrument(c Cache) *Instrumented {
+	return &Instrumented{Cache: c}
+}
+
+func (c *Instrumented) Get(ctx context.Context, key string) ([]byte, bool, error) {
+	value, found, err := c.Cache.Get(ctx, key)
+	if found && err == nil {
+		c.hits.Add(1)
+	} else {
+		c.misses.Add(1)
+	}
+	return value, found, err
+}
+
+// Stats returns the number of hits and misses since the cache was created.
+func (c *Instrumented) Stats() (hits uint64, misses uint64) {
+	return c.hits.Load(), c.misses.Load()
+}
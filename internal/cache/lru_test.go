@@ -0,0 +1,56 @@
+package cache
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	c := NewLRU(2)
+	_ = c.Set(ctx, "a", []byte("1"), 0)
+	_ = c.Set(ctx, "b", []byte("2"), 0)
+	if _, found, _ := c.Get(ctx, "a"); !found {
+		t.Fatalf("a must be cached")
+	}
+	_ = c.Set(ctx, "c", []byte("3"), 0)
+
+	if _, found, _ := c.Get(ctx, "b"); found {
+		t.Fatalf("b was least recently used and must be evicted")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, found, _ := c.Get(ctx, key); !found {
+			t.Fatalf("%s must be cached", key)
+		}
+	}
+
+	_ = c.Delete(ctx, "a", "missing")
+	if _, found, _ := c.Get(ctx, "a"); found || c.Len() != 1 {
+		t.Fatalf("a must be deleted, %d entries left", c.Len())
+	}
+}
+
+func TestLRUExpiresEntries(t *testing.T) {
+	ctx := context.Background()
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := NewLRU(10)
+	c.now = func() time.Time { return now }
+	_ = c.Set(ctx, "short", []byte("1"), time.Minute)
+	_ = c.Set(ctx, "forever", []byte("2"), 0)
+
+	now = now.Add(time.Minute)
+	if _, found, _ := c.Get(ctx, "short"); found {
+		t.Fatalf("expired entry returned")
+	}
+	if value, found, _ := c.Get(ctx, "forever"); !found || string(value) != "2" {
+		t.Fatalf("entry without ttl must stay, got %q", value)
+	}
+
+	instrumented := Instrument(c)
+	_, _, _ = instrumented.Get(ctx, "forever")
+	_, _, _ = instrumented.Get(ctx, "short")
+	if hits, misses := instrumented.Stats(); hits != 1 || misses != 1 {
+		t.Fatalf("expected 1 hit and 1 miss, got %d and %d", hits, misses)
+	}
+}
@@ -1,28 +1,488 @@
 package config
 
 import (
+	"errors"
+	"flag"
 	"fmt"
+	"net/netip"
+	"net/url"
 	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
 )
 
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+)
+
+// MaxFieldLengthLimit is the size of the name, full_name and position columns.
+const MaxFieldLengthLimit = 200
+
 type Config struct {
-	Port        string
-	DatabaseURL string
+	Port           string
+	LogLevel       string
+	LogFormat      string
+	DatabaseDriver string
+	DatabaseURL    string
+	Database       DatabaseConfig
+	// AutoMigrate applies pending migrations before the server starts.
+	AutoMigrate       bool
+	ReadHeaderTimeout time.Duration
+	// WriteTimeout bounds a response, event streams excepted; 0 disables it.
+	WriteTimeout       time.Duration
+	SlowQueryThreshold time.Duration
+	IdempotencyTTL     time.Duration
+	// IdempotencyLease is how long a request holds its Idempotency-Key
+	// before a retry may take it over; at least WriteTimeout.
+	IdempotencyLease time.Duration
+	// ShutdownDelay is how long /readyz fails after SIGTERM before the listener
+	// closes, so that load balancers stop routing new connections first.
+	ShutdownDelay time.Duration
+	// ShutdownTimeout bounds how long in-flight requests may drain after SIGTERM.
+	ShutdownTimeout time.Duration
+	// TreeMaxDepth is the deepest subtree a client may request.
+	TreeMaxDepth Depth
+	// MaxFieldLength limits department names and employee full names and
+	// positions; it cannot exceed MaxFieldLengthLimit.
+	MaxFieldLength int
+	Auth           AuthConfig
+	// EmployeeFieldPolicy overrides which employee fields each role sees,
+	// e.g. "viewer=full_name,position". Empty keeps the built-in default.
+	EmployeeFieldPolicy string
+	RateLimit           RateLimitConfig
+	Tracing             TracingConfig
+	Cache               CacheConfig
+	Outbox              OutboxConfig
+	Webhooks            WebhooksConfig
+	Directory           DirectoryConfig
+
+	origin origin
+}
+
+// origin remembers where the configuration came from so that Reload can
+// repeat the same layering and Print can show the source of every value.
+type origin struct {
+	file    string
+	flags   map[string]string
+	sources map[string]string
+}
+
+// DatabaseConfig tunes the connection pools and optional read replica. Zero
+// durations and connection counts mean no limit, except that zero idle
+// connections keeps the database/sql default.
+type DatabaseConfig struct {
+	// ReplicaURL, when set, serves reads outside transactions (PostgreSQL only).
+	ReplicaURL string
+	// ReadYourWrites sends the reads of a request that has already committed a
+	// write to the primary, so the client never sees its own change missing.
+	ReadYourWrites   bool
+	MaxOpenConns     int
+	MaxIdleConns     int
+	ConnMaxLifetime  time.Duration
+	ConnMaxIdleTime  time.Duration
+	StatementTimeout time.Duration
+}
+
+// CacheConfig sizes the in-process cache of department trees. Zero entries
+// disable it.
+type CacheConfig struct {
+	TreeEntries int
+	TreeTTL     time.Duration
+}
+
+// OutboxConfig selects where the relay publishes change events besides the
+// webhook subscriptions: none, file or webhook.
+type OutboxConfig struct {
+	Sink       string
+	File       string
+	WebhookURL string
+	Interval   time.Duration
+	BatchSize  int
+	MaxBackoff time.Duration
+	// Retention is how long events are kept after publishing.
+	Retention time.Duration
+}
+
+// WebhooksConfig tunes the delivery to subscriptions managed under /webhooks.
+type WebhooksConfig struct {
+	Timeout     time.Duration
+	MaxAttempts int
+	MaxBackoff  time.Duration
+	// Retention is how long delivered and dead deliveries stay in the log.
+	Retention time.Duration
+	// AllowPrivateNetworks permits subscriptions to loopback and private
+	// addresses, for receivers next to the service.
+	AllowPrivateNetworks bool
+}
+
+// DirectoryConfig places the LDIF export in the target directory.
+type DirectoryConfig struct {
+	// BaseDN is appended to the DN of every exported entry.
+	BaseDN string
+}
+
+// TracingConfig selects where spans go: none, otlp, stdout or file.
+type TracingConfig struct {
+	Exporter     string
+	OTLPEndpoint string
+	File         string
+	ServiceName  string
+	SampleRatio  float64
+}
+
+// RateLimitConfig holds per-client token bucket limits. A zero rate disables
+// the corresponding limit.
+type RateLimitConfig struct {
+	ReadRate   float64
+	ReadBurst  int
+	WriteRate  float64
+	WriteBurst int
+	// TrustedProxies are the reverse proxies allowed to name the client IP
+	// in Forwarded or X-Forwarded-For.
+	TrustedProxies Prefixes
+}
+
+type AuthConfig struct {
+	// Disabled leaves the API open; meant for local development only.
+	Disabled      bool
+	JWTHMACSecret string
+	JWKSFile      string
+	JWTIssuer     string
+	JWTAudience   string
 }
 
-func Load() (Config, error) {
-	port := os.Getenv("APP_PORT")
-	if port == "" {
-		port = "8080"
+// JWTEnabled reports whether bearer tokens are accepted in addition to API keys.
+func (c AuthConfig) JWTEnabled() bool {
+	return c.JWTHMACSecret != "" || c.JWKSFile != ""
+}
+
+// Depth is a subtree depth where -1 means unlimited.
+type Depth int
+
+const Unlimited Depth = -1
+
+func (d Depth) String() string {
+	if d < 0 {
+		return "unlimited"
 	}
+	return strconv.Itoa(int(d))
+}
 
-	databaseURL := os.Getenv("DATABASE_URL")
-	if databaseURL == "" {
-		return Config{}, fmt.Errorf("DATABASE_URL required")
+func (d *Depth) Set(raw string) error {
+	if strings.EqualFold(raw, "unlimited") {
+		*d = Unlimited
+		return nil
+	}
+	parsed, err := strconv.Atoi(raw)
+	if err != nil || parsed < 0 {
+		return fmt.Errorf("must be a non-negative integer or unlimited")
 	}
+	*d = Depth(parsed)
+	return nil
+}
+
+// Prefixes is a comma-separated list of CIDR ranges; a bare address stands
+// for itself.
+type Prefixes []netip.Prefix
+
+func (p Prefixes) String() string {
+	values := make([]string, len(p))
+	for i, prefix := range p {
+		values[i] = prefix.String()
+	}
+	return strings.Join(values, ",")
+}
 
+func (p *Prefixes) Set(raw string) error {
+	var prefixes Prefixes
+	for _, value := range strings.Split(raw, ",") {
+		value = strings.TrimSpace(value)
+		if value == "" {
+			continue
+		}
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			addr, addrErr := netip.ParseAddr(value)
+			if addrErr != nil {
+				return fmt.Errorf("must be a comma-separated list of CIDR ranges or addresses")
+			}
+			prefix = netip.PrefixFrom(addr, addr.BitLen())
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+	*p = prefixes
+	return nil
+}
+
+func defaults() Config {
 	return Config{
-		Port:        port,
-		DatabaseURL: databaseURL,
-	}, nil
+		Port:               "8080",
+		LogLevel:           "info",
+		LogFormat:          "text",
+		ReadHeaderTimeout:  5 * time.Second,
+		SlowQueryThreshold: time.Second,
+		IdempotencyTTL:     24 * time.Hour,
+		IdempotencyLease:   time.Minute,
+		ShutdownDelay:      5 * time.Second,
+		ShutdownTimeout:    30 * time.Second,
+		TreeMaxDepth:       5,
+		MaxFieldLength:     MaxFieldLengthLimit,
+		Database: DatabaseConfig{
+			ReadYourWrites:  true,
+			MaxOpenConns:    20,
+			MaxIdleConns:    10,
+			ConnMaxLifetime: 30 * time.Minute,
+			ConnMaxIdleTime: 5 * time.Minute,
+		},
+		RateLimit: RateLimitConfig{ReadRate: 20, ReadBurst: 40, WriteRate: 5, WriteBurst: 10},
+		Tracing:   TracingConfig{Exporter: "none", ServiceName: "hitalent-go-task", SampleRatio: 1},
+		Cache:     CacheConfig{TreeEntries: 1000, TreeTTL: time.Minute},
+		Outbox: OutboxConfig{
+			Sink:       "none",
+			Interval:   time.Second,
+			BatchSize:  100,
+			MaxBackoff: 5 * time.Minute,
+			Retention:  7 * 24 * time.Hour,
+		},
+		Webhooks: WebhooksConfig{
+			Timeout:     10 * time.Second,
+			MaxAttempts: 10,
+			MaxBackoff:  time.Hour,
+			Retention:   30 * 24 * time.Hour,
+		},
+		Directory: DirectoryConfig{BaseDN: "dc=example,dc=com"},
+	}
+}
+
+// Load builds the configuration from defaults, then a YAML or TOML file
+// (-config or CONFIG_FILE), then environment variables, then command line
+// flags. It returns the arguments left after the flags, i.e. the subcommand.
+func Load(args []string) (Config, []string, error) {
+	flags := flag.NewFlagSet("server", flag.ContinueOnError)
+	configFile := flags.String("config", os.Getenv("CONFIG_FILE"), "path to a YAML or TOML configuration file")
+	flagValues := make(map[string]string)
+	for _, s := range settings {
+		flags.Var(&flagValue{key: s.key, values: flagValues, isBool: s.isBool()}, s.flagName(), "overrides "+s.env)
+	}
+	if err := flags.Parse(args); err != nil {
+		return Config{}, nil, err
+	}
+
+	cfg, err := load(*configFile, flagValues)
+	return cfg, flags.Args(), err
+}
+
+// Reload repeats the layering of current with a fresh read of the file and
+// the environment. Flags keep the values given at start.
+func Reload(current Config) (Config, error) {
+	return load(current.origin.file, current.origin.flags)
+}
+
+func load(file string, flagValues map[string]string) (Config, error) {
+	cfg := defaults()
+	cfg.origin = origin{file: file, flags: flagValues, sources: make(map[string]string)}
+	var errs []error
+	apply := func(s *setting, raw string, source string) {
+		if err := s.set(&cfg, raw); err != nil {
+			errs = append(errs, fmt.Errorf("%s (%s): %w", s.key, source, err))
+			return
+		}
+		cfg.origin.sources[s.key] = source
+	}
+
+	if file != "" {
+		values, err := readFile(file)
+		if err != nil {
+			return Config{}, err
+		}
+		for _, key := range values.keys {
+			s := lookupSetting(key)
+			if s == nil {
+				errs = append(errs, fmt.Errorf("%s: unknown key %q", file, key))
+				continue
+			}
+			apply(s, values.values[key], "file "+file)
+		}
+	}
+	for i := range settings {
+		if raw, ok := os.LookupEnv(settings[i].env); ok && raw != "" {
+			apply(&settings[i], raw, "env "+settings[i].env)
+		}
+	}
+	for i := range settings {
+		if raw, ok := flagValues[settings[i].key]; ok {
+			apply(&settings[i], raw, "flag -"+settings[i].flagName())
+		}
+	}
+	if len(errs) > 0 {
+		return Config{}, errors.Join(errs...)
+	}
+
+	cfg.DatabaseDriver = DriverPostgres
+	if strings.HasPrefix(cfg.DatabaseURL, "sqlite://") {
+		cfg.DatabaseDriver = DriverSQLite
+	}
+	if err := cfg.validate(); err != nil {
+		return Config{}, err
+	}
+	return cfg, nil
+}
+
+func (c Config) validate() error {
+	var errs []error
+	check := func(ok bool, key string, message string) {
+		if !ok {
+			errs = append(errs, fmt.Errorf("%s (%s): %s", key, c.source(key), message))
+		}
+	}
+
+	port, err := strconv.Atoi(c.Port)
+	check(err == nil && port > 0 && port <= 65535, "port", "must be a TCP port number")
+	check(c.DatabaseURL != "", "database_url", "required")
+	check(c.DatabaseDriver != DriverSQLite || strings.TrimPrefix(c.DatabaseURL, "sqlite://") != "",
+		"database_url", "must contain a file path after sqlite://")
+	check(c.Database.ReplicaURL == "" || c.DatabaseDriver == DriverPostgres && !strings.HasPrefix(c.Database.ReplicaURL, "sqlite://"),
+		"database.replica_url", "is supported only with PostgreSQL")
+	check(c.Database.StatementTimeout == 0 || c.DatabaseDriver == DriverPostgres,
+		"database.statement_timeout", "is supported only with PostgreSQL")
+	check(c.Database.MaxOpenConns >= 0, "database.max_open_conns", "must not be negative")
+	check(c.Database.MaxIdleConns >= 0, "database.max_idle_conns", "must not be negative")
+	check(c.Database.MaxOpenConns == 0 || c.Database.MaxIdleConns <= c.Database.MaxOpenConns,
+		"database.max_idle_conns", "must not exceed database.max_open_conns")
+	for key, value := range map[string]time.Duration{
+		"database.conn_max_lifetime":  c.Database.ConnMaxLifetime,
+		"database.conn_max_idle_time": c.Database.ConnMaxIdleTime,
+		"database.statement_timeout":  c.Database.StatementTimeout,
+		"shutdown_delay":              c.ShutdownDelay,
+		"write_timeout":               c.WriteTimeout,
+	} {
+		check(value >= 0, key, "must not be negative")
+	}
+	check(validLogLevel(c.LogLevel), "log_level", "must be debug, info, warn or error")
+	check(c.LogFormat == "text" || c.LogFormat == "json", "log_format", "must be text or json")
+	for key, value := range map[string]time.Duration{
+		"read_header_timeout":  c.ReadHeaderTimeout,
+		"slow_query_threshold": c.SlowQueryThreshold,
+		"idempotency_ttl":      c.IdempotencyTTL,
+		"idempotency_lease":    c.IdempotencyLease,
+		"shutdown_timeout":     c.ShutdownTimeout,
+	} {
+		check(value > 0, key, "must be a positive duration")
+	}
+	check(c.WriteTimeout == 0 || c.IdempotencyLease >= c.WriteTimeout,
+		"idempotency_lease", "must not be shorter than write_timeout")
+	check(c.MaxFieldLength >= 1 && c.MaxFieldLength <= MaxFieldLengthLimit,
+		"max_field_length", fmt.Sprintf("must be between 1 and %d (the column size)", MaxFieldLengthLimit))
+	check(c.Auth.JWTHMACSecret == "" || c.Auth.JWKSFile == "",
+		"auth.jwt_hmac_secret", "cannot be combined with auth.jwks_file")
+	check(c.RateLimit.ReadRate >= 0, "rate_limit.read_rps", "must be a non-negative number")
+	check(c.RateLimit.WriteRate >= 0, "rate_limit.write_rps", "must be a non-negative number")
+	check(c.RateLimit.ReadBurst >= 1, "rate_limit.read_burst", "must be a positive integer")
+	check(c.RateLimit.WriteBurst >= 1, "rate_limit.write_burst", "must be a positive integer")
+	switch c.Tracing.Exporter {
+	case "none", "otlp", "stdout":
+	case "file":
+		check(c.Tracing.File != "", "tracing.file", "required when tracing.exporter is file")
+	default:
+		check(false, "tracing.exporter", "must be none, otlp, stdout or file")
+	}
+	check(c.Tracing.SampleRatio >= 0 && c.Tracing.SampleRatio <= 1, "tracing.sample_ratio", "must be between 0 and 1")
+	check(c.Cache.TreeEntries >= 0, "cache.tree_entries", "must not be negative")
+	check(c.Cache.TreeTTL > 0, "cache.tree_ttl", "must be a positive duration")
+	switch c.Outbox.Sink {
+	case "none":
+	case "file":
+		check(c.Outbox.File != "", "outbox.file", "required when outbox.sink is file")
+	case "webhook":
+		check(validHTTPURL(c.Outbox.WebhookURL), "outbox.webhook_url", "must be an http or https URL when outbox.sink is webhook")
+	default:
+		check(false, "outbox.sink", "must be none, file or webhook")
+	}
+	for key, value := range map[string]time.Duration{
+		"outbox.interval":      c.Outbox.Interval,
+		"outbox.max_backoff":   c.Outbox.MaxBackoff,
+		"outbox.retention":     c.Outbox.Retention,
+		"webhooks.timeout":     c.Webhooks.Timeout,
+		"webhooks.max_backoff": c.Webhooks.MaxBackoff,
+		"webhooks.retention":   c.Webhooks.Retention,
+	} {
+		check(value > 0, key, "must be a positive duration")
+	}
+	check(c.Outbox.BatchSize >= 1, "outbox.batch_size", "must be a positive integer")
+	check(c.Webhooks.MaxAttempts >= 1, "webhooks.max_attempts", "must be a positive integer")
+	check(strings.Contains(c.Directory.BaseDN, "="), "directory.base_dn", "must be a DN such as dc=example,dc=com")
+	return errors.Join(errs...)
+}
+
+func validHTTPURL(raw string) bool {
+	parsed, err := url.Parse(raw)
+	return err == nil && (parsed.Scheme == "http" || parsed.Scheme == "https") && parsed.Host != ""
+}
+
+func validLogLevel(level string) bool {
+	switch strings.ToLower(level) {
+	case "debug", "info", "warn", "error":
+		return true
+	}
+	return false
+}
+
+func (c Config) source(key string) string {
+	if source, ok := c.origin.sources[key]; ok {
+		return source
+	}
+	return "default"
+}
+
+// Changed lists the keys whose values differ between two configurations.
+func Changed(previous Config, next Config) []string {
+	var keys []string
+	for _, s := range settings {
+		if s.format(&previous) != s.format(&next) {
+			keys = append(keys, s.key)
+		}
+	}
+	return keys
+}
+
+// Reloadable reports whether a running server applies a new value of key
+// without a restart.
+func Reloadable(key string) bool {
+	s := lookupSetting(key)
+	return s != nil && s.reloadable
+}
+
+// dsnPassword matches a password in a keyword/value DSN, quoted or not, e.g.
+// "host=db password='s 3cret'"; sslpassword is covered too.
+var dsnPassword = regexp.MustCompile(`(?i)(\b\w*password\s*=\s*)('(?:[^'\\]|\\.)*'|\S*)`)
+
+// redactURL hides the password of a database URL wherever pgx accepts it:
+// in the userinfo, in a password query parameter or in a keyword/value DSN.
+// A URL that cannot be parsed is hidden entirely.
+func redactURL(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	if !strings.Contains(raw, "://") {
+		return dsnPassword.ReplaceAllString(raw, "${1}xxxxx")
+	}
+	parsed, err := url.Parse(raw)
+	if err != nil {
+		return "[redacted]"
+	}
+	query := parsed.Query()
+	redacted := false
+	for name := range query {
+		if strings.Contains(strings.ToLower(name), "password") {
+			query.Set(name, "xxxxx")
+			redacted = true
+		}
+	}
+	if redacted {
+		parsed.RawQuery = query.Encode()
+	}
+	return parsed.Redacted()
 }
@@ -0,0 +1,168 @@
+package config
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, name string, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+		t.Fatalf("write %s: %v", name, err)
+	}
+	return path
+}
+
+func TestLoadLayers(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+port: "9000"
+database_url: postgres://app:secret@db:5432/app
+tree_max_depth: unlimited
+rate_limit:
+  read_rps: 50
+  write_rps: 7
+  trusted_proxies: 10.0.0.0/8, 192.168.1.10
+auth:
+  jwt_hmac_secret: hmac-secret
+`)
+	t.Setenv("CONFIG_FILE", file)
+	t.Setenv("RATE_LIMIT_READ_RPS", "60")
+
+	cfg, args, err := Load([]string{"-rate-limit-write-rps", "8", "-auto-migrate", "migrate", "up"})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if strings.Join(args, " ") != "migrate up" {
+		t.Fatalf("unexpected remaining args %v", args)
+	}
+	if cfg.Port != "9000" || cfg.TreeMaxDepth != Unlimited || cfg.DatabaseDriver != DriverPostgres {
+		t.Fatalf("file values not applied: %+v", cfg)
+	}
+	if cfg.RateLimit.ReadRate != 60 || cfg.RateLimit.WriteRate != 8 || !cfg.AutoMigrate {
+		t.Fatalf("env and flags must override the file: %+v", cfg.RateLimit)
+	}
+	if cfg.ShutdownTimeout != 30*time.Second || cfg.ShutdownDelay != 5*time.Second || cfg.MaxFieldLength != MaxFieldLengthLimit {
+		t.Fatalf("defaults not applied: %+v", cfg)
+	}
+
+	var out bytes.Buffer
+	if err := Print(&out, cfg); err != nil {
+		t.Fatalf("print: %v", err)
+	}
+	printed := out.String()
+	if strings.Contains(printed, "secret@") || strings.Contains(printed, "hmac-secret") {
+		t.Fatalf("secrets leaked:\n%s", printed)
+	}
+	for _, want := range []string{"env RATE_LIMIT_READ_RPS", "flag -rate-limit-write-rps", "file " + file, "shutdown_timeout", "10.0.0.0/8,192.168.1.10/32"} {
+		if !strings.Contains(printed, want) {
+			t.Fatalf("expected %q in:\n%s", want, printed)
+		}
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	file := writeFile(t, "config.toml", `
+database_url = "sqlite:///tmp/app.db"
+read_header_timeout = "2s"
+
+[tracing]
+exporter = "stdout"
+sample_ratio = 0.25
+`)
+	cfg, _, err := Load([]string{"-config", file})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.DatabaseDriver != DriverSQLite || cfg.ReadHeaderTimeout != 2*time.Second ||
+		cfg.Tracing.Exporter != "stdout" || cfg.Tracing.SampleRatio != 0.25 {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+}
+
+func TestLoadReportsAllErrors(t *testing.T) {
+	file := writeFile(t, "config.yaml", `
+database_url: postgres://db/app
+max_field_length: 500
+rate_limit:
+  read_burst: 0
+  trusted_proxies: 10.0.0.0/33
+unknown_key: 1
+`)
+	t.Setenv("SHUTDOWN_TIMEOUT", "soon")
+
+	_, _, err := Load([]string{"-config", file})
+	if err == nil {
+		t.Fatalf("expected errors")
+	}
+	for _, want := range []string{
+		`unknown key "unknown_key"`,
+		"shutdown_timeout (env SHUTDOWN_TIMEOUT): must be a duration",
+		"rate_limit.trusted_proxies (file " + file + "): must be a comma-separated list",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+
+	t.Setenv("SHUTDOWN_TIMEOUT", "")
+	file = writeFile(t, "config.yaml", `
+database_url: postgres://db/app
+max_field_length: 500
+write_timeout: 2m
+rate_limit:
+  read_burst: 0
+`)
+	_, _, err = Load([]string{"-config", file})
+	if err == nil {
+		t.Fatalf("expected validation errors")
+	}
+	for _, want := range []string{"max_field_length (file ", "rate_limit.read_burst (file ", "must not be shorter than write_timeout"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %q in %v", want, err)
+		}
+	}
+}
+
+func TestReload(t *testing.T) {
+	file := writeFile(t, "config.yaml", "database_url: postgres://db/app\nlog_level: info\n")
+	cfg, _, err := Load([]string{"-config", file, "-port", "9100"})
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if err := os.WriteFile(file, []byte("database_url: postgres://db/app\nlog_level: debug\nport: \"9200\"\n"), 0o600); err != nil {
+		t.Fatalf("rewrite config: %v", err)
+	}
+	next, err := Reload(cfg)
+	if err != nil {
+		t.Fatalf("reload: %v", err)
+	}
+	if next.LogLevel != "debug" || next.Port != "9100" {
+		t.Fatalf("reload must re-read the file and keep flags: %+v", next)
+	}
+	changed := Changed(cfg, next)
+	if len(changed) != 1 || changed[0] != "log_level" || !Reloadable("log_level") || Reloadable("port") {
+		t.Fatalf("unexpected changes %v", changed)
+	}
+}
+
+func TestRedactURL(t *testing.T) {
+	cases := map[string]string{
+		"postgres://app:s3cret@db:5432/app":                          "postgres://app:xxxxx@db:5432/app",
+		"postgres://db/app?password=s3cret&sslmode=disable":          "postgres://db/app?password=xxxxx&sslmode=disable",
+		"host=db user=app password=s3cret dbname=app":                "host=db user=app password=xxxxx dbname=app",
+		"host=db password = 's 3\\'cret' sslpassword=key dbname=app": "host=db password = xxxxx sslpassword=xxxxx dbname=app",
+		"sqlite:///var/lib/app.db":                                   "sqlite:///var/lib/app.db",
+		"postgres://app:s3cret@db:bad-port/app":                      "[redacted]",
+	}
+	for raw, want := range cases {
+		if got := redactURL(raw); got != want {
+			t.Fatalf("redactURL(%q) = %q, want %q", raw, got, want)
+		}
+	}
+}
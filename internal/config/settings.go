@@ -0,0 +1,263 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+)
+
+// setting binds a file key to its environment variable and Config field.
+// Command line flags are derived from the key: rate_limit.read_rps becomes
+// -rate-limit-read-rps.
+type setting struct {
+	key        string
+	env        string
+	secret     bool
+	reloadable bool
+	field      func(*Config) any
+}
+
+var settings = []setting{
+	{key: "port", env: "APP_PORT", field: func(c *Config) any { return &c.Port }},
+	{key: "log_level", env: "LOG_LEVEL", reloadable: true, field: func(c *Config) any { return &c.LogLevel }},
+	{key: "log_format", env: "LOG_FORMAT", field: func(c *Config) any { return &c.LogFormat }},
+	{key: "database_url", env: "DATABASE_URL", secret: true, field: func(c *Config) any { return &c.DatabaseURL }},
+	{key: "database.replica_url", env: "DATABASE_REPLICA_URL", secret: true, field: func(c *Config) any { return &c.Database.ReplicaURL }},
+	{key: "database.read_your_writes", env: "DB_READ_YOUR_WRITES", field: func(c *Config) any { return &c.Database.ReadYourWrites }},
+	{key: "database.max_open_conns", env: "DB_MAX_OPEN_CONNS", field: func(c *Config) any { return &c.Database.MaxOpenConns }},
+	{key: "database.max_idle_conns", env: "DB_MAX_IDLE_CONNS", field: func(c *Config) any { return &c.Database.MaxIdleConns }},
+	{key: "database.conn_max_lifetime", env: "DB_CONN_MAX_LIFETIME", field: func(c *Config) any { return &c.Database.ConnMaxLifetime }},
+	{key: "database.conn_max_idle_time", env: "DB_CONN_MAX_IDLE_TIME", field: func(c *Config) any { return &c.Database.ConnMaxIdleTime }},
+	{key: "database.statement_timeout", env: "DB_STATEMENT_TIMEOUT", field: func(c *Config) any { return &c.Database.StatementTimeout }},
+	{key: "auto_migrate", env: "AUTO_MIGRATE", field: func(c *Config) any { return &c.AutoMigrate }},
+	{key: "read_header_timeout", env: "READ_HEADER_TIMEOUT", field: func(c *Config) any { return &c.ReadHeaderTimeout }},
+	{key: "write_timeout", env: "WRITE_TIMEOUT", field: func(c *Config) any { return &c.WriteTimeout }},
+	{key: "slow_query_threshold", env: "SLOW_QUERY_THRESHOLD", field: func(c *Config) any { return &c.SlowQueryThreshold }},
+	{key: "idempotency_ttl", env: "IDEMPOTENCY_TTL", field: func(c *Config) any { return &c.IdempotencyTTL }},
+	{key: "idempotency_lease", env: "IDEMPOTENCY_LEASE", field: func(c *Config) any { return &c.IdempotencyLease }},
+	{key: "shutdown_delay", env: "SHUTDOWN_DELAY", field: func(c *Config) any { return &c.ShutdownDelay }},
+	{key: "shutdown_timeout", env: "SHUTDOWN_TIMEOUT", field: func(c *Config) any { return &c.ShutdownTimeout }},
+	{key: "tree_max_depth", env: "TREE_MAX_DEPTH", field: func(c *Config) any { return &c.TreeMaxDepth }},
+	{key: "max_field_length", env: "MAX_FIELD_LENGTH", field: func(c *Config) any { return &c.MaxFieldLength }},
+	{key: "employee_field_policy", env: "EMPLOYEE_FIELD_POLICY", field: func(c *Config) any { return &c.EmployeeFieldPolicy }},
+	{key: "auth.disabled", env: "AUTH_DISABLED", field: func(c *Config) any { return &c.Auth.Disabled }},
+	{key: "auth.jwt_hmac_secret", env: "AUTH_JWT_HMAC_SECRET", secret: true, field: func(c *Config) any { return &c.Auth.JWTHMACSecret }},
+	{key: "auth.jwks_file", env: "AUTH_JWKS_FILE", field: func(c *Config) any { return &c.Auth.JWKSFile }},
+	{key: "auth.jwt_issuer", env: "AUTH_JWT_ISSUER", field: func(c *Config) any { return &c.Auth.JWTIssuer }},
+	{key: "auth.jwt_audience", env: "AUTH_JWT_AUDIENCE", field: func(c *Config) any { return &c.Auth.JWTAudience }},
+	{key: "rate_limit.read_rps", env: "RATE_LIMIT_READ_RPS", reloadable: true, field: func(c *Config) any { return &c.RateLimit.ReadRate }},
+	{key: "rate_limit.read_burst", env: "RATE_LIMIT_READ_BURST", reloadable: true, field: func(c *Config) any { return &c.RateLimit.ReadBurst }},
+	{key: "rate_limit.write_rps", env: "RATE_LIMIT_WRITE_RPS", reloadable: true, field: func(c *Config) any { return &c.RateLimit.WriteRate }},
+	{key: "rate_limit.write_burst", env: "RATE_LIMIT_WRITE_BURST", reloadable: true, field: func(c *Config) any { return &c.RateLimit.WriteBurst }},
+	{key: "rate_limit.trusted_proxies", env: "RATE_LIMIT_TRUSTED_PROXIES", field: func(c *Config) any { return &c.RateLimit.TrustedProxies }},
+	{key: "tracing.exporter", env: "TRACING_EXPORTER", field: func(c *Config) any { return &c.Tracing.Exporter }},
+	{key: "tracing.otlp_endpoint", env: "TRACING_OTLP_ENDPOINT", field: func(c *Config) any { return &c.Tracing.OTLPEndpoint }},
+	{key: "tracing.file", env: "TRACING_FILE", field: func(c *Config) any { return &c.Tracing.File }},
+	{key: "tracing.service_name", env: "OTEL_SERVICE_NAME", field: func(c *Config) any { return &c.Tracing.ServiceName }},
+	{key: "tracing.sample_ratio", env: "TRACING_SAMPLE_RATIO", field: func(c *Config) any { return &c.Tracing.SampleRatio }},
+	{key: "cache.tree_entries", env: "TREE_CACHE_ENTRIES", field: func(c *Config) any { return &c.Cache.TreeEntries }},
+	{key: "cache.tree_ttl", env: "TREE_CACHE_TTL", field: func(c *Config) any { return &c.Cache.TreeTTL }},
+	{key: "outbox.sink", env: "OUTBOX_SINK", field: func(c *Config) any { return &c.Outbox.Sink }},
+	{key: "outbox.file", env: "OUTBOX_FILE", field: func(c *Config) any { return &c.Outbox.File }},
+	{key: "outbox.webhook_url", env: "OUTBOX_WEBHOOK_URL", secret: true, field: func(c *Config) any { return &c.Outbox.WebhookURL }},
+	{key: "outbox.interval", env: "OUTBOX_INTERVAL", field: func(c *Config) any { return &c.Outbox.Interval }},
+	{key: "outbox.batch_size", env: "OUTBOX_BATCH_SIZE", field: func(c *Config) any { return &c.Outbox.BatchSize }},
+	{key: "outbox.max_backoff", env: "OUTBOX_MAX_BACKOFF", field: func(c *Config) any { return &c.Outbox.MaxBackoff }},
+	{key: "outbox.retention", env: "OUTBOX_RETENTION", field: func(c *Config) any { return &c.Outbox.Retention }},
+	{key: "webhooks.timeout", env: "WEBHOOK_TIMEOUT", field: func(c *Config) any { return &c.Webhooks.Timeout }},
+	{key: "webhooks.max_attempts", env: "WEBHOOK_MAX_ATTEMPTS", field: func(c *Config) any { return &c.Webhooks.MaxAttempts }},
+	{key: "webhooks.max_backoff", env: "WEBHOOK_MAX_BACKOFF", field: func(c *Config) any { return &c.Webhooks.MaxBackoff }},
+	{key: "webhooks.retention", env: "WEBHOOK_RETENTION", field: func(c *Config) any { return &c.Webhooks.Retention }},
+	{key: "webhooks.allow_private_networks", env: "WEBHOOK_ALLOW_PRIVATE_NETWORKS", field: func(c *Config) any { return &c.Webhooks.AllowPrivateNetworks }},
+	{key: "directory.base_dn", env: "DIRECTORY_BASE_DN", field: func(c *Config) any { return &c.Directory.BaseDN }},
+}
+
+func lookupSetting(key string) *setting {
+	for i := range settings {
+		if settings[i].key == key {
+			return &settings[i]
+		}
+	}
+	return nil
+}
+
+func (s *setting) flagName() string {
+	return strings.NewReplacer(".", "-", "_", "-").Replace(s.key)
+}
+
+func (s *setting) isBool() bool {
+	_, ok := s.field(&Config{}).(*bool)
+	return ok
+}
+
+func (s *setting) set(c *Config, raw string) error {
+	raw = strings.TrimSpace(raw)
+	switch field := s.field(c).(type) {
+	case *string:
+		*field = raw
+	case *bool:
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return fmt.Errorf("must be a boolean")
+		}
+		*field = parsed
+	case *int:
+		parsed, err := strconv.Atoi(raw)
+		if err != nil {
+			return fmt.Errorf("must be an integer")
+		}
+		*field = parsed
+	case *float64:
+		parsed, err := strconv.ParseFloat(raw, 64)
+		if err != nil {
+			return fmt.Errorf("must be a number")
+		}
+		*field = parsed
+	case *time.Duration:
+		parsed, err := time.ParseDuration(raw)
+		if err != nil {
+			return fmt.Errorf("must be a duration such as 500ms or 30s")
+		}
+		*field = parsed
+	case *Depth:
+		return field.Set(raw)
+	case *Prefixes:
+		return field.Set(raw)
+	default:
+		panic(fmt.Sprintf("config: unsupported type %T for %s", field, s.key))
+	}
+	return nil
+}
+
+func (s *setting) format(c *Config) string {
+	switch field := s.field(c).(type) {
+	case *string:
+		return *field
+	case *bool:
+		return strconv.FormatBool(*field)
+	case *int:
+		return strconv.Itoa(*field)
+	case *float64:
+		return strconv.FormatFloat(*field, 'g', -1, 64)
+	case *time.Duration:
+		return field.String()
+	case *Depth:
+		return field.String()
+	case *Prefixes:
+		return field.String()
+	default:
+		panic(fmt.Sprintf("config: unsupported type %T for %s", field, s.key))
+	}
+}
+
+// flagValue records a flag as a raw string so that flags are applied after
+// the file and the environment.
+type flagValue struct {
+	key    string
+	values map[string]string
+	isBool bool
+}
+
+func (f *flagValue) String() string {
+	if f.values == nil {
+		return ""
+	}
+	return f.values[f.key]
+}
+
+func (f *flagValue) Set(raw string) error {
+	f.values[f.key] = raw
+	return nil
+}
+
+func (f *flagValue) IsBoolFlag() bool {
+	return f.isBool
+}
+
+type fileValues struct {
+	keys   []string
+	values map[string]string
+}
+
+// readFile flattens a YAML or TOML file into dotted keys, e.g. the read_rps
+// entry of the rate_limit table becomes rate_limit.read_rps.
+func readFile(path string) (fileValues, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return fileValues{}, fmt.Errorf("read config file: %w", err)
+	}
+
+	document := make(map[string]any)
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(content, &document)
+	case ".toml":
+		err = toml.Unmarshal(content, &document)
+	default:
+		return fileValues{}, fmt.Errorf("config file %s: unsupported format, expected .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return fileValues{}, fmt.Errorf("parse config file %s: %w", path, err)
+	}
+
+	values := fileValues{values: make(map[string]string)}
+	if err := flatten("", document, values.values); err != nil {
+		return fileValues{}, fmt.Errorf("config file %s: %w", path, err)
+	}
+	for key := range values.values {
+		values.keys = append(values.keys, key)
+	}
+	sort.Strings(values.keys)
+	return values, nil
+}
+
+func flatten(prefix string, document map[string]any, out map[string]string) error {
+	for key, value := range document {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		switch value := value.(type) {
+		case map[string]any:
+			if err := flatten(key, value, out); err != nil {
+				return err
+			}
+		case nil:
+			out[key] = ""
+		case string, bool, int, int64, uint64, float64:
+			out[key] = fmt.Sprint(value)
+		default:
+			return fmt.Errorf("%s: unsupported value %v", key, value)
+		}
+	}
+	return nil
+}
+
+// Print writes every effective value with its source. Secrets are redacted.
+func Print(w io.Writer, c Config) error {
+	writer := tabwriter.NewWriter(w, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(writer, "KEY\tVALUE\tSOURCE")
+	for i := range settings {
+		s := &settings[i]
+		value := s.format(&c)
+		switch {
+		case s.key == "database_url" || s.key == "database.replica_url":
+			value = redactURL(value)
+		case s.secret && value != "":
+			value = "[redacted]"
+		}
+		if value == "" {
+			value = "-"
+		}
+		fmt.Fprintf(writer, "%s\t%s\t%s\n", s.key, value, c.source(s.key))
+	}
+	return writer.Flush()
+}
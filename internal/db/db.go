@@ -1,29 +1,87 @@
 package db
 
 import (
-	"log"
-	"os"
-	"time"
+	"database/sql"
+	"fmt"
+	"log/slog"
+	"strconv"
 
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/stdlib"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 
 	"hitalent-go-task/internal/config"
+	"hitalent-go-task/internal/logging"
+	"hitalent-go-task/internal/tracing"
 )
 
-func Connect(cfg config.Config) (*gorm.DB, error) {
-	gormLogger := logger.New(
-		log.New(os.Stdout, "", log.LstdFlags),
-		logger.Config{
-			SlowThreshold:             time.Second,
-			LogLevel:                  logger.Warn,
-			IgnoreRecordNotFoundError: true,
-			Colorful:                  false,
-		},
-	)
-
-	return gorm.Open(postgres.Open(cfg.DatabaseURL), &gorm.Config{
-		Logger: gormLogger,
-	})
+func Connect(cfg config.Config, logger *slog.Logger) (*gorm.DB, error) {
+	gormLogger := logging.NewGormLogger(logger, cfg.SlowQueryThreshold)
+
+	var database *gorm.DB
+	var err error
+	switch cfg.DatabaseDriver {
+	case config.DriverSQLite:
+		database, err = openSQLite(cfg.DatabaseURL, cfg.Database, gormLogger)
+	case config.DriverPostgres, "":
+		var dialector gorm.Dialector
+		dialector, _, err = openPostgres(cfg.DatabaseURL, cfg.Database)
+		if err == nil {
+			database, err = gorm.Open(dialector, &gorm.Config{
+				Logger: gormLogger,
+			})
+		}
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", cfg.DatabaseDriver)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	system := cfg.DatabaseDriver
+	if system == "" {
+		system = config.DriverPostgres
+	}
+	if err := database.Use(tracing.GormPlugin{System: system}); err != nil {
+		return nil, fmt.Errorf("register tracing plugin: %w", err)
+	}
+	return database, nil
+}
+
+// ConnectMaintenance returns the pool for migrations and maintenance commands,
+// which may legitimately run longer than the statement timeout of database.
+// Without a timeout to lift it is database itself.
+func ConnectMaintenance(database *gorm.DB, cfg config.Config, logger *slog.Logger) (*gorm.DB, error) {
+	if cfg.Database.StatementTimeout == 0 || cfg.DatabaseDriver == config.DriverSQLite {
+		return database, nil
+	}
+	cfg.Database.StatementTimeout = 0
+	// The migration lock holds one connection while the migrations use another.
+	cfg.Database.MaxOpenConns = 2
+	return Connect(cfg, logger)
+}
+
+// openPostgres opens a connection pool for dsn with the pool limits and
+// statement timeout of cfg applied.
+func openPostgres(dsn string, cfg config.DatabaseConfig) (gorm.Dialector, *sql.DB, error) {
+	connConfig, err := pgx.ParseConfig(dsn)
+	if err != nil {
+		return nil, nil, fmt.Errorf("parse database url: %w", err)
+	}
+	if cfg.StatementTimeout > 0 {
+		connConfig.RuntimeParams["statement_timeout"] = strconv.FormatInt(cfg.StatementTimeout.Milliseconds(), 10)
+	}
+	sqlDB := stdlib.OpenDB(*connConfig)
+	configurePool(sqlDB, cfg)
+	return postgres.New(postgres.Config{Conn: sqlDB}), sqlDB, nil
+}
+
+func configurePool(sqlDB *sql.DB, cfg config.DatabaseConfig) {
+	sqlDB.SetMaxOpenConns(cfg.MaxOpenConns)
+	if cfg.MaxIdleConns > 0 {
+		sqlDB.SetMaxIdleConns(cfg.MaxIdleConns)
+	}
+	sqlDB.SetConnMaxLifetime(cfg.ConnMaxLifetime)
+	sqlDB.SetConnMaxIdleTime(cfg.ConnMaxIdleTime)
 }
@@ -0,0 +1,45 @@
+package db
+
+import (
+	"fmt"
+	"io/fs"
+
+	"github.com/pressly/goose/v3"
+	"github.com/pressly/goose/v3/lock"
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/config"
+	"hitalent-go-task/migrations"
+)
+
+// NewMigrator returns a goose provider over the migrations embedded for the
+// driver. On PostgreSQL migrations run under a session advisory lock, so
+// replicas that start together apply them one at a time.
+func NewMigrator(database *gorm.DB, driver string) (*goose.Provider, error) {
+	sqlDB, err := database.DB()
+	if err != nil {
+		return nil, err
+	}
+
+	var dialect goose.Dialect
+	var options []goose.ProviderOption
+	switch driver {
+	case config.DriverSQLite:
+		dialect = goose.DialectSQLite3
+	case config.DriverPostgres, "":
+		driver, dialect = config.DriverPostgres, goose.DialectPostgres
+		locker, err := lock.NewPostgresSessionLocker()
+		if err != nil {
+			return nil, fmt.Errorf("create migration lock: %w", err)
+		}
+		options = append(options, goose.WithSessionLocker(locker))
+	default:
+		return nil, fmt.Errorf("unsupported database driver %q", driver)
+	}
+
+	files, err := fs.Sub(migrations.FS, driver)
+	if err != nil {
+		return nil, err
+	}
+	return goose.NewProvider(dialect, sqlDB, files, options...)
+}
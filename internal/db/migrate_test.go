@@ -0,0 +1,57 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	gormlogger "gorm.io/gorm/logger"
+
+	"hitalent-go-task/internal/config"
+)
+
+func TestEmbeddedMigrationsRoundTrip(t *testing.T) {
+	database, err := openSQLite("sqlite://"+filepath.Join(t.TempDir(), "migrate.db"), config.DatabaseConfig{}, gormlogger.Discard)
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	migrator, err := NewMigrator(database, config.DriverSQLite)
+	if err != nil {
+		t.Fatalf("create migrator: %v", err)
+	}
+	ctx := context.Background()
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("up: %v", err)
+	}
+	current, target, err := migrator.GetVersions(ctx)
+	if err != nil || current != target || target == 0 {
+		t.Fatalf("expected all migrations applied, got %d of %d, %v", current, target, err)
+	}
+
+	if _, err := migrator.DownTo(ctx, 0); err != nil {
+		t.Fatalf("down: %v", err)
+	}
+	if current, _, err := migrator.GetVersions(ctx); err != nil || current != 0 {
+		t.Fatalf("expected version 0 after rolling back, got %d, %v", current, err)
+	}
+
+	if _, err := migrator.Up(ctx); err != nil {
+		t.Fatalf("up after rollback: %v", err)
+	}
+
+	// Every embedded postgres migration has a sqlite counterpart.
+	postgresMigrator, err := NewMigrator(database, config.DriverPostgres)
+	if err != nil {
+		t.Fatalf("create postgres migrator: %v", err)
+	}
+	postgresSources, sqliteSources := postgresMigrator.ListSources(), migrator.ListSources()
+	if len(postgresSources) != len(sqliteSources) {
+		t.Fatalf("%d postgres migrations, %d sqlite migrations", len(postgresSources), len(sqliteSources))
+	}
+	for i := range postgresSources {
+		if postgresSources[i].Version != sqliteSources[i].Version {
+			t.Fatalf("migration %d: postgres version %d, sqlite version %d", i, postgresSources[i].Version, sqliteSources[i].Version)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package db
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+	"gorm.io/plugin/dbresolver"
+
+	"hitalent-go-task/internal/config"
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+)
+
+// UseReplica routes reads of the organization tables that run outside a
+// transaction to the read replica of cfg. Writes, transactions and the
+// bookkeeping tables (API keys, idempotency keys, audit) stay on the primary.
+// It returns the replica pool so that its statistics can be exported.
+func UseReplica(database *gorm.DB, cfg config.Config) (*sql.DB, error) {
+	dialector, sqlDB, err := openPostgres(cfg.Database.ReplicaURL, cfg.Database)
+	if err != nil {
+		return nil, fmt.Errorf("replica: %w", err)
+	}
+	if err := useReplica(database, dialector); err != nil {
+		_ = sqlDB.Close()
+		return nil, err
+	}
+	return sqlDB, nil
+}
+
+func useReplica(database *gorm.DB, replica gorm.Dialector) error {
+	// A request that has committed a write reads from the primary for the
+	// rest of its lifetime, so it never misses its own change on a lagging
+	// replica.
+	readYourWrites := func(db *gorm.DB) {
+		if repository.ReadFromPrimary(db.Statement.Context) {
+			dbresolver.Write.ModifyStatement(db.Statement)
+		}
+	}
+	// dbresolver picks the pool first thing, so the hook has to be registered
+	// ahead of it.
+	callback := database.Callback()
+	if err := errors.Join(
+		callback.Query().Before("*").Register("app:read_your_writes", readYourWrites),
+		callback.Row().Before("*").Register("app:read_your_writes", readYourWrites),
+		callback.Raw().Before("*").Register("app:read_your_writes", readYourWrites),
+	); err != nil {
+		return err
+	}
+
+	resolver := dbresolver.Register(
+		dbresolver.Config{Replicas: []gorm.Dialector{replica}},
+		&models.Department{}, &models.Employee{}, &models.DepartmentClosure{},
+	)
+	if err := database.Use(resolver); err != nil {
+		return fmt.Errorf("register replica: %w", err)
+	}
+	return nil
+}
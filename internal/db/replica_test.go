@@ -0,0 +1,80 @@
+package db
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	gormsqlite "github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+
+	"hitalent-go-task/internal/config"
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+)
+
+func openMigrated(t *testing.T, name string) *gorm.DB {
+	t.Helper()
+	database, err := openSQLite("sqlite://"+filepath.Join(t.TempDir(), name), config.DatabaseConfig{}, gormlogger.Discard)
+	if err != nil {
+		t.Fatalf("open %s: %v", name, err)
+	}
+	migrator, err := NewMigrator(database, config.DriverSQLite)
+	if err != nil {
+		t.Fatalf("create migrator: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("migrate %s: %v", name, err)
+	}
+	if err := database.Create(&models.Department{Name: name}).Error; err != nil {
+		t.Fatalf("seed %s: %v", name, err)
+	}
+	return database
+}
+
+func TestReplicaRouting(t *testing.T) {
+	primary := openMigrated(t, "primary")
+	replica := openMigrated(t, "replica")
+	replicaConn, err := replica.DB()
+	if err != nil {
+		t.Fatalf("replica pool: %v", err)
+	}
+	if err := useReplica(primary, gormsqlite.Dialector{Conn: replicaConn}); err != nil {
+		t.Fatalf("use replica: %v", err)
+	}
+
+	readName := func(ctx context.Context, db *gorm.DB) string {
+		t.Helper()
+		var department models.Department
+		if err := db.WithContext(ctx).First(&department).Error; err != nil {
+			t.Fatalf("read: %v", err)
+		}
+		return department.Name
+	}
+
+	ctx := repository.WithSession(context.Background())
+	if name := readName(ctx, primary); name != "replica" {
+		t.Fatalf("reads must go to the replica, got %s", name)
+	}
+	err = primary.Transaction(func(tx *gorm.DB) error {
+		if name := readName(ctx, tx); name != "primary" {
+			t.Fatalf("reads in a transaction must go to the primary, got %s", name)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("transaction: %v", err)
+	}
+
+	repository.RecordWrite(ctx)
+	if name := readName(ctx, primary); name != "primary" {
+		t.Fatalf("reads after a write in the session must go to the primary, got %s", name)
+	}
+	if name := readName(context.Background(), primary); name != "replica" {
+		t.Fatalf("other sessions must keep reading the replica, got %s", name)
+	}
+	if name := readName(repository.WithPrimaryReads(context.Background()), primary); name != "primary" {
+		t.Fatalf("reads marked for the primary must go to the primary, got %s", name)
+	}
+}
@@ -0,0 +1,83 @@
+package db
+
+import (
+	"database/sql/driver"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/glebarez/go-sqlite"
+	gormsqlite "github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+
+	"hitalent-go-task/internal/config"
+)
+
+var registerSQLiteFunctions sync.Once
+
+// openSQLite opens a database given as sqlite:///absolute/path.db,
+// sqlite://relative/path.db or sqlite://:memory:.
+func openSQLite(databaseURL string, cfg config.DatabaseConfig, gormLogger logger.Interface) (*gorm.DB, error) {
+	var registerErr error
+	registerSQLiteFunctions.Do(func() {
+		// The built-in LOWER only folds ASCII. Overriding it keeps the
+		// case-insensitive sibling-name index equivalent to Postgres for
+		// non-Latin names as well.
+		registerErr = sqlite.RegisterDeterministicScalarFunction("lower", 1, unicodeLower)
+	})
+	if registerErr != nil {
+		return nil, fmt.Errorf("register sqlite functions: %w", registerErr)
+	}
+
+	path := strings.TrimPrefix(databaseURL, "sqlite://")
+	inMemory := strings.HasPrefix(path, ":memory:")
+
+	separator := "?"
+	if strings.Contains(path, "?") {
+		separator = "&"
+	}
+	dsn := path + separator + strings.Join([]string{
+		"_pragma=foreign_keys(1)",
+		"_pragma=busy_timeout(5000)",
+		"_pragma=journal_mode(WAL)",
+		"_txlock=immediate",
+		"_time_format=sqlite",
+	}, "&")
+
+	database, err := gorm.Open(gormsqlite.Open(dsn), &gorm.Config{
+		Logger: gormLogger,
+		NowFunc: func() time.Time {
+			return time.Now().UTC()
+		},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sqlDB, err := database.DB()
+	if err != nil {
+		return nil, err
+	}
+	if inMemory {
+		// Every connection to :memory: is a separate database, so its single
+		// connection must never be closed or recycled.
+		sqlDB.SetMaxOpenConns(1)
+	} else {
+		configurePool(sqlDB, cfg)
+	}
+
+	return database, nil
+}
+
+func unicodeLower(ctx *sqlite.FunctionContext, args []driver.Value) (driver.Value, error) {
+	switch value := args[0].(type) {
+	case string:
+		return strings.ToLower(value), nil
+	case []byte:
+		return strings.ToLower(string(value)), nil
+	default:
+		return value, nil
+	}
+}
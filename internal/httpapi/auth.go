@@ -0,0 +1,46 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+
+	"hitalent-go-task/internal/auth"
+)
+
+// WithAuthenticator requires every request to carry credentials accepted by authenticator.
+func WithAuthenticator(authenticator auth.Authenticator) Option {
+	return func(h *Handler) {
+		h.authenticator = authenticator
+	}
+}
+
+// authenticate attaches the principal to the request context. It writes 401 and
+// returns false when credentials are missing or invalid, or 429 once the client
+// IP has failed too often.
+func (h *Handler) authenticate(w http.ResponseWriter, r *http.Request) (*http.Request, bool) {
+	if h.authenticator == nil {
+		return r, true
+	}
+
+	principal, err := h.authenticator.Authenticate(r)
+	switch {
+	case err == nil:
+		return r.WithContext(auth.WithPrincipal(r.Context(), principal)), true
+	case errors.Is(err, auth.ErrNoCredentials):
+		if h.limitFailedAuthentication(w, r) {
+			return r, false
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api"`)
+		writeError(w, http.StatusUnauthorized, "authentication required")
+	case errors.Is(err, auth.ErrInvalidCredentials):
+		if h.limitFailedAuthentication(w, r) {
+			return r, false
+		}
+		w.Header().Set("WWW-Authenticate", `Bearer realm="api", error="invalid_token"`)
+		writeError(w, http.StatusUnauthorized, "invalid credentials")
+	default:
+		h.logger.ErrorContext(r.Context(), "authentication failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal server error")
+	}
+	return r, false
+}
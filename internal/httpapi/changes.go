@@ -0,0 +1,69 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hitalent-go-task/internal/service"
+)
+
+// WithChanges serves the change feed at /changes.
+func WithChanges(lister service.ChangeLister) Option {
+	return func(h *Handler) {
+		h.changes = lister
+	}
+}
+
+func (h *Handler) serveChanges(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) != 1 {
+		writeError(w, http.StatusNotFound, "route not found")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	options, err := parseListChangesOptions(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+	page, err := h.changes.ListChanges(r.Context(), options)
+	if err != nil {
+		h.respondWithError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, page)
+}
+
+func parseListChangesOptions(r *http.Request) (service.ListChangesOptions, error) {
+	query := r.URL.Query()
+	var options service.ListChangesOptions
+
+	if raw := strings.TrimSpace(query.Get("since")); raw != "" {
+		since, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return service.ListChangesOptions{}, errors.New("since must be a cursor returned by /changes")
+		}
+		cursor := uint(since)
+		options.Since = &cursor
+	}
+	if raw := strings.TrimSpace(query.Get("department_id")); raw != "" {
+		departmentID, err := parseUintID(raw)
+		if err != nil {
+			return service.ListChangesOptions{}, errors.New("department_id must be a positive integer")
+		}
+		options.DepartmentID = &departmentID
+	}
+	if raw := strings.TrimSpace(query.Get("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return service.ListChangesOptions{}, errors.New("limit must be a positive integer")
+		}
+		options.Limit = limit
+	}
+	return options, nil
+}
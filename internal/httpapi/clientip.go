@@ -0,0 +1,87 @@
+package httpapi
+
+import (
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// WithTrustedProxies names the reverse proxies whose Forwarded and
+// X-Forwarded-For headers identify the client. Without it the client is the
+// peer address of the connection.
+func WithTrustedProxies(proxies []netip.Prefix) Option {
+	return func(h *Handler) {
+		h.trustedProxies = proxies
+	}
+}
+
+// clientIP walks the forwarding chain from the nearest hop and returns the
+// first address that is not a trusted proxy. Headers are ignored unless the
+// peer itself is trusted, so clients cannot pick their own address.
+func (h *Handler) clientIP(r *http.Request) string {
+	peer, ok := parseHop(r.RemoteAddr)
+	if !ok {
+		host, _, err := net.SplitHostPort(r.RemoteAddr)
+		if err != nil {
+			return r.RemoteAddr
+		}
+		return host
+	}
+	client := peer
+	hops := forwardedFor(r.Header)
+	for i := len(hops) - 1; i >= 0 && h.trustedProxy(client); i-- {
+		hop, ok := parseHop(hops[i])
+		if !ok {
+			break
+		}
+		client = hop
+	}
+	return client.String()
+}
+
+func (h *Handler) trustedProxy(addr netip.Addr) bool {
+	for _, proxy := range h.trustedProxies {
+		if proxy.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// forwardedFor lists the client chain, farthest hop first. Forwarded (RFC 7239)
+// wins over X-Forwarded-For when both are present.
+func forwardedFor(header http.Header) []string {
+	var hops []string
+	for _, value := range header.Values("Forwarded") {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				name, value, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(name, "for") {
+					hops = append(hops, value)
+				}
+			}
+		}
+	}
+	if len(hops) > 0 {
+		return hops
+	}
+	for _, value := range header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(value, ",")...)
+	}
+	return hops
+}
+
+// parseHop accepts an address with or without a port, e.g. 192.0.2.1,
+// 192.0.2.1:4711 or "[2001:db8::1]:4711".
+func parseHop(raw string) (netip.Addr, bool) {
+	raw = strings.Trim(strings.TrimSpace(raw), `"`)
+	if addrPort, err := netip.ParseAddrPort(raw); err == nil {
+		return addrPort.Addr().Unmap(), true
+	}
+	addr, err := netip.ParseAddr(strings.TrimSuffix(strings.TrimPrefix(raw, "["), "]"))
+	if err != nil {
+		return netip.Addr{}, false
+	}
+	return addr.Unmap(), true
+}
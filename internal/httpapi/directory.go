@@ -0,0 +1,130 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hitalent-go-task/internal/ldif"
+	"hitalent-go-task/internal/service"
+)
+
+// WithLDIFExport serves the org structure as LDIF at /directory/ldif, with
+// departments as organizational units under baseDN and employees as persons.
+func WithLDIFExport(exporter service.DirectoryExporter, baseDN string) Option {
+	return func(h *Handler) {
+		h.exporter = exporter
+		h.baseDN = baseDN
+	}
+}
+
+func (h *Handler) serveDirectory(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) != 2 || parts[1] != "ldif" {
+		writeError(w, http.StatusNotFound, "route not found")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+
+	var departmentID *uint
+	if raw := strings.TrimSpace(r.URL.Query().Get("department_id")); raw != "" {
+		id, err := parseUintID(raw)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "department_id must be a positive integer")
+			return
+		}
+		departmentID = &id
+	}
+
+	// As with NDJSON, the status is committed with the first entry.
+	writer := ldif.NewWriter(w)
+	started := false
+	err := h.exporter.ExportDirectory(r.Context(), departmentID, func(node service.DirectoryNode) error {
+		if !started {
+			started = true
+			w.Header().Set("Content-Type", ldif.ContentType)
+			w.Header().Set("Content-Disposition", `attachment; filename="directory.ldif"`)
+			w.WriteHeader(http.StatusOK)
+		}
+		dn := departmentDN(node.Path, h.baseDN)
+		if err := writer.Write(departmentEntry(node.Department, dn)); err != nil {
+			return err
+		}
+		for _, employee := range node.Employees {
+			if employee.ErasedAt != nil {
+				continue
+			}
+			if err := writer.Write(employeeEntry(employee, node.Department, dn)); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	switch {
+	case err == nil:
+		if !started {
+			// Nothing to export: an empty file is still a valid LDIF.
+			w.Header().Set("Content-Type", ldif.ContentType)
+			w.WriteHeader(http.StatusOK)
+		}
+	case !started:
+		h.respondWithError(w, r, err)
+	default:
+		h.logger.WarnContext(r.Context(), "ldif export aborted", "error", err)
+	}
+}
+
+func directoryRouteTemplate(parts []string) string {
+	if len(parts) == 2 && parts[1] == "ldif" {
+		return "/directory/ldif"
+	}
+	return ""
+}
+
+// departmentDN lists the path from the department up to its top, as in
+// ou=Backend,ou=Engineering,dc=example,dc=com.
+func departmentDN(path []string, baseDN string) string {
+	rdns := make([]string, 0, len(path)+1)
+	for i := len(path) - 1; i >= 0; i-- {
+		rdns = append(rdns, ldif.RDN("ou", path[i]))
+	}
+	if baseDN != "" {
+		rdns = append(rdns, baseDN)
+	}
+	return strings.Join(rdns, ",")
+}
+
+func departmentEntry(department service.DepartmentDTO, dn string) ldif.Entry {
+	return ldif.Entry{DN: dn, Attributes: []ldif.Attribute{
+		{Name: "objectClass", Values: []string{"top", "organizationalUnit"}},
+		{Name: "ou", Values: []string{department.Name}},
+	}}
+}
+
+// employeeEntry names employees by id, which is stable across renames. The
+// name is required by the person class, so a masked one falls back to the id.
+func employeeEntry(employee service.EmployeeDTO, department service.DepartmentDTO, departmentDN string) ldif.Entry {
+	uid := strconv.FormatUint(uint64(employee.ID), 10)
+	name := employee.FullName
+	if name == "" {
+		name = uid
+	}
+	attributes := []ldif.Attribute{
+		{Name: "objectClass", Values: []string{"top", "person", "organizationalPerson", "inetOrgPerson"}},
+		{Name: "uid", Values: []string{uid}},
+		{Name: "cn", Values: []string{name}},
+		{Name: "sn", Values: []string{name}},
+		{Name: "displayName", Values: []string{name}},
+	}
+	if employee.Position != "" {
+		attributes = append(attributes, ldif.Attribute{Name: "title", Values: []string{employee.Position}})
+	}
+	attributes = append(attributes,
+		ldif.Attribute{Name: "ou", Values: []string{department.Name}},
+		ldif.Attribute{Name: "employeeNumber", Values: []string{uid}},
+		ldif.Attribute{Name: "departmentNumber", Values: []string{strconv.FormatUint(uint64(department.ID), 10)}},
+	)
+	return ldif.Entry{DN: ldif.RDN("uid", uid) + "," + departmentDN, Attributes: attributes}
+}
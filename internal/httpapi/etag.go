@@ -0,0 +1,89 @@
+package httpapi
+
+import (
+	"errors"
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"strconv"
+	"strings"
+
+	"hitalent-go-task/internal/service"
+)
+
+func formatETag(version int64) string {
+	return `"` + strconv.FormatInt(version, 10) + `"`
+}
+
+// treeETag tags a department tree. A department's version only changes with
+// the department itself, its direct children and its employees, so a tree
+// reaching deeper is tagged with a hash over the versions of every department
+// it contains, after the version of the root: "<version>.<hash>".
+func treeETag(tree service.DepartmentTree) string {
+	if len(tree.Children) == 0 {
+		return formatETag(tree.Department.Version)
+	}
+	digest := fnv.New64a()
+	hashTreeVersions(digest, tree)
+	return fmt.Sprintf(`"%d.%016x"`, tree.Department.Version, digest.Sum64())
+}
+
+func hashTreeVersions(digest hash.Hash64, tree service.DepartmentTree) {
+	fmt.Fprintf(digest, "%d:%d;", tree.Department.ID, tree.Department.Version)
+	for _, child := range tree.Children {
+		hashTreeVersions(digest, child)
+	}
+}
+
+// parseIfMatch returns the expected version from an If-Match header. A missing
+// header or "*" yields nil, meaning no version check. A tree tag only
+// contributes the version of its root: updates change the department itself.
+func parseIfMatch(raw string) (*int64, error) {
+	value := strings.TrimSpace(raw)
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+	if strings.Contains(value, ",") {
+		return nil, errors.New("If-Match must contain a single entity tag")
+	}
+	if strings.HasPrefix(value, "W/") {
+		return nil, errWeakETag
+	}
+
+	if root, _, found := strings.Cut(value, "."); found {
+		value = root + `"`
+	}
+	version, ok := parseETagVersion(value)
+	if !ok {
+		return nil, errors.New("If-Match must be a valid entity tag")
+	}
+	return &version, nil
+}
+
+// matchesIfNoneMatch implements weak comparison as required for If-None-Match.
+func matchesIfNoneMatch(raw string, etag string) bool {
+	for _, candidate := range strings.Split(raw, ",") {
+		candidate = strings.TrimSpace(candidate)
+		if candidate == "*" {
+			return true
+		}
+		candidate = strings.TrimPrefix(candidate, "W/")
+		if candidate == etag {
+			return true
+		}
+	}
+	return false
+}
+
+func parseETagVersion(value string) (int64, bool) {
+	if len(value) < 2 || !strings.HasPrefix(value, `"`) || !strings.HasSuffix(value, `"`) {
+		return 0, false
+	}
+	version, err := strconv.ParseInt(value[1:len(value)-1], 10, 64)
+	if err != nil || version < 1 {
+		return 0, false
+	}
+	return version, true
+}
+
+var errWeakETag = errors.New("weak entity tags never match If-Match")
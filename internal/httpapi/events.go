@@ -0,0 +1,188 @@
+package httpapi
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"hitalent-go-task/internal/outbox"
+	"hitalent-go-task/internal/service"
+)
+
+const (
+	lastEventIDHeader      = "Last-Event-ID"
+	defaultHeartbeat       = 15 * time.Second
+	eventStreamRetryMillis = 3000
+	eventStreamContentType = "text/event-stream"
+)
+
+// WithEvents serves the Server-Sent Events stream at /events/stream.
+func WithEvents(streamer service.EventStreamer) Option {
+	return func(h *Handler) {
+		h.events = streamer
+		h.heartbeat = defaultHeartbeat
+	}
+}
+
+func (h *Handler) serveEvents(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) != 2 || parts[1] != "stream" {
+		writeError(w, http.StatusNotFound, "route not found")
+		return
+	}
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	h.handleStreamEvents(w, r)
+}
+
+func eventRouteTemplate(parts []string) string {
+	if len(parts) == 2 && parts[1] == "stream" {
+		return "/events/stream"
+	}
+	return ""
+}
+
+// handleStreamEvents writes every event as an SSE message with the event id,
+// so that a reconnecting EventSource resumes by itself. Comments are sent as
+// heartbeats to keep idle proxies from closing the connection.
+func (h *Handler) handleStreamEvents(w http.ResponseWriter, r *http.Request) {
+	options, err := parseStreamEventsOptions(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	stream, err := h.events.OpenEventStream(r.Context(), options)
+	if errors.Is(err, outbox.ErrFeedClosed) {
+		writeError(w, http.StatusServiceUnavailable, "server is shutting down")
+		return
+	}
+	if err != nil {
+		h.respondWithError(w, r, err)
+		return
+	}
+	defer stream.Close()
+
+	// Next blocks, so it runs apart from the heartbeats; only this goroutine
+	// writes the response.
+	ctx, cancel := context.WithCancel(r.Context())
+	events := make(chan outbox.Event)
+	var streamErr error
+	go func() {
+		defer close(events)
+		for {
+			event, err := stream.Next(ctx)
+			if err != nil {
+				streamErr = err
+				return
+			}
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				streamErr = ctx.Err()
+				return
+			}
+		}
+	}()
+	defer func() {
+		cancel()
+		for range events {
+		}
+	}()
+
+	header := w.Header()
+	header.Set("Content-Type", eventStreamContentType)
+	header.Set("Cache-Control", "no-cache")
+	header.Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	controller := http.NewResponseController(w)
+	// The stream is long-lived; the server write timeout is for responses.
+	_ = controller.SetWriteDeadline(time.Time{})
+	if _, err := fmt.Fprintf(w, "retry: %d\n\n", eventStreamRetryMillis); err != nil {
+		return
+	}
+	if err := controller.Flush(); err != nil {
+		h.logger.ErrorContext(r.Context(), "event stream not supported", "error", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(h.heartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case event, ok := <-events:
+			if !ok {
+				h.logStreamEnd(r, streamErr)
+				return
+			}
+			if err := writeEvent(w, event); err != nil {
+				return
+			}
+		case <-heartbeat.C:
+			if _, err := fmt.Fprint(w, ": heartbeat\n\n"); err != nil {
+				return
+			}
+		}
+		if err := controller.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+func (h *Handler) logStreamEnd(r *http.Request, err error) {
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, outbox.ErrFeedClosed):
+	case errors.Is(err, outbox.ErrSubscriberTooSlow):
+		h.logger.WarnContext(r.Context(), "event stream client too slow, disconnected")
+	default:
+		h.logger.ErrorContext(r.Context(), "event stream failed", "error", err)
+	}
+}
+
+// writeEvent writes one SSE message; the data is the outbox envelope, which
+// never contains a newline. The SSE id is the feed position, the resume point,
+// rather than the event id, which does not follow the commit order.
+func writeEvent(w http.ResponseWriter, event outbox.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.Position, event.Type, data)
+	return err
+}
+
+// parseStreamEventsOptions reads the resume point from the Last-Event-ID
+// header that EventSource sends on reconnect, or from last_event_id for the
+// first connection, where EventSource cannot set headers.
+func parseStreamEventsOptions(r *http.Request) (service.StreamEventsOptions, error) {
+	query := r.URL.Query()
+	var options service.StreamEventsOptions
+
+	if raw := strings.TrimSpace(query.Get("department_id")); raw != "" {
+		departmentID, err := parseUintID(raw)
+		if err != nil {
+			return service.StreamEventsOptions{}, errors.New("department_id must be a positive integer")
+		}
+		options.DepartmentID = &departmentID
+	}
+
+	raw := strings.TrimSpace(r.Header.Get(lastEventIDHeader))
+	if raw == "" {
+		raw = strings.TrimSpace(query.Get("last_event_id"))
+	}
+	if raw != "" {
+		id64, err := strconv.ParseUint(raw, 10, 64)
+		if err != nil {
+			return service.StreamEventsOptions{}, errors.New("last event id must be a non-negative integer")
+		}
+		lastEventID := uint(id64)
+		options.LastEventID = &lastEventID
+	}
+	return options, nil
+}
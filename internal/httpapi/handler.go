@@ -5,42 +5,93 @@ import (
 	"encoding/json"
 	"errors"
 	"io"
-	"log"
+	"log/slog"
 	"net/http"
+	"net/netip"
 	"strconv"
 	"strings"
 	"time"
 
 	"hitalent-go-task/internal/apperror"
+	"hitalent-go-task/internal/auth"
+	"hitalent-go-task/internal/metrics"
 	"hitalent-go-task/internal/service"
+	"hitalent-go-task/internal/tracing"
 )
 
 type Handler struct {
-	service service.Manager
-	logger  *log.Logger
+	service        service.Manager
+	logger         *slog.Logger
+	idempotency    *idempotencyConfig
+	authenticator  auth.Authenticator
+	webhooks       service.WebhookManager
+	events         service.EventStreamer
+	changes        service.ChangeLister
+	directory      service.Directory
+	exporter       service.DirectoryExporter
+	baseDN         string
+	heartbeat      time.Duration
+	limits         *rateLimits
+	trustedProxies []netip.Prefix
 }
 
-func NewHandler(svc service.Manager, logger *log.Logger) *Handler {
-	return &Handler{
+type Option func(*Handler)
+
+func NewHandler(svc service.Manager, logger *slog.Logger, opts ...Option) *Handler {
+	h := &Handler{
 		service: svc,
 		logger:  logger,
 	}
+	for _, opt := range opts {
+		opt(h)
+	}
+	return h
 }
 
 func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
-	if len(parts) == 0 || parts[0] != "departments" {
+	if !h.serves(parts[0]) {
 		writeError(w, http.StatusNotFound, "route not found")
 		return
 	}
 
+	if route := routeTemplate(parts); route != "" {
+		metrics.SetRoute(r.Context(), route)
+		tracing.SetRoute(r.Context(), r.Method, route)
+	}
+
+	r, ok := h.authenticate(w, r)
+	if !ok {
+		return
+	}
+	if !h.allowRequest(w, r) {
+		return
+	}
+	switch parts[0] {
+	case "webhooks":
+		h.serveWebhooks(w, r, parts)
+		return
+	case "events":
+		h.serveEvents(w, r, parts)
+		return
+	case "changes":
+		h.serveChanges(w, r, parts)
+		return
+	case "scim":
+		h.serveSCIM(w, r, parts)
+		return
+	case "directory":
+		h.serveDirectory(w, r, parts)
+		return
+	}
+
 	switch {
 	case len(parts) == 1:
 		if r.Method != http.MethodPost {
 			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
 			return
 		}
-		h.handleCreateDepartment(w, r)
+		h.serveIdempotent(w, r, h.handleCreateDepartment)
 		return
 
 	case len(parts) == 2:
@@ -74,13 +125,87 @@ func (h *Handler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		h.handleCreateEmployee(w, r, departmentID)
+		h.serveIdempotent(w, r, func(w http.ResponseWriter, r *http.Request) {
+			h.handleCreateEmployee(w, r, departmentID)
+		})
+		return
+
+	case len(parts) == 5 && parts[2] == "employees" && parts[4] == "erase":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+
+		departmentID, err := parseUintID(parts[1])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid department id")
+			return
+		}
+		employeeID, err := parseUintID(parts[3])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid employee id")
+			return
+		}
+
+		h.serveIdempotent(w, r, func(w http.ResponseWriter, r *http.Request) {
+			h.handleEraseEmployee(w, r, departmentID, employeeID)
+		})
 		return
 	}
 
 	writeError(w, http.StatusNotFound, "route not found")
 }
 
+// serves reports whether the top-level path segment is handled; the optional
+// endpoints only exist when configured.
+func (h *Handler) serves(resource string) bool {
+	switch resource {
+	case "departments":
+		return true
+	case "webhooks":
+		return h.webhooks != nil
+	case "events":
+		return h.events != nil
+	case "changes":
+		return h.changes != nil
+	case "scim":
+		return h.directory != nil
+	case "directory":
+		return h.exporter != nil
+	}
+	return false
+}
+
+// routeTemplate names the route for metrics; it mirrors the switch in ServeHTTP.
+func routeTemplate(parts []string) string {
+	switch parts[0] {
+	case "webhooks":
+		return webhookRouteTemplate(parts)
+	case "events":
+		return eventRouteTemplate(parts)
+	case "changes":
+		if len(parts) == 1 {
+			return "/changes"
+		}
+		return ""
+	case "scim":
+		return scimRouteTemplate(parts)
+	case "directory":
+		return directoryRouteTemplate(parts)
+	}
+	switch {
+	case len(parts) == 1:
+		return "/departments"
+	case len(parts) == 2:
+		return "/departments/{id}"
+	case len(parts) == 3 && parts[2] == "employees":
+		return "/departments/{id}/employees"
+	case len(parts) == 5 && parts[2] == "employees" && parts[4] == "erase":
+		return "/departments/{id}/employees/{employee_id}/erase"
+	}
+	return ""
+}
+
 type createDepartmentRequest struct {
 	Name     string `json:"name"`
 	ParentID *uint  `json:"parent_id"`
@@ -109,10 +234,12 @@ func (h *Handler) handleCreateDepartment(w http.ResponseWriter, r *http.Request)
 		ParentID: req.ParentID,
 	})
 	if err != nil {
-		h.respondWithError(w, err)
+		h.respondWithError(w, r, err)
 		return
 	}
 
+	w.Header().Set("Location", "/departments/"+strconv.FormatUint(uint64(department.ID), 10))
+	w.Header().Set("ETag", formatETag(department.Version))
 	writeJSON(w, http.StatusCreated, department)
 }
 
@@ -135,7 +262,7 @@ func (h *Handler) handleCreateEmployee(w http.ResponseWriter, r *http.Request, d
 		HiredAt:  hiredAt,
 	})
 	if err != nil {
-		h.respondWithError(w, err)
+		h.respondWithError(w, r, err)
 		return
 	}
 
@@ -149,16 +276,75 @@ func (h *Handler) handleGetDepartment(w http.ResponseWriter, r *http.Request, de
 		return
 	}
 
+	w.Header().Set("Vary", "Accept")
+	if acceptsNDJSON(r) {
+		h.streamDepartment(w, r, departmentID, options)
+		return
+	}
+
 	response, err := h.service.GetDepartment(r.Context(), departmentID, options)
 	if err != nil {
-		h.respondWithError(w, err)
+		h.respondWithError(w, r, err)
+		return
+	}
+
+	etag := treeETag(response)
+	w.Header().Set("ETag", etag)
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && matchesIfNoneMatch(ifNoneMatch, etag) {
+		w.WriteHeader(http.StatusNotModified)
 		return
 	}
 
 	writeJSON(w, http.StatusOK, response)
 }
 
+// errNotModified stops the stream after a matching If-None-Match.
+var errNotModified = errors.New("not modified")
+
+// streamDepartment writes the subtree as NDJSON, one node per line. Once the
+// first line is written the status is committed, so later errors only abort
+// the stream.
+func (h *Handler) streamDepartment(w http.ResponseWriter, r *http.Request, departmentID uint, options service.GetDepartmentOptions) {
+	encoder := json.NewEncoder(w)
+	started := false
+	err := h.service.StreamDepartment(r.Context(), departmentID, options, func(node service.DepartmentNode) error {
+		if !started {
+			started = true
+			// The tag of a deeper subtree is only known once it has been
+			// written, so only the root alone is tagged.
+			if options.Depth == 0 {
+				etag := formatETag(node.Department.Version)
+				w.Header().Set("ETag", etag)
+				if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && matchesIfNoneMatch(ifNoneMatch, etag) {
+					w.WriteHeader(http.StatusNotModified)
+					return errNotModified
+				}
+			}
+			w.Header().Set("Content-Type", ndjsonContentType)
+			w.WriteHeader(http.StatusOK)
+		}
+		return encoder.Encode(node)
+	})
+	switch {
+	case err == nil, errors.Is(err, errNotModified):
+	case !started:
+		h.respondWithError(w, r, err)
+	default:
+		h.logger.WarnContext(r.Context(), "department stream aborted", "department_id", departmentID, "error", err)
+	}
+}
+
 func (h *Handler) handleUpdateDepartment(w http.ResponseWriter, r *http.Request, departmentID uint) {
+	expectedVersion, err := parseIfMatch(r.Header.Get("If-Match"))
+	if errors.Is(err, errWeakETag) {
+		writeError(w, http.StatusPreconditionFailed, err.Error())
+		return
+	}
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
 	var req updateDepartmentRequest
 	if err := decodeJSON(r, &req); err != nil {
 		writeError(w, http.StatusBadRequest, err.Error())
@@ -166,15 +352,17 @@ func (h *Handler) handleUpdateDepartment(w http.ResponseWriter, r *http.Request,
 	}
 
 	updatedDepartment, err := h.service.UpdateDepartment(r.Context(), departmentID, service.UpdateDepartmentInput{
-		Name:        req.Name,
-		ParentIDSet: req.ParentID.Set,
-		ParentID:    req.ParentID.Value,
+		Name:            req.Name,
+		ParentIDSet:     req.ParentID.Set,
+		ParentID:        req.ParentID.Value,
+		ExpectedVersion: expectedVersion,
 	})
 	if err != nil {
-		h.respondWithError(w, err)
+		h.respondWithError(w, r, err)
 		return
 	}
 
+	w.Header().Set("ETag", formatETag(updatedDepartment.Version))
 	writeJSON(w, http.StatusOK, updatedDepartment)
 }
 
@@ -187,14 +375,24 @@ func (h *Handler) handleDeleteDepartment(w http.ResponseWriter, r *http.Request,
 	}
 
 	if err := h.service.DeleteDepartment(r.Context(), departmentID, mode, reassignToDepartmentID); err != nil {
-		h.respondWithError(w, err)
+		h.respondWithError(w, r, err)
 		return
 	}
 
 	w.WriteHeader(http.StatusNoContent)
 }
 
-func (h *Handler) respondWithError(w http.ResponseWriter, err error) {
+func (h *Handler) handleEraseEmployee(w http.ResponseWriter, r *http.Request, departmentID uint, employeeID uint) {
+	employee, err := h.service.EraseEmployee(r.Context(), departmentID, employeeID)
+	if err != nil {
+		h.respondWithError(w, r, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, employee)
+}
+
+func (h *Handler) respondWithError(w http.ResponseWriter, r *http.Request, err error) {
 	switch apperror.GetCode(err) {
 	case apperror.CodeValidation:
 		writeError(w, http.StatusBadRequest, err.Error())
@@ -202,8 +400,14 @@ func (h *Handler) respondWithError(w http.ResponseWriter, err error) {
 		writeError(w, http.StatusNotFound, err.Error())
 	case apperror.CodeConflict:
 		writeError(w, http.StatusConflict, err.Error())
+	case apperror.CodePreconditionFailed:
+		writeError(w, http.StatusPreconditionFailed, err.Error())
+	case apperror.CodeForbidden:
+		writeError(w, http.StatusForbidden, err.Error())
+	case apperror.CodeGone:
+		writeError(w, http.StatusGone, err.Error())
 	default:
-		h.logger.Printf("unexpected error: %v", err)
+		h.logger.ErrorContext(r.Context(), "unexpected error", "error", err)
 		writeError(w, http.StatusInternalServerError, "internal server error")
 	}
 }
@@ -251,14 +455,15 @@ func parseGetDepartmentOptions(r *http.Request) (service.GetDepartmentOptions, e
 
 	depth := 1
 	if rawDepth := strings.TrimSpace(query.Get("depth")); rawDepth != "" {
-		parsedDepth, err := strconv.Atoi(rawDepth)
-		if err != nil {
-			return service.GetDepartmentOptions{}, errors.New("depth must be an integer")
+		if strings.EqualFold(rawDepth, "all") {
+			depth = service.DepthAll
+		} else {
+			parsedDepth, err := strconv.Atoi(rawDepth)
+			if err != nil || parsedDepth < 0 {
+				return service.GetDepartmentOptions{}, errors.New("depth must be a non-negative integer or all")
+			}
+			depth = parsedDepth
 		}
-		if parsedDepth < 0 || parsedDepth > 5 {
-			return service.GetDepartmentOptions{}, errors.New("depth must be between 0 and 5")
-		}
-		depth = parsedDepth
 	}
 
 	includeEmployees := true
@@ -276,6 +481,20 @@ func parseGetDepartmentOptions(r *http.Request) (service.GetDepartmentOptions, e
 	}, nil
 }
 
+const ndjsonContentType = "application/x-ndjson"
+
+func acceptsNDJSON(r *http.Request) bool {
+	for _, accept := range r.Header.Values("Accept") {
+		for _, mediaRange := range strings.Split(accept, ",") {
+			mediaType, _, _ := strings.Cut(mediaRange, ";")
+			if strings.EqualFold(strings.TrimSpace(mediaType), ndjsonContentType) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
 func parseOptionalReassignDepartmentID(raw string) (*uint, error) {
 	value := strings.TrimSpace(raw)
 	if value == "" {
@@ -4,13 +4,24 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
-	"io"
-	"log"
+	"fmt"
+	"log/slog"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/golang-jwt/jwt/v5"
+
+	"hitalent-go-task/internal/apperror"
+	"hitalent-go-task/internal/auth"
+	"hitalent-go-task/internal/idempotency"
+	"hitalent-go-task/internal/metrics"
+	"hitalent-go-task/internal/ratelimit"
+	"hitalent-go-task/internal/repository/memory"
 	"hitalent-go-task/internal/service"
 )
 
@@ -18,8 +29,10 @@ type stubService struct {
 	createDepartmentFn func(ctx context.Context, input service.CreateDepartmentInput) (service.DepartmentDTO, error)
 	createEmployeeFn   func(ctx context.Context, departmentID uint, input service.CreateEmployeeInput) (service.EmployeeDTO, error)
 	getDepartmentFn    func(ctx context.Context, departmentID uint, options service.GetDepartmentOptions) (service.DepartmentTree, error)
+	streamDepartmentFn func(ctx context.Context, departmentID uint, options service.GetDepartmentOptions, emit func(service.DepartmentNode) error) error
 	updateDepartmentFn func(ctx context.Context, departmentID uint, input service.UpdateDepartmentInput) (service.DepartmentDTO, error)
 	deleteDepartmentFn func(ctx context.Context, departmentID uint, mode service.DeleteMode, reassignToDepartmentID *uint) error
+	eraseEmployeeFn    func(ctx context.Context, departmentID uint, employeeID uint) (service.EmployeeDTO, error)
 }
 
 func (s stubService) CreateDepartment(ctx context.Context, input service.CreateDepartmentInput) (service.DepartmentDTO, error) {
@@ -43,6 +56,13 @@ func (s stubService) GetDepartment(ctx context.Context, departmentID uint, optio
 	return s.getDepartmentFn(ctx, departmentID, options)
 }
 
+func (s stubService) StreamDepartment(ctx context.Context, departmentID uint, options service.GetDepartmentOptions, emit func(service.DepartmentNode) error) error {
+	if s.streamDepartmentFn == nil {
+		return nil
+	}
+	return s.streamDepartmentFn(ctx, departmentID, options, emit)
+}
+
 func (s stubService) UpdateDepartment(ctx context.Context, departmentID uint, input service.UpdateDepartmentInput) (service.DepartmentDTO, error) {
 	if s.updateDepartmentFn == nil {
 		return service.DepartmentDTO{}, nil
@@ -57,6 +77,13 @@ func (s stubService) DeleteDepartment(ctx context.Context, departmentID uint, mo
 	return s.deleteDepartmentFn(ctx, departmentID, mode, reassignToDepartmentID)
 }
 
+func (s stubService) EraseEmployee(ctx context.Context, departmentID uint, employeeID uint) (service.EmployeeDTO, error) {
+	if s.eraseEmployeeFn == nil {
+		return service.EmployeeDTO{}, nil
+	}
+	return s.eraseEmployeeFn(ctx, departmentID, employeeID)
+}
+
 func TestCreateDepartment(t *testing.T) {
 	handler := NewHandler(stubService{
 		createDepartmentFn: func(ctx context.Context, input service.CreateDepartmentInput) (service.DepartmentDTO, error) {
@@ -70,7 +97,7 @@ func TestCreateDepartment(t *testing.T) {
 				CreatedAt: time.Now(),
 			}, nil
 		},
-	}, log.New(io.Discard, "", 0))
+	}, slog.New(slog.DiscardHandler))
 
 	body := bytes.NewBufferString(`{"name":"Backend"}`)
 	req := httptest.NewRequest(http.MethodPost, "/departments", body)
@@ -93,14 +120,902 @@ func TestCreateDepartment(t *testing.T) {
 }
 
 func TestGetDepartmentDepthValidation(t *testing.T) {
-	handler := NewHandler(stubService{}, log.New(io.Discard, "", 0))
+	handler := NewHandler(stubService{}, slog.New(slog.DiscardHandler))
+
+	for _, depth := range []string{"-1", "deep"} {
+		req := httptest.NewRequest(http.MethodGet, "/departments/1?depth="+depth, nil)
+		recorder := httptest.NewRecorder()
+
+		handler.ServeHTTP(recorder, req)
+
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("depth=%s: expected status %d, got %d", depth, http.StatusBadRequest, recorder.Code)
+		}
+	}
+}
+
+func TestGetDepartmentStreamsNDJSON(t *testing.T) {
+	parentID := uint(1)
+	handler := NewHandler(stubService{
+		streamDepartmentFn: func(ctx context.Context, departmentID uint, options service.GetDepartmentOptions, emit func(service.DepartmentNode) error) error {
+			if options.Depth != service.DepthAll {
+				t.Fatalf("expected depth=all, got %d", options.Depth)
+			}
+			if err := emit(service.DepartmentNode{Department: service.DepartmentDTO{ID: 1, Name: "Engineering", Version: 3}}); err != nil {
+				return err
+			}
+			return emit(service.DepartmentNode{Department: service.DepartmentDTO{ID: 2, Name: "Backend", ParentID: &parentID}, Depth: 1})
+		},
+	}, slog.New(slog.DiscardHandler))
 
-	req := httptest.NewRequest(http.MethodGet, "/departments/1?depth=6", nil)
+	req := httptest.NewRequest(http.MethodGet, "/departments/1?depth=all", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
 	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if contentType := recorder.Header().Get("Content-Type"); contentType != "application/x-ndjson" {
+		t.Fatalf("expected NDJSON content type, got %q", contentType)
+	}
+	if etag := recorder.Header().Get("ETag"); etag != "" {
+		t.Fatalf("expected no ETag for a subtree stream, got %q", etag)
+	}
 
+	decoder := json.NewDecoder(recorder.Body)
+	var nodes []service.DepartmentNode
+	for decoder.More() {
+		var node service.DepartmentNode
+		if err := decoder.Decode(&node); err != nil {
+			t.Fatalf("decode line: %v", err)
+		}
+		nodes = append(nodes, node)
+	}
+	if len(nodes) != 2 || nodes[1].Department.ParentID == nil || *nodes[1].Department.ParentID != 1 {
+		t.Fatalf("unexpected nodes: %+v", nodes)
+	}
+}
+
+func TestGetDepartmentStreamErrorBeforeFirstLine(t *testing.T) {
+	handler := NewHandler(stubService{
+		streamDepartmentFn: func(ctx context.Context, departmentID uint, options service.GetDepartmentOptions, emit func(service.DepartmentNode) error) error {
+			return apperror.New(apperror.CodeValidation, "depth must be between 0 and 5")
+		},
+	}, slog.New(slog.DiscardHandler))
+
+	req := httptest.NewRequest(http.MethodGet, "/departments/1?depth=10", nil)
+	req.Header.Set("Accept", "application/x-ndjson")
+	recorder := httptest.NewRecorder()
 	handler.ServeHTTP(recorder, req)
 
 	if recorder.Code != http.StatusBadRequest {
 		t.Fatalf("expected status %d, got %d", http.StatusBadRequest, recorder.Code)
 	}
 }
+
+func TestCreateEmployeeIdempotencyReplay(t *testing.T) {
+	calls := 0
+	handler := NewHandler(stubService{
+		createEmployeeFn: func(ctx context.Context, departmentID uint, input service.CreateEmployeeInput) (service.EmployeeDTO, error) {
+			calls++
+			return service.EmployeeDTO{
+				ID:           uint(calls),
+				DepartmentID: departmentID,
+				FullName:     input.FullName,
+				Position:     input.Position,
+			}, nil
+		},
+	}, slog.New(slog.DiscardHandler), WithIdempotency(idempotency.NewMemoryStore(), time.Hour, 0))
+
+	send := func(body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/departments/1/employees", bytes.NewBufferString(body))
+		req.Header.Set("Idempotency-Key", "import-42")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	first := send(`{"full_name":"Ivan Petrov","position":"Developer"}`)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d", http.StatusCreated, first.Code)
+	}
+
+	second := send(`{"full_name":"Ivan Petrov","position":"Developer"}`)
+	if second.Code != http.StatusCreated {
+		t.Fatalf("expected replayed status %d, got %d", http.StatusCreated, second.Code)
+	}
+	if second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected Idempotent-Replayed header on replay")
+	}
+	if second.Body.String() != first.Body.String() {
+		t.Fatalf("expected replayed body %q, got %q", first.Body.String(), second.Body.String())
+	}
+	if calls != 1 {
+		t.Fatalf("expected service to be called once, got %d", calls)
+	}
+
+	mismatch := send(`{"full_name":"Petr Ivanov","position":"Developer"}`)
+	if mismatch.Code != http.StatusUnprocessableEntity {
+		t.Fatalf("expected status %d, got %d", http.StatusUnprocessableEntity, mismatch.Code)
+	}
+}
+
+func TestCreateDepartmentIdempotencyRecovers(t *testing.T) {
+	calls := 0
+	panics := true
+	store := idempotency.NewMemoryStore()
+	handler := NewHandler(stubService{
+		createDepartmentFn: func(ctx context.Context, input service.CreateDepartmentInput) (service.DepartmentDTO, error) {
+			calls++
+			if panics {
+				panics = false
+				panic("boom")
+			}
+			return service.DepartmentDTO{ID: uint(calls), Name: input.Name, Version: 1}, nil
+		},
+	}, slog.New(slog.DiscardHandler), WithIdempotency(store, time.Hour, 0))
+
+	send := func(ctx context.Context, key string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/departments", strings.NewReader(`{"name":"Backend"}`)).WithContext(ctx)
+		req.Header.Set("Idempotency-Key", key)
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	// A panic releases the key instead of leaving it in progress.
+	func() {
+		defer func() { _ = recover() }()
+		send(context.Background(), "panic")
+	}()
+	if recorder := send(context.Background(), "panic"); recorder.Code != http.StatusCreated || calls != 2 {
+		t.Fatalf("expected the retry to run, got %d after %d calls", recorder.Code, calls)
+	}
+
+	// The client going away after the commit still stores the response,
+	// with the headers.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	send(ctx, "gone")
+	replayed := send(context.Background(), "gone")
+	if replayed.Header().Get("Idempotent-Replayed") != "true" || replayed.Header().Get("Location") != "/departments/3" || replayed.Header().Get("ETag") != `"1"` {
+		t.Fatalf("expected a replay with Location and ETag, got %d %v", replayed.Code, replayed.Header())
+	}
+
+	// A reservation whose server stopped is taken over once its lease passed.
+	now := time.Now()
+	if _, _, err := store.Reserve(context.Background(), "stale", "fingerprint", now.Add(time.Hour), now.Add(-time.Second)); err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	if recorder := send(context.Background(), "stale"); recorder.Code != http.StatusCreated {
+		t.Fatalf("expected the stale reservation to be taken over, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	body := `{"name":"Backend"}`
+	fingerprint := requestFingerprint(httptest.NewRequest(http.MethodPost, "/departments", nil), []byte(body))
+	if _, _, err := store.Reserve(context.Background(), "running", fingerprint, now.Add(time.Hour), now.Add(time.Minute)); err != nil {
+		t.Fatalf("reserve: %v", err)
+	}
+	if recorder := send(context.Background(), "running"); recorder.Code != http.StatusConflict {
+		t.Fatalf("expected a running reservation to be kept, got %d", recorder.Code)
+	}
+}
+
+func TestIdempotencyKeysArePerPrincipal(t *testing.T) {
+	keys := auth.NewMemoryAPIKeyStore()
+	var apiKeys []string
+	for _, name := range []string{"importer", "sync"} {
+		key, keyHash, err := auth.GenerateAPIKey()
+		if err != nil {
+			t.Fatalf("generate api key: %v", err)
+		}
+		if _, err := keys.Create(context.Background(), auth.APIKey{Name: name, Role: auth.RoleEditor}, keyHash); err != nil {
+			t.Fatalf("create api key: %v", err)
+		}
+		apiKeys = append(apiKeys, key)
+	}
+	calls := 0
+	handler := NewHandler(stubService{
+		createDepartmentFn: func(ctx context.Context, input service.CreateDepartmentInput) (service.DepartmentDTO, error) {
+			calls++
+			return service.DepartmentDTO{ID: uint(calls), Name: input.Name, Version: 1}, nil
+		},
+	}, slog.New(slog.DiscardHandler),
+		WithAuthenticator(auth.NewAPIKeyAuthenticator(keys)),
+		WithIdempotency(idempotency.NewMemoryStore(), time.Hour, 0))
+
+	for _, key := range apiKeys {
+		req := httptest.NewRequest(http.MethodPost, "/departments", strings.NewReader(`{"name":"Backend"}`))
+		req.Header.Set("X-API-Key", key)
+		req.Header.Set("Idempotency-Key", "import-42")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		if recorder.Code != http.StatusCreated || recorder.Header().Get("Idempotent-Replayed") != "" {
+			t.Fatalf("expected a fresh response, got %d: %s", recorder.Code, recorder.Body.String())
+		}
+	}
+	if calls != 2 {
+		t.Fatalf("expected each principal's request to run, got %d calls", calls)
+	}
+}
+
+func TestIdempotencyLeaseLost(t *testing.T) {
+	store := idempotency.NewMemoryStore()
+	body := `{"name":"Backend"}`
+	handler := NewHandler(stubService{
+		createDepartmentFn: func(ctx context.Context, input service.CreateDepartmentInput) (service.DepartmentDTO, error) {
+			// The request runs past its lease and a retry takes the key over.
+			<-ctx.Done()
+			fingerprint := requestFingerprint(httptest.NewRequest(http.MethodPost, "/departments", nil), []byte(body))
+			if _, reserved, err := store.Reserve(context.Background(), "slow", fingerprint, time.Now().Add(time.Hour), time.Now().Add(time.Minute)); err != nil || !reserved {
+				t.Errorf("expected the retry to take the key over: %v", err)
+			}
+			return service.DepartmentDTO{ID: 1, Name: input.Name, Version: 1}, nil
+		},
+	}, slog.New(slog.DiscardHandler), WithIdempotency(store, time.Hour, 10*time.Millisecond))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/departments", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", "slow")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	send()
+	if recorder := send(); recorder.Code != http.StatusConflict {
+		t.Fatalf("expected the retry's reservation to be kept, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+}
+
+func TestUpdateDepartmentIfMatch(t *testing.T) {
+	handler := NewHandler(stubService{
+		updateDepartmentFn: func(ctx context.Context, departmentID uint, input service.UpdateDepartmentInput) (service.DepartmentDTO, error) {
+			if input.ExpectedVersion == nil || *input.ExpectedVersion != 3 {
+				t.Fatalf("expected version 3, got %v", input.ExpectedVersion)
+			}
+			return service.DepartmentDTO{ID: departmentID, Name: "Platform", Version: 4}, nil
+		},
+	}, slog.New(slog.DiscardHandler))
+
+	req := httptest.NewRequest(http.MethodPatch, "/departments/1", bytes.NewBufferString(`{"name":"Platform"}`))
+	req.Header.Set("If-Match", `"3"`)
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, recorder.Code)
+	}
+	if etag := recorder.Header().Get("ETag"); etag != `"4"` {
+		t.Fatalf("expected ETag %q, got %q", `"4"`, etag)
+	}
+}
+
+func TestUpdateDepartmentVersionMismatch(t *testing.T) {
+	handler := NewHandler(stubService{
+		updateDepartmentFn: func(ctx context.Context, departmentID uint, input service.UpdateDepartmentInput) (service.DepartmentDTO, error) {
+			return service.DepartmentDTO{}, apperror.New(apperror.CodePreconditionFailed, "department was modified by another request")
+		},
+	}, slog.New(slog.DiscardHandler))
+
+	req := httptest.NewRequest(http.MethodPatch, "/departments/1", bytes.NewBufferString(`{"name":"Platform"}`))
+	req.Header.Set("If-Match", `"2"`)
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected status %d, got %d", http.StatusPreconditionFailed, recorder.Code)
+	}
+}
+
+func TestGetDepartmentIfNoneMatch(t *testing.T) {
+	handler := NewHandler(stubService{
+		getDepartmentFn: func(ctx context.Context, departmentID uint, options service.GetDepartmentOptions) (service.DepartmentTree, error) {
+			return service.DepartmentTree{
+				Department: service.DepartmentDTO{ID: departmentID, Name: "Backend", Version: 7},
+				Children:   []service.DepartmentTree{},
+			}, nil
+		},
+	}, slog.New(slog.DiscardHandler))
+
+	req := httptest.NewRequest(http.MethodGet, "/departments/1", nil)
+	req.Header.Set("If-None-Match", `W/"6", "7"`)
+	recorder := httptest.NewRecorder()
+
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusNotModified {
+		t.Fatalf("expected status %d, got %d", http.StatusNotModified, recorder.Code)
+	}
+	if recorder.Body.Len() != 0 {
+		t.Fatalf("expected empty body, got %q", recorder.Body.String())
+	}
+}
+
+func TestGetDepartmentTreeETag(t *testing.T) {
+	grandchildVersion := int64(1)
+	handler := NewHandler(stubService{
+		getDepartmentFn: func(ctx context.Context, departmentID uint, options service.GetDepartmentOptions) (service.DepartmentTree, error) {
+			return service.DepartmentTree{
+				Department: service.DepartmentDTO{ID: departmentID, Name: "Engineering", Version: 4},
+				Children: []service.DepartmentTree{{
+					Department: service.DepartmentDTO{ID: 2, Name: "Backend", Version: 2},
+					Children: []service.DepartmentTree{{
+						Department: service.DepartmentDTO{ID: 3, Name: "Go", Version: grandchildVersion},
+						Children:   []service.DepartmentTree{},
+					}},
+				}},
+			}, nil
+		},
+		updateDepartmentFn: func(ctx context.Context, departmentID uint, input service.UpdateDepartmentInput) (service.DepartmentDTO, error) {
+			if input.ExpectedVersion == nil || *input.ExpectedVersion != 4 {
+				t.Fatalf("expected version 4 from the tree tag, got %v", input.ExpectedVersion)
+			}
+			return service.DepartmentDTO{ID: departmentID, Name: "Platform", Version: 5}, nil
+		},
+	}, slog.New(slog.DiscardHandler))
+
+	get := func() string {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/departments/1?depth=all", nil))
+		if recorder.Code != http.StatusOK {
+			t.Fatalf("expected status %d, got %d", http.StatusOK, recorder.Code)
+		}
+		return recorder.Header().Get("ETag")
+	}
+
+	before := get()
+	if !strings.HasPrefix(before, `"4.`) {
+		t.Fatalf("expected a tree tag of version 4, got %q", before)
+	}
+	grandchildVersion++
+	if after := get(); after == before {
+		t.Fatalf("expected the tag to change with a grandchild, got %q twice", after)
+	}
+
+	req := httptest.NewRequest(http.MethodPatch, "/departments/1", strings.NewReader(`{"name":"Platform"}`))
+	req.Header.Set("If-Match", before)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, recorder.Code, recorder.Body.String())
+	}
+}
+
+type countingAuthenticator struct {
+	auth.Authenticator
+	calls int
+}
+
+func (a *countingAuthenticator) Authenticate(r *http.Request) (auth.Principal, error) {
+	a.calls++
+	return a.Authenticator.Authenticate(r)
+}
+
+func TestRateLimitByPrincipal(t *testing.T) {
+	keys := auth.NewMemoryAPIKeyStore()
+	key, keyHash, err := auth.GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("generate api key: %v", err)
+	}
+	if _, err := keys.Create(context.Background(), auth.APIKey{Name: "importer", Role: auth.RoleViewer}, keyHash); err != nil {
+		t.Fatalf("create api key: %v", err)
+	}
+	secret := []byte("test-secret")
+	jwtAuthenticator, err := auth.NewJWTAuthenticator(auth.JWTConfig{HMACSecret: secret})
+	if err != nil {
+		t.Fatalf("jwt authenticator: %v", err)
+	}
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}).SignedString(secret)
+	if err != nil {
+		t.Fatalf("sign token: %v", err)
+	}
+
+	authenticator := &countingAuthenticator{Authenticator: auth.Chain{auth.NewAPIKeyAuthenticator(keys), jwtAuthenticator}}
+	handler := NewHandler(stubService{
+		getDepartmentFn: func(ctx context.Context, departmentID uint, options service.GetDepartmentOptions) (service.DepartmentTree, error) {
+			return service.DepartmentTree{}, nil
+		},
+	}, slog.New(slog.DiscardHandler),
+		WithAuthenticator(authenticator),
+		WithRateLimit(ratelimit.NewLimiter(0.001, 2), ratelimit.NewLimiter(0.001, 2)),
+	)
+
+	get := func(remoteAddr, header, value string) int {
+		req := httptest.NewRequest(http.MethodGet, "/departments/1", nil)
+		req.RemoteAddr = remoteAddr
+		if header != "" {
+			req.Header.Set(header, value)
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder.Code
+	}
+
+	// Rotating invalid keys spends the tokens of the IP.
+	for i := range 2 {
+		if code := get("192.0.2.1:1000", "X-API-Key", fmt.Sprintf("hk_bogus%d", i)); code != http.StatusUnauthorized {
+			t.Fatalf("bogus key %d: expected status %d, got %d", i, http.StatusUnauthorized, code)
+		}
+	}
+	if code := get("192.0.2.1:1001", "X-API-Key", "hk_bogus2"); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the IP to be limited, got %d", code)
+	}
+	if authenticator.calls != 3 {
+		t.Fatalf("expected 3 credential lookups, got %d", authenticator.calls)
+	}
+
+	// A valid key from the same IP is still let through, and is limited as
+	// one client whichever header carries it.
+	if code := get("192.0.2.1:1002", "X-API-Key", key); code != http.StatusOK {
+		t.Fatalf("expected status %d for a valid key, got %d", http.StatusOK, code)
+	}
+	if code := get("198.51.100.2:1000", "Authorization", "Bearer "+key); code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d", http.StatusOK, code)
+	}
+	if code := get("198.51.100.3:1000", "Authorization", "Bearer "+key); code != http.StatusTooManyRequests {
+		t.Fatalf("expected the key to be limited, got %d", code)
+	}
+	if code := get("198.51.100.1:1000", "Authorization", "Bearer "+token); code != http.StatusOK {
+		t.Fatalf("expected a separate bucket for the jwt subject, got %d", code)
+	}
+}
+
+func TestClientIP(t *testing.T) {
+	handler := NewHandler(stubService{}, slog.New(slog.DiscardHandler), WithTrustedProxies([]netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("2001:db8::/32"),
+	}))
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		header     string
+		value      string
+		want       string
+	}{
+		{name: "direct", remoteAddr: "192.0.2.1:1000", want: "192.0.2.1"},
+		{name: "spoofed from an untrusted peer", remoteAddr: "192.0.2.1:1000", header: "X-Forwarded-For", value: "198.51.100.7", want: "192.0.2.1"},
+		{name: "trusted proxy", remoteAddr: "10.0.0.5:1000", header: "X-Forwarded-For", value: "198.51.100.7", want: "198.51.100.7"},
+		{name: "client prepends a fake hop", remoteAddr: "10.0.0.5:1000", header: "X-Forwarded-For", value: "203.0.113.9, 198.51.100.7, 10.0.0.6", want: "198.51.100.7"},
+		{name: "forwarded", remoteAddr: "10.0.0.5:1000", header: "Forwarded", value: `for="[2001:db8:cafe::17]:4711", for=198.51.100.7;proto=https`, want: "198.51.100.7"},
+		{name: "forwarded from an ipv6 proxy", remoteAddr: "[2001:db8::1]:1000", header: "Forwarded", value: `for=198.51.100.7`, want: "198.51.100.7"},
+		{name: "unparsable hop", remoteAddr: "10.0.0.5:1000", header: "Forwarded", value: "for=unknown", want: "10.0.0.5"},
+		{name: "only proxies", remoteAddr: "10.0.0.5:1000", header: "X-Forwarded-For", value: "10.0.0.7", want: "10.0.0.7"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/departments/1", nil)
+			req.RemoteAddr = tt.remoteAddr
+			if tt.header != "" {
+				req.Header.Set(tt.header, tt.value)
+			}
+			if got := handler.clientIP(req); got != tt.want {
+				t.Fatalf("expected %s, got %s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	keys := auth.NewMemoryAPIKeyStore()
+	key, keyHash, err := auth.GenerateAPIKey()
+	if err != nil {
+		t.Fatalf("generate api key: %v", err)
+	}
+	if _, err := keys.Create(context.Background(), auth.APIKey{Name: "importer", Role: auth.RoleViewer}, keyHash); err != nil {
+		t.Fatalf("create api key: %v", err)
+	}
+
+	secret := []byte("test-secret")
+	jwtAuthenticator, err := auth.NewJWTAuthenticator(auth.JWTConfig{HMACSecret: secret})
+	if err != nil {
+		t.Fatalf("jwt authenticator: %v", err)
+	}
+
+	var principal auth.Principal
+	handler := NewHandler(stubService{
+		getDepartmentFn: func(ctx context.Context, departmentID uint, options service.GetDepartmentOptions) (service.DepartmentTree, error) {
+			principal, _ = auth.PrincipalFromContext(ctx)
+			return service.DepartmentTree{}, nil
+		},
+	}, slog.New(slog.DiscardHandler), WithAuthenticator(auth.Chain{auth.NewAPIKeyAuthenticator(keys), jwtAuthenticator}))
+
+	signed := func(claims jwt.MapClaims) string {
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(secret)
+		if err != nil {
+			t.Fatalf("sign token: %v", err)
+		}
+		return token
+	}
+	validToken := signed(jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+	expiredToken := signed(jwt.MapClaims{"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()})
+
+	cases := []struct {
+		name      string
+		header    string
+		value     string
+		status    int
+		principal string
+	}{
+		{name: "no credentials", status: http.StatusUnauthorized},
+		{name: "api key", header: "X-API-Key", value: key, status: http.StatusOK, principal: "api_key:importer"},
+		{name: "unknown api key", header: "X-API-Key", value: "hk_unknown", status: http.StatusUnauthorized},
+		{name: "api key as bearer token", header: "Authorization", value: "Bearer " + key, status: http.StatusOK, principal: "api_key:importer"},
+		{name: "jwt", header: "Authorization", value: "Bearer " + validToken, status: http.StatusOK, principal: "jwt:alice"},
+		{name: "expired jwt", header: "Authorization", value: "Bearer " + expiredToken, status: http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			principal = auth.Principal{}
+			req := httptest.NewRequest(http.MethodGet, "/departments/1", nil)
+			if tc.header != "" {
+				req.Header.Set(tc.header, tc.value)
+			}
+			recorder := httptest.NewRecorder()
+			handler.ServeHTTP(recorder, req)
+
+			if recorder.Code != tc.status {
+				t.Fatalf("expected status %d, got %d", tc.status, recorder.Code)
+			}
+			if tc.principal != "" && principal.String() != tc.principal {
+				t.Fatalf("expected principal %q, got %q", tc.principal, principal.String())
+			}
+		})
+	}
+
+	if err := keys.Revoke(context.Background(), "importer"); err != nil {
+		t.Fatalf("revoke api key: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/departments/1", nil)
+	req.Header.Set("X-API-Key", key)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusUnauthorized {
+		t.Fatalf("expected revoked key to be rejected, got %d", recorder.Code)
+	}
+}
+
+func TestEraseEmployeeRoute(t *testing.T) {
+	handler := NewHandler(stubService{
+		eraseEmployeeFn: func(ctx context.Context, departmentID uint, employeeID uint) (service.EmployeeDTO, error) {
+			if departmentID != 3 || employeeID != 7 {
+				t.Fatalf("unexpected ids %d/%d", departmentID, employeeID)
+			}
+			return service.EmployeeDTO{}, apperror.New(apperror.CodeForbidden, "role admin or higher is required")
+		},
+	}, slog.New(slog.DiscardHandler))
+
+	req := httptest.NewRequest(http.MethodPost, "/departments/3/employees/7/erase", nil)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d, got %d", http.StatusForbidden, recorder.Code)
+	}
+}
+
+func TestEraseEmployeeIdempotencyReplay(t *testing.T) {
+	calls := 0
+	handler := NewHandler(stubService{
+		eraseEmployeeFn: func(ctx context.Context, departmentID uint, employeeID uint) (service.EmployeeDTO, error) {
+			calls++
+			return service.EmployeeDTO{ID: employeeID, DepartmentID: departmentID, FullName: "[erased]"}, nil
+		},
+	}, slog.New(slog.DiscardHandler), WithIdempotency(idempotency.NewMemoryStore(), time.Hour, 0))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/departments/3/employees/7/erase", nil)
+		req.Header.Set("Idempotency-Key", "erase-7")
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	first := send()
+	second := send()
+	if first.Code != http.StatusOK || second.Code != http.StatusOK || second.Header().Get("Idempotent-Replayed") != "true" {
+		t.Fatalf("expected the erasure replayed, got %d then %d", first.Code, second.Code)
+	}
+	if calls != 1 {
+		t.Fatalf("expected the erasure to run once, got %d", calls)
+	}
+}
+
+func TestMetricsUseRouteTemplate(t *testing.T) {
+	appMetrics := metrics.New()
+	handler := appMetrics.Middleware(NewHandler(stubService{}, slog.New(slog.DiscardHandler)))
+
+	for _, path := range []string{"/departments/1", "/departments/2", "/departments/x/unknown"} {
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	recorder := httptest.NewRecorder()
+	appMetrics.Handler().ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	body := recorder.Body.String()
+	for _, expected := range []string{
+		`http_requests_total{method="GET",route="/departments/{id}",status="200"} 2`,
+		`http_requests_total{method="GET",route="unmatched",status="404"} 1`,
+	} {
+		if !strings.Contains(body, expected) {
+			t.Fatalf("expected %q in metrics output:\n%s", expected, body)
+		}
+	}
+}
+
+// publicResolver resolves every host to a documentation address, without DNS.
+type publicResolver struct{}
+
+func (publicResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	return []net.IPAddr{{IP: net.ParseIP("203.0.113.10")}}, nil
+}
+
+func TestWebhookRoutes(t *testing.T) {
+	store := memory.NewStore()
+	handler := NewHandler(stubService{}, slog.New(slog.DiscardHandler), WithWebhooks(service.NewWebhookService(store, service.WithWebhookResolver(publicResolver{}))))
+
+	serve := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	created := serve(http.MethodPost, "/webhooks", `{"url":"https://example.test/hook","event_types":["department.deleted"]}`)
+	if created.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, created.Code, created.Body.String())
+	}
+	var webhook service.WebhookDTO
+	if err := json.Unmarshal(created.Body.Bytes(), &webhook); err != nil || webhook.Secret == "" {
+		t.Fatalf("expected the secret in the create response, got %s", created.Body.String())
+	}
+
+	if recorder := serve(http.MethodGet, "/webhooks/1", ""); recorder.Code != http.StatusOK || strings.Contains(recorder.Body.String(), webhook.Secret) {
+		t.Fatalf("expected the webhook without its secret, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := serve(http.MethodPatch, "/webhooks/1", `{"active":false,"department_id":null}`); recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"active":false`) {
+		t.Fatalf("expected a paused webhook, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := serve(http.MethodGet, "/webhooks/1/deliveries?status=dead", ""); recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"deliveries":[]`) {
+		t.Fatalf("expected an empty delivery log, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := serve(http.MethodGet, "/webhooks/1/deliveries?limit=0", ""); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for an invalid limit, got %d", http.StatusBadRequest, recorder.Code)
+	}
+	if recorder := serve(http.MethodPost, "/webhooks/1/deliveries/9/redeliver", ""); recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d for an unknown delivery, got %d", http.StatusNotFound, recorder.Code)
+	}
+	if recorder := serve(http.MethodDelete, "/webhooks/1", ""); recorder.Code != http.StatusNoContent {
+		t.Fatalf("expected status %d, got %d", http.StatusNoContent, recorder.Code)
+	}
+	if recorder := serve(http.MethodGet, "/webhooks", ""); recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"webhooks":[]`) {
+		t.Fatalf("expected no webhooks, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	withoutWebhooks := NewHandler(stubService{}, slog.New(slog.DiscardHandler))
+	recorder := httptest.NewRecorder()
+	withoutWebhooks.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/webhooks", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d without webhooks, got %d", http.StatusNotFound, recorder.Code)
+	}
+}
+
+func TestWebhookCreateReplaysIdempotencyKey(t *testing.T) {
+	handler := NewHandler(stubService{}, slog.New(slog.DiscardHandler),
+		WithWebhooks(service.NewWebhookService(memory.NewStore(), service.WithWebhookResolver(publicResolver{}))),
+		WithIdempotency(idempotency.NewMemoryStore(), time.Hour, 0))
+
+	serve := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		if method == http.MethodPost {
+			req.Header.Set("Idempotency-Key", "create-hook")
+		}
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	body := `{"url":"https://example.test/hook","event_types":["department.deleted"]}`
+	first := serve(http.MethodPost, "/webhooks", body)
+	if first.Code != http.StatusCreated {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, first.Code, first.Body.String())
+	}
+	second := serve(http.MethodPost, "/webhooks", body)
+	if second.Code != http.StatusCreated || second.Header().Get("Idempotent-Replayed") != "true" || second.Body.String() != first.Body.String() {
+		t.Fatalf("expected the original response replayed, got %d: %s", second.Code, second.Body.String())
+	}
+
+	listed := serve(http.MethodGet, "/webhooks", "")
+	var list struct {
+		Webhooks []service.WebhookDTO `json:"webhooks"`
+	}
+	if err := json.Unmarshal(listed.Body.Bytes(), &list); err != nil || len(list.Webhooks) != 1 {
+		t.Fatalf("expected one webhook, got %s", listed.Body.String())
+	}
+}
+
+type stubEventStreamer struct {
+	options service.StreamEventsOptions
+	err     error
+}
+
+func (s *stubEventStreamer) OpenEventStream(ctx context.Context, options service.StreamEventsOptions) (*service.EventStream, error) {
+	s.options = options
+	return nil, s.err
+}
+
+func TestEventStreamOptions(t *testing.T) {
+	streamer := &stubEventStreamer{err: apperror.New(apperror.CodeForbidden, "department is outside of your scope")}
+	handler := NewHandler(stubService{}, slog.New(slog.DiscardHandler), WithEvents(streamer))
+
+	req := httptest.NewRequest(http.MethodGet, "/events/stream?department_id=4&last_event_id=7", nil)
+	req.Header.Set(lastEventIDHeader, "12")
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusForbidden {
+		t.Fatalf("expected status %d before the stream starts, got %d", http.StatusForbidden, recorder.Code)
+	}
+	if streamer.options.DepartmentID == nil || *streamer.options.DepartmentID != 4 || streamer.options.LastEventID == nil || *streamer.options.LastEventID != 12 {
+		t.Fatalf("expected department 4 resumed after the header's event 12, got %+v", streamer.options)
+	}
+
+	for _, path := range []string{"/events/stream?last_event_id=x", "/events/stream?department_id=0"} {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, path, nil))
+		if recorder.Code != http.StatusBadRequest {
+			t.Fatalf("expected status %d for %s, got %d", http.StatusBadRequest, path, recorder.Code)
+		}
+	}
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodGet, "/events", nil))
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, recorder.Code)
+	}
+}
+
+func TestSCIMRoutes(t *testing.T) {
+	ctx := context.Background()
+	departments := service.NewDepartmentService(memory.NewStore())
+	handler := NewHandler(stubService{}, slog.New(slog.DiscardHandler), WithSCIM(service.NewDirectoryService(departments)))
+	engineering, err := departments.CreateDepartment(ctx, service.CreateDepartmentInput{Name: "Engineering"})
+	if err != nil {
+		t.Fatalf("create department: %v", err)
+	}
+	sales, err := departments.CreateDepartment(ctx, service.CreateDepartmentInput{Name: "Sales"})
+	if err != nil {
+		t.Fatalf("create department: %v", err)
+	}
+	employee, err := departments.CreateEmployee(ctx, engineering.ID, service.CreateEmployeeInput{FullName: "Ivan Petrov", Position: "Developer"})
+	if err != nil {
+		t.Fatalf("create employee: %v", err)
+	}
+
+	serve := func(method string, path string, body string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	listed := serve(http.MethodGet, `/scim/v2/Users?filter=displayName+sw+%22ivan%22+and+active+eq+true&count=1`, "")
+	if listed.Code != http.StatusOK || listed.Header().Get("Content-Type") != "application/scim+json" {
+		t.Fatalf("expected a SCIM list, got %d: %s", listed.Code, listed.Body.String())
+	}
+	var users struct {
+		TotalResults int64 `json:"totalResults"`
+		Resources    []struct {
+			ID         string `json:"id"`
+			Enterprise struct {
+				Department string `json:"department"`
+			} `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User"`
+		} `json:"Resources"`
+	}
+	if err := json.Unmarshal(listed.Body.Bytes(), &users); err != nil || users.TotalResults != 1 || users.Resources[0].Enterprise.Department != "Engineering" {
+		t.Fatalf("expected the employee with its department, got %s", listed.Body.String())
+	}
+	if recorder := serve(http.MethodGet, `/scim/v2/Users?filter=emails+pr`, ""); recorder.Code != http.StatusBadRequest || !strings.Contains(recorder.Body.String(), `"scimType":"invalidFilter"`) {
+		t.Fatalf("expected an invalidFilter error, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+
+	userPath := "/scim/v2/Users/" + users.Resources[0].ID
+	patched := serve(http.MethodPatch, userPath, `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"Replace","value":{"title":"Lead"}}]}`)
+	if patched.Code != http.StatusOK || !strings.Contains(patched.Body.String(), `"title":"Lead"`) {
+		t.Fatalf("expected the new title, got %d: %s", patched.Code, patched.Body.String())
+	}
+	if recorder := serve(http.MethodPatch, userPath, `{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"active","value":false}]}`); recorder.Code != http.StatusBadRequest {
+		t.Fatalf("expected status %d for active, got %d", http.StatusBadRequest, recorder.Code)
+	}
+
+	// Adding a user to a group moves the employee to that department.
+	groupPath := fmt.Sprintf("/scim/v2/Groups/%d", sales.ID)
+
+	// A PATCH applies all of its operations or none, and If-Match guards
+	// member changes as well.
+	halfApplied := serve(http.MethodPatch, groupPath, fmt.Sprintf(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"replace","path":"displayName","value":"Revenue"},{"op":"add","path":"members","value":[{"value":"%d"}]}]}`, employee.ID+100))
+	if halfApplied.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d for an unknown member, got %d", http.StatusNotFound, halfApplied.Code)
+	}
+	req := httptest.NewRequest(http.MethodPatch, groupPath, strings.NewReader(fmt.Sprintf(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"add","path":"members","value":[{"value":"%d"}]}]}`, employee.ID)))
+	req.Header.Set("If-Match", fmt.Sprintf(`W/"%d"`, sales.Version+1))
+	stale := httptest.NewRecorder()
+	handler.ServeHTTP(stale, req)
+	if stale.Code != http.StatusPreconditionFailed {
+		t.Fatalf("expected status %d for a stale version, got %d: %s", http.StatusPreconditionFailed, stale.Code, stale.Body.String())
+	}
+	if recorder := serve(http.MethodGet, groupPath, ""); !strings.Contains(recorder.Body.String(), `"displayName":"Sales"`) || strings.Contains(recorder.Body.String(), `"members":[{`) {
+		t.Fatalf("expected sales unchanged, got %s", recorder.Body.String())
+	}
+
+	moved := serve(http.MethodPatch, groupPath, fmt.Sprintf(`{"schemas":["urn:ietf:params:scim:api:messages:2.0:PatchOp"],"Operations":[{"op":"add","path":"members","value":[{"value":"%d"}]}]}`, employee.ID))
+	if moved.Code != http.StatusOK || !strings.Contains(moved.Body.String(), fmt.Sprintf(`"value":"%d"`, employee.ID)) {
+		t.Fatalf("expected the employee among the members, got %d: %s", moved.Code, moved.Body.String())
+	}
+	if recorder := serve(http.MethodGet, "/scim/v2/Groups", ""); recorder.Code != http.StatusOK || strings.Count(recorder.Body.String(), fmt.Sprintf(`"value":"%d"`, employee.ID)) != 1 || !strings.Contains(recorder.Body.String(), `"members":[]`) {
+		t.Fatalf("expected the employee among the members of one group, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := serve(http.MethodGet, "/scim/v2/Groups?filter=displayName+eq+%22sales%22&excludedAttributes=members", ""); recorder.Code != http.StatusOK || !strings.Contains(recorder.Body.String(), `"totalResults":1`) || strings.Contains(recorder.Body.String(), "members") {
+		t.Fatalf("expected sales without members, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	if recorder := serve(http.MethodDelete, groupPath, ""); recorder.Code != http.StatusNotImplemented {
+		t.Fatalf("expected status %d, got %d", http.StatusNotImplemented, recorder.Code)
+	}
+	if recorder := serve(http.MethodGet, "/scim/v2/Users/unknown", ""); recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected status %d, got %d", http.StatusNotFound, recorder.Code)
+	}
+}
+
+func TestLDIFExport(t *testing.T) {
+	ctx := context.Background()
+	departments := service.NewDepartmentService(memory.NewStore())
+	handler := NewHandler(stubService{}, slog.New(slog.DiscardHandler), WithLDIFExport(service.NewDirectoryService(departments), "dc=example,dc=com"))
+	engineering, err := departments.CreateDepartment(ctx, service.CreateDepartmentInput{Name: "Engineering"})
+	if err != nil {
+		t.Fatalf("create department: %v", err)
+	}
+	backend, err := departments.CreateDepartment(ctx, service.CreateDepartmentInput{Name: "Backend, Go", ParentID: &engineering.ID})
+	if err != nil {
+		t.Fatalf("create department: %v", err)
+	}
+	employee, err := departments.CreateEmployee(ctx, backend.ID, service.CreateEmployeeInput{FullName: "Ivan Petrov", Position: "Developer"})
+	if err != nil {
+		t.Fatalf("create employee: %v", err)
+	}
+	erased, err := departments.CreateEmployee(ctx, backend.ID, service.CreateEmployeeInput{FullName: "Oleg Sidorov", Position: "Developer"})
+	if err != nil {
+		t.Fatalf("create employee: %v", err)
+	}
+	if _, err := departments.EraseEmployee(ctx, backend.ID, erased.ID); err != nil {
+		t.Fatalf("erase employee: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, fmt.Sprintf("/directory/ldif?department_id=%d", backend.ID), nil)
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusOK || recorder.Header().Get("Content-Type") != "text/x-ldif; charset=utf-8" {
+		t.Fatalf("expected an LDIF file, got %d: %s", recorder.Code, recorder.Body.String())
+	}
+	body := recorder.Body.String()
+	departmentDN := `ou=Backend\, Go,ou=Engineering,dc=example,dc=com`
+	for _, line := range []string{
+		"dn: " + departmentDN + "\n",
+		fmt.Sprintf("dn: uid=%d,%s\n", employee.ID, departmentDN),
+		"cn: Ivan Petrov\n",
+		"title: Developer\n",
+	} {
+		if !strings.Contains(body, line) {
+			t.Fatalf("expected %q in the export:\n%s", line, body)
+		}
+	}
+	if strings.Contains(body, fmt.Sprintf("uid=%d,", erased.ID)) {
+		t.Fatalf("expected erased employees to be skipped:\n%s", body)
+	}
+
+	req = httptest.NewRequest(http.MethodGet, "/directory/ldif?department_id=999", nil)
+	recorder = httptest.NewRecorder()
+	handler.ServeHTTP(recorder, req)
+	if recorder.Code != http.StatusNotFound {
+		t.Fatalf("expected 404 for an unknown department, got %d", recorder.Code)
+	}
+}
@@ -0,0 +1,201 @@
+package httpapi
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"hitalent-go-task/internal/auth"
+	"hitalent-go-task/internal/idempotency"
+)
+
+const (
+	idempotencyKeyHeader     = "Idempotency-Key"
+	idempotentReplayedHeader = "Idempotent-Replayed"
+	maxIdempotencyKeyLength  = 255
+	defaultIdempotencyTTL    = 24 * time.Hour
+	defaultIdempotencyLease  = time.Minute
+)
+
+// replayedHeaders are stored with an idempotent response and replayed.
+var replayedHeaders = []string{"Content-Type", "ETag", "Location"}
+
+type idempotencyConfig struct {
+	store idempotency.Store
+	ttl   time.Duration
+	lease time.Duration
+}
+
+// WithIdempotency stores POST responses by Idempotency-Key for ttl. A request
+// may run for lease, after which a retry with the same key takes the
+// reservation over, e.g. because the server handling it stopped.
+func WithIdempotency(store idempotency.Store, ttl time.Duration, lease time.Duration) Option {
+	return func(h *Handler) {
+		if ttl <= 0 {
+			ttl = defaultIdempotencyTTL
+		}
+		if lease <= 0 {
+			lease = defaultIdempotencyLease
+		}
+		h.idempotency = &idempotencyConfig{
+			store: store,
+			ttl:   ttl,
+			lease: lease,
+		}
+	}
+}
+
+func (h *Handler) serveIdempotent(w http.ResponseWriter, r *http.Request, next func(http.ResponseWriter, *http.Request)) {
+	key := strings.TrimSpace(r.Header.Get(idempotencyKeyHeader))
+	if h.idempotency == nil || r.Method != http.MethodPost || key == "" {
+		next(w, r)
+		return
+	}
+	if len(key) > maxIdempotencyKeyLength {
+		writeError(w, http.StatusBadRequest, "Idempotency-Key must be at most 255 characters")
+		return
+	}
+
+	var body []byte
+	if r.Body != nil {
+		var err error
+		body, err = io.ReadAll(r.Body)
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "failed to read request body")
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	key = idempotencyStoreKey(r, key)
+	fingerprint := requestFingerprint(r, body)
+	now := time.Now()
+	record, reserved, err := h.idempotency.store.Reserve(r.Context(), key, fingerprint, now.Add(h.idempotency.ttl), now.Add(h.idempotency.lease))
+	if err != nil {
+		h.logger.ErrorContext(r.Context(), "idempotency reserve failed", "error", err)
+		writeError(w, http.StatusInternalServerError, "internal server error")
+		return
+	}
+
+	if !reserved {
+		switch {
+		case record.Fingerprint != fingerprint:
+			writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used with a different request")
+		case !record.Completed():
+			writeError(w, http.StatusConflict, "request with this Idempotency-Key is still in progress")
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			for _, name := range replayedHeaders {
+				if value := record.Header.Get(name); value != "" {
+					w.Header().Set(name, value)
+				}
+			}
+			w.Header().Set(idempotentReplayedHeader, "true")
+			w.WriteHeader(record.StatusCode)
+			_, _ = w.Write(record.Body)
+		}
+		return
+	}
+
+	// The outcome is stored even if the client has gone: the request may
+	// have committed, and its retry must get the response.
+	storeCtx := context.WithoutCancel(r.Context())
+	defer func() {
+		if p := recover(); p != nil {
+			h.releaseIdempotencyKey(storeCtx, key, record.LeaseToken)
+			panic(p)
+		}
+	}()
+
+	// The request is not allowed to outlive its lease, so a retry that takes
+	// the key over never runs alongside it.
+	ctx, cancel := context.WithDeadline(r.Context(), record.LeaseExpiresAt)
+	defer cancel()
+	recorder := &capturingResponseWriter{ResponseWriter: w, status: http.StatusOK}
+	next(recorder, r.WithContext(ctx))
+
+	// Server errors are not cached so the client can safely retry with the same key.
+	if recorder.status >= http.StatusInternalServerError {
+		h.releaseIdempotencyKey(storeCtx, key, record.LeaseToken)
+		return
+	}
+	header := make(http.Header)
+	for _, name := range replayedHeaders {
+		if value := w.Header().Get(name); value != "" {
+			header.Set(name, value)
+		}
+	}
+	err = h.idempotency.store.Complete(storeCtx, key, record.LeaseToken, recorder.status, header, recorder.body.Bytes())
+	switch {
+	case errors.Is(err, idempotency.ErrLeaseLost):
+		h.logger.WarnContext(r.Context(), "idempotency lease lost before the response was stored")
+	case err != nil:
+		h.logger.ErrorContext(r.Context(), "idempotency complete failed", "error", err)
+	}
+}
+
+func (h *Handler) releaseIdempotencyKey(ctx context.Context, key string, leaseToken string) {
+	err := h.idempotency.store.Release(ctx, key, leaseToken)
+	switch {
+	case errors.Is(err, idempotency.ErrLeaseLost):
+		h.logger.WarnContext(ctx, "idempotency lease lost before the key was released")
+	case err != nil:
+		h.logger.ErrorContext(ctx, "idempotency release failed", "error", err)
+	}
+}
+
+// idempotencyStoreKey scopes the key to the caller, so two principals that
+// happen to pick the same key do not collide.
+func idempotencyStoreKey(r *http.Request, key string) string {
+	principal, ok := auth.PrincipalFromContext(r.Context())
+	if !ok {
+		return key
+	}
+	hash := sha256.Sum256([]byte(principal.String() + "\x00" + key))
+	return hex.EncodeToString(hash[:])
+}
+
+// requestFingerprint covers the caller as well, so a key reused by another
+// principal never replays someone else's response.
+func requestFingerprint(r *http.Request, body []byte) string {
+	hash := sha256.New()
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		hash.Write([]byte(principal.String()))
+	}
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.Method))
+	hash.Write([]byte{0})
+	hash.Write([]byte(r.URL.RequestURI()))
+	hash.Write([]byte{0})
+	hash.Write(body)
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+type capturingResponseWriter struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (w *capturingResponseWriter) WriteHeader(status int) {
+	if !w.wroteHeader {
+		w.status = status
+		w.wroteHeader = true
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *capturingResponseWriter) Write(data []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
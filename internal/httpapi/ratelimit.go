@@ -0,0 +1,93 @@
+package httpapi
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"hitalent-go-task/internal/auth"
+	"hitalent-go-task/internal/ratelimit"
+)
+
+type rateLimits struct {
+	read  *ratelimit.Limiter
+	write *ratelimit.Limiter
+}
+
+// WithRateLimit throttles each client separately for reads and writes.
+// Authenticated clients are limited by principal, everyone else by IP.
+// Failed authentications count against the IP and are answered with 429 once
+// it runs out of tokens, so rotating invalid keys does not escape the limit,
+// while valid credentials from the same IP keep working.
+func WithRateLimit(read, write *ratelimit.Limiter) Option {
+	return func(h *Handler) {
+		h.limits = &rateLimits{read: read, write: write}
+	}
+}
+
+func (h *Handler) limiterFor(r *http.Request) *ratelimit.Limiter {
+	if h.limits == nil {
+		return nil
+	}
+	limiter := h.limits.write
+	if r.Method == http.MethodGet || r.Method == http.MethodHead || r.Method == http.MethodOptions {
+		limiter = h.limits.read
+	}
+	if !limiter.Enabled() {
+		return nil
+	}
+	return limiter
+}
+
+// limitFailedAuthentication takes a token from the client IP and writes 429
+// when there was none left.
+func (h *Handler) limitFailedAuthentication(w http.ResponseWriter, r *http.Request) bool {
+	limiter := h.limiterFor(r)
+	if limiter == nil {
+		return false
+	}
+	decision := limiter.Allow(h.ipRateLimitKey(r))
+	if !decision.Allowed {
+		writeRateLimited(w, decision)
+	}
+	return !decision.Allowed
+}
+
+// allowRequest takes a token from the authenticated client.
+func (h *Handler) allowRequest(w http.ResponseWriter, r *http.Request) bool {
+	limiter := h.limiterFor(r)
+	if limiter == nil {
+		return true
+	}
+	key := h.ipRateLimitKey(r)
+	if principal, ok := auth.PrincipalFromContext(r.Context()); ok {
+		key = "principal:" + principal.String()
+	}
+	decision := limiter.Allow(key)
+	if !decision.Allowed {
+		writeRateLimited(w, decision)
+		return false
+	}
+	setRateLimitHeaders(w, decision)
+	return true
+}
+
+func (h *Handler) ipRateLimitKey(r *http.Request) string {
+	return "ip:" + h.clientIP(r)
+}
+
+func setRateLimitHeaders(w http.ResponseWriter, decision ratelimit.Decision) {
+	w.Header().Set("RateLimit-Limit", strconv.Itoa(decision.Limit))
+	w.Header().Set("RateLimit-Remaining", strconv.Itoa(decision.Remaining))
+	w.Header().Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(decision.Reset)))
+}
+
+func writeRateLimited(w http.ResponseWriter, decision ratelimit.Decision) {
+	setRateLimitHeaders(w, decision)
+	w.Header().Set("Retry-After", strconv.Itoa(ceilSeconds(decision.RetryAfter)))
+	writeError(w, http.StatusTooManyRequests, "rate limit exceeded")
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
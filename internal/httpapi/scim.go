@@ -0,0 +1,670 @@
+package httpapi
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"hitalent-go-task/internal/apperror"
+	"hitalent-go-task/internal/scim"
+	"hitalent-go-task/internal/service"
+)
+
+const (
+	scimDefaultCount = 100
+	scimMaxCount     = 200
+)
+
+var errSCIMNotFound = &scim.Error{Status: http.StatusNotFound, Detail: "resource not found"}
+
+// WithSCIM serves employees as SCIM users and departments as SCIM groups
+// under /scim/v2, for identity providers to provision from.
+func WithSCIM(directory service.Directory) Option {
+	return func(h *Handler) {
+		h.directory = directory
+	}
+}
+
+type scimName struct {
+	Formatted string `json:"formatted,omitempty"`
+}
+
+type scimReference struct {
+	Value   string `json:"value"`
+	Display string `json:"display,omitempty"`
+	Ref     string `json:"$ref,omitempty"`
+	Type    string `json:"type,omitempty"`
+}
+
+type scimEnterpriseUser struct {
+	Department string `json:"department,omitempty"`
+}
+
+type scimUser struct {
+	Schemas     []string            `json:"schemas"`
+	ID          string              `json:"id"`
+	UserName    string              `json:"userName"`
+	Name        *scimName           `json:"name,omitempty"`
+	DisplayName string              `json:"displayName,omitempty"`
+	Title       string              `json:"title,omitempty"`
+	Active      bool                `json:"active"`
+	Groups      []scimReference     `json:"groups,omitempty"`
+	Enterprise  *scimEnterpriseUser `json:"urn:ietf:params:scim:schemas:extension:enterprise:2.0:User,omitempty"`
+	Meta        scim.Meta           `json:"meta"`
+}
+
+type scimGroup struct {
+	Schemas     []string         `json:"schemas"`
+	ID          string           `json:"id"`
+	DisplayName string           `json:"displayName"`
+	Members     *[]scimReference `json:"members,omitempty"`
+	Meta        scim.Meta        `json:"meta"`
+}
+
+func (h *Handler) serveSCIM(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) < 3 || len(parts) > 4 || parts[1] != "v2" {
+		writeSCIMError(w, errSCIMNotFound)
+		return
+	}
+
+	if len(parts) == 3 {
+		switch parts[2] {
+		case "Users", "Groups":
+			switch r.Method {
+			case http.MethodGet:
+				if parts[2] == "Users" {
+					h.handleListSCIMUsers(w, r)
+				} else {
+					h.handleListSCIMGroups(w, r)
+				}
+			case http.MethodPost:
+				writeSCIMError(w, &scim.Error{Status: http.StatusNotImplemented, Detail: "resources are created through the departments API"})
+			default:
+				writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			}
+		case "ServiceProviderConfig":
+			h.handleSCIMStatic(w, r, scimServiceProviderConfig())
+		case "ResourceTypes":
+			h.handleSCIMStatic(w, r, scim.NewListResponse(2, 1, scimResourceTypes(r)))
+		default:
+			writeSCIMError(w, errSCIMNotFound)
+		}
+		return
+	}
+
+	if parts[2] != "Users" && parts[2] != "Groups" {
+		writeSCIMError(w, errSCIMNotFound)
+		return
+	}
+	// SCIM ids are opaque strings; anything but our numeric ids is unknown.
+	id, err := parseUintID(parts[3])
+	if err != nil {
+		writeSCIMError(w, errSCIMNotFound)
+		return
+	}
+	switch r.Method {
+	case http.MethodGet:
+		if parts[2] == "Users" {
+			h.handleGetSCIMUser(w, r, id)
+		} else {
+			h.writeSCIMGroup(w, r, id)
+		}
+	case http.MethodPatch:
+		if parts[2] == "Users" {
+			h.handlePatchSCIMUser(w, r, id)
+		} else {
+			h.handlePatchSCIMGroup(w, r, id)
+		}
+	case http.MethodPut, http.MethodDelete:
+		writeSCIMError(w, &scim.Error{Status: http.StatusNotImplemented, Detail: "use PATCH; resources are replaced and deleted through the departments API"})
+	default:
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+	}
+}
+
+func scimRouteTemplate(parts []string) string {
+	if len(parts) < 3 || parts[1] != "v2" {
+		return ""
+	}
+	switch {
+	case len(parts) == 3 && (parts[2] == "Users" || parts[2] == "Groups" || parts[2] == "ServiceProviderConfig" || parts[2] == "ResourceTypes"):
+		return "/scim/v2/" + parts[2]
+	case len(parts) == 4 && (parts[2] == "Users" || parts[2] == "Groups"):
+		return "/scim/v2/" + parts[2] + "/{id}"
+	}
+	return ""
+}
+
+func (h *Handler) handleListSCIMUsers(w http.ResponseWriter, r *http.Request) {
+	startIndex, count := parseSCIMPage(r)
+	options := service.ListEmployeesOptions{Offset: startIndex - 1, Limit: count}
+	if raw := strings.TrimSpace(r.URL.Query().Get("filter")); raw != "" {
+		comparisons, err := scim.ParseFilter(raw)
+		if err == nil {
+			err = applyUserFilter(&options, comparisons)
+		}
+		if err != nil {
+			h.respondWithSCIMError(w, r, err)
+			return
+		}
+	}
+
+	employees, total, err := h.directory.ListEmployees(r.Context(), options)
+	if err != nil {
+		h.respondWithSCIMError(w, r, err)
+		return
+	}
+	users, err := h.scimUsers(r, employees)
+	if err != nil {
+		h.respondWithSCIMError(w, r, err)
+		return
+	}
+	writeSCIM(w, http.StatusOK, scim.NewListResponse(total, startIndex, users))
+}
+
+func (h *Handler) handleGetSCIMUser(w http.ResponseWriter, r *http.Request, id uint) {
+	employee, err := h.directory.GetEmployee(r.Context(), id)
+	if err != nil {
+		h.respondWithSCIMError(w, r, err)
+		return
+	}
+	h.writeSCIMUser(w, r, employee)
+}
+
+func (h *Handler) writeSCIMUser(w http.ResponseWriter, r *http.Request, employee service.EmployeeDTO) {
+	users, err := h.scimUsers(r, []service.EmployeeDTO{employee})
+	if err != nil {
+		h.respondWithSCIMError(w, r, err)
+		return
+	}
+	user := users[0].(scimUser)
+	w.Header().Set("ETag", user.Meta.Version)
+	writeSCIM(w, http.StatusOK, user)
+}
+
+// handlePatchSCIMUser changes the name and title. The department is changed
+// through group membership; activation follows erasure, which stays in the
+// departments API.
+func (h *Handler) handlePatchSCIMUser(w http.ResponseWriter, r *http.Request, id uint) {
+	expectedVersion, err := parseSCIMIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		h.respondWithSCIMError(w, r, err)
+		return
+	}
+	operations, err := scim.ParsePatch(r.Body)
+	if err != nil {
+		h.respondWithSCIMError(w, r, err)
+		return
+	}
+
+	input := service.UpdateEmployeeInput{ExpectedVersion: expectedVersion}
+	for _, operation := range operations {
+		var target **string
+		switch operation.Path {
+		case "displayname", "name.formatted":
+			target = &input.FullName
+		case "title":
+			target = &input.Position
+		case "active", strings.ToLower(scim.EnterpriseUserSchema) + ":department", "groups":
+			h.respondWithSCIMError(w, r, scim.BadRequest(scim.ErrMutability, "%s cannot be changed on a user", operation.Path))
+			return
+		default:
+			h.respondWithSCIMError(w, r, scim.BadRequest(scim.ErrInvalidPath, "unsupported path %q", operation.Path))
+			return
+		}
+		if operation.Op == scim.PatchRemove {
+			h.respondWithSCIMError(w, r, scim.BadRequest(scim.ErrMutability, "%s is required", operation.Path))
+			return
+		}
+		var value string
+		if err := json.Unmarshal(operation.Value, &value); err != nil {
+			h.respondWithSCIMError(w, r, scim.BadRequest(scim.ErrInvalidValue, "%s must be a string", operation.Path))
+			return
+		}
+		*target = &value
+	}
+
+	employee, err := h.directory.UpdateEmployee(r.Context(), id, input)
+	if err != nil {
+		h.respondWithSCIMError(w, r, err)
+		return
+	}
+	h.writeSCIMUser(w, r, employee)
+}
+
+func (h *Handler) handleListSCIMGroups(w http.ResponseWriter, r *http.Request) {
+	startIndex, count := parseSCIMPage(r)
+	options := service.ListDepartmentsOptions{Offset: startIndex - 1, Limit: count}
+	if raw := strings.TrimSpace(r.URL.Query().Get("filter")); raw != "" {
+		comparisons, err := scim.ParseFilter(raw)
+		if err == nil {
+			err = applyGroupFilter(&options, comparisons)
+		}
+		if err != nil {
+			h.respondWithSCIMError(w, r, err)
+			return
+		}
+	}
+
+	departments, total, err := h.directory.ListDepartments(r.Context(), options)
+	if err != nil {
+		h.respondWithSCIMError(w, r, err)
+		return
+	}
+	var members map[uint][]service.EmployeeDTO
+	withMembers := !excludesAttribute(r, "members")
+	if withMembers && len(departments) > 0 {
+		departmentIDs := make([]uint, 0, len(departments))
+		for _, department := range departments {
+			departmentIDs = append(departmentIDs, department.ID)
+		}
+		if members, err = h.directory.DepartmentMembers(r.Context(), departmentIDs); err != nil {
+			h.respondWithSCIMError(w, r, err)
+			return
+		}
+	}
+	groups := make([]any, 0, len(departments))
+	for _, department := range departments {
+		group := newSCIMGroup(r, department)
+		if withMembers {
+			group.Members = scimMembers(r, members[department.ID])
+		}
+		groups = append(groups, group)
+	}
+	writeSCIM(w, http.StatusOK, scim.NewListResponse(total, startIndex, groups))
+}
+
+func (h *Handler) writeSCIMGroup(w http.ResponseWriter, r *http.Request, id uint) {
+	tree, err := h.directory.GetDepartment(r.Context(), id, service.GetDepartmentOptions{Depth: 0, IncludeEmployees: true})
+	if err != nil {
+		h.respondWithSCIMError(w, r, err)
+		return
+	}
+	group := newSCIMGroup(r, tree.Department)
+	if !excludesAttribute(r, "members") {
+		group.Members = scimMembers(r, *tree.Employees)
+	}
+	w.Header().Set("ETag", group.Meta.Version)
+	writeSCIM(w, http.StatusOK, group)
+}
+
+// handlePatchSCIMGroup renames the department and moves users into it, in
+// one transaction. An employee is always in exactly one department, so
+// members cannot be removed; adding them to another group moves them there.
+func (h *Handler) handlePatchSCIMGroup(w http.ResponseWriter, r *http.Request, id uint) {
+	expectedVersion, err := parseSCIMIfMatch(r.Header.Get("If-Match"))
+	if err != nil {
+		h.respondWithSCIMError(w, r, err)
+		return
+	}
+	operations, err := scim.ParsePatch(r.Body)
+	if err != nil {
+		h.respondWithSCIMError(w, r, err)
+		return
+	}
+
+	var name *string
+	var memberIDs []uint
+	for _, operation := range operations {
+		switch {
+		case operation.Path == "displayname" && operation.Op != scim.PatchRemove:
+			var value string
+			if err := json.Unmarshal(operation.Value, &value); err != nil {
+				h.respondWithSCIMError(w, r, scim.BadRequest(scim.ErrInvalidValue, "displayName must be a string"))
+				return
+			}
+			name = &value
+		case operation.Path == "members" && operation.Op == scim.PatchAdd:
+			var members []scimReference
+			if err := json.Unmarshal(operation.Value, &members); err != nil {
+				h.respondWithSCIMError(w, r, scim.BadRequest(scim.ErrInvalidValue, "members must be a list of {\"value\": id}"))
+				return
+			}
+			for _, member := range members {
+				memberID, err := parseUintID(member.Value)
+				if err != nil {
+					h.respondWithSCIMError(w, r, scim.BadRequest(scim.ErrInvalidValue, "unknown member %q", member.Value))
+					return
+				}
+				memberIDs = append(memberIDs, memberID)
+			}
+		case operation.Path == "displayname" || operation.Path == "members" || strings.HasPrefix(operation.Path, "members["):
+			h.respondWithSCIMError(w, r, scim.BadRequest(scim.ErrMutability, "%s %s is not supported; add users to another group to move them", operation.Op, operation.Path))
+			return
+		default:
+			h.respondWithSCIMError(w, r, scim.BadRequest(scim.ErrInvalidPath, "unsupported path %q", operation.Path))
+			return
+		}
+	}
+
+	input := service.UpdateDepartmentInput{Name: name, EmployeeIDs: memberIDs, ExpectedVersion: expectedVersion}
+	if _, err := h.directory.UpdateDepartment(r.Context(), id, input); err != nil {
+		h.respondWithSCIMError(w, r, err)
+		return
+	}
+	h.writeSCIMGroup(w, r, id)
+}
+
+func (h *Handler) handleSCIMStatic(w http.ResponseWriter, r *http.Request, payload any) {
+	if r.Method != http.MethodGet {
+		writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		return
+	}
+	writeSCIM(w, http.StatusOK, payload)
+}
+
+// scimUsers maps employees to users, loading the names of their departments.
+func (h *Handler) scimUsers(r *http.Request, employees []service.EmployeeDTO) ([]any, error) {
+	users := make([]any, 0, len(employees))
+	if len(employees) == 0 {
+		return users, nil
+	}
+	departmentIDs := make([]uint, 0, len(employees))
+	for _, employee := range employees {
+		departmentIDs = append(departmentIDs, employee.DepartmentID)
+	}
+	departments, _, err := h.directory.ListDepartments(r.Context(), service.ListDepartmentsOptions{IDs: departmentIDs, Limit: len(departmentIDs)})
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[uint]string, len(departments))
+	for _, department := range departments {
+		names[department.ID] = department.Name
+	}
+
+	base := scimBaseURL(r)
+	withGroups := !excludesAttribute(r, "groups")
+	for _, employee := range employees {
+		id := strconv.FormatUint(uint64(employee.ID), 10)
+		user := scimUser{
+			Schemas:     []string{scim.UserSchema, scim.EnterpriseUserSchema},
+			ID:          id,
+			UserName:    id,
+			DisplayName: employee.FullName,
+			Title:       employee.Position,
+			Active:      employee.ErasedAt == nil,
+			Enterprise:  &scimEnterpriseUser{Department: names[employee.DepartmentID]},
+			Meta:        newSCIMMeta("User", base+"/Users/"+id, employee.CreatedAt, employee.Version),
+		}
+		if employee.FullName != "" {
+			user.Name = &scimName{Formatted: employee.FullName}
+		}
+		if withGroups {
+			groupID := strconv.FormatUint(uint64(employee.DepartmentID), 10)
+			user.Groups = []scimReference{{Value: groupID, Display: names[employee.DepartmentID], Ref: base + "/Groups/" + groupID, Type: "direct"}}
+		}
+		users = append(users, user)
+	}
+	return users, nil
+}
+
+func newSCIMGroup(r *http.Request, department service.DepartmentDTO) scimGroup {
+	id := strconv.FormatUint(uint64(department.ID), 10)
+	return scimGroup{
+		Schemas:     []string{scim.GroupSchema},
+		ID:          id,
+		DisplayName: department.Name,
+		Meta:        newSCIMMeta("Group", scimBaseURL(r)+"/Groups/"+id, department.CreatedAt, department.Version),
+	}
+}
+
+// scimMembers lists the employees of a department. Subdepartments are not
+// members: user and group ids overlap, so a member value must mean a user.
+func scimMembers(r *http.Request, employees []service.EmployeeDTO) *[]scimReference {
+	base := scimBaseURL(r)
+	members := make([]scimReference, 0, len(employees))
+	for _, employee := range employees {
+		id := strconv.FormatUint(uint64(employee.ID), 10)
+		members = append(members, scimReference{Value: id, Display: employee.FullName, Ref: base + "/Users/" + id, Type: "User"})
+	}
+	return &members
+}
+
+func newSCIMMeta(resourceType string, location string, created time.Time, version int64) scim.Meta {
+	return scim.Meta{
+		ResourceType: resourceType,
+		Created:      created.UTC().Format(time.RFC3339),
+		Location:     location,
+		Version:      "W/" + formatETag(version),
+	}
+}
+
+// applyUserFilter translates a filter on users. externalId is accepted but
+// never matches, as the service does not store one.
+func applyUserFilter(options *service.ListEmployeesOptions, comparisons []scim.Comparison) error {
+	for _, comparison := range comparisons {
+		switch comparison.Attribute {
+		case "id", "username":
+			ids, err := filterIDs(comparison)
+			if err != nil {
+				return err
+			}
+			options.IDs = intersectIDs(options.IDs, ids)
+		case "groups.value":
+			ids, err := filterIDs(comparison)
+			if err != nil {
+				return err
+			}
+			options.DepartmentIDs = intersectIDs(options.DepartmentIDs, ids)
+		case "externalid":
+			options.IDs = []uint{}
+		case "displayname", "name.formatted":
+			match, err := filterText(comparison)
+			if err != nil {
+				return err
+			}
+			options.FullName = append(options.FullName, match)
+		case "title":
+			match, err := filterText(comparison)
+			if err != nil {
+				return err
+			}
+			options.Position = append(options.Position, match)
+		case "active":
+			active, ok := comparison.Value.(bool)
+			if !ok || comparison.Operator != scim.OpEqual {
+				return scim.BadRequest(scim.ErrInvalidFilter, "active only supports eq true or false")
+			}
+			if options.Active != nil && *options.Active != active {
+				options.IDs = []uint{}
+			}
+			options.Active = &active
+		default:
+			return scim.BadRequest(scim.ErrInvalidFilter, "cannot filter users by %s", comparison.Attribute)
+		}
+	}
+	return nil
+}
+
+func applyGroupFilter(options *service.ListDepartmentsOptions, comparisons []scim.Comparison) error {
+	for _, comparison := range comparisons {
+		switch comparison.Attribute {
+		case "id":
+			ids, err := filterIDs(comparison)
+			if err != nil {
+				return err
+			}
+			options.IDs = intersectIDs(options.IDs, ids)
+		case "externalid":
+			options.IDs = []uint{}
+		case "displayname":
+			match, err := filterText(comparison)
+			if err != nil {
+				return err
+			}
+			options.Name = append(options.Name, match)
+		default:
+			return scim.BadRequest(scim.ErrInvalidFilter, "cannot filter groups by %s", comparison.Attribute)
+		}
+	}
+	return nil
+}
+
+// filterIDs reads an id comparison. An id that is not ours matches nothing.
+func filterIDs(comparison scim.Comparison) ([]uint, error) {
+	value, ok := comparison.Value.(string)
+	if !ok || comparison.Operator != scim.OpEqual {
+		return nil, scim.BadRequest(scim.ErrInvalidFilter, "%s only supports eq with a string", comparison.Attribute)
+	}
+	id, err := parseUintID(value)
+	if err != nil {
+		return []uint{}, nil
+	}
+	return []uint{id}, nil
+}
+
+func filterText(comparison scim.Comparison) (service.TextMatch, error) {
+	value, ok := comparison.Value.(string)
+	if !ok {
+		return service.TextMatch{}, scim.BadRequest(scim.ErrInvalidFilter, "%s must be compared with a string", comparison.Attribute)
+	}
+	op := service.MatchEquals
+	switch comparison.Operator {
+	case scim.OpContains:
+		op = service.MatchContains
+	case scim.OpStartsWith:
+		op = service.MatchStartsWith
+	}
+	return service.TextMatch{Op: op, Value: value}, nil
+}
+
+// intersectIDs combines two id conditions; nil means no condition.
+func intersectIDs(current []uint, ids []uint) []uint {
+	if current == nil {
+		return ids
+	}
+	result := []uint{}
+	for _, id := range ids {
+		for _, existing := range current {
+			if id == existing {
+				result = append(result, id)
+				break
+			}
+		}
+	}
+	return result
+}
+
+// parseSCIMPage reads startIndex and count. Out-of-range values are clamped
+// as RFC 7644 asks instead of rejected.
+func parseSCIMPage(r *http.Request) (startIndex int, count int) {
+	query := r.URL.Query()
+	startIndex, count = 1, scimDefaultCount
+	if parsed, err := strconv.Atoi(strings.TrimSpace(query.Get("startIndex"))); err == nil && parsed > 1 {
+		startIndex = parsed
+	}
+	if parsed, err := strconv.Atoi(strings.TrimSpace(query.Get("count"))); err == nil {
+		count = min(max(parsed, 0), scimMaxCount)
+	}
+	return startIndex, count
+}
+
+func excludesAttribute(r *http.Request, attribute string) bool {
+	for _, excluded := range strings.Split(r.URL.Query().Get("excludedAttributes"), ",") {
+		if strings.EqualFold(strings.TrimSpace(excluded), attribute) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseSCIMIfMatch accepts the weak entity tags of meta.version, unlike the
+// REST API.
+func parseSCIMIfMatch(raw string) (*int64, error) {
+	value := strings.TrimPrefix(strings.TrimSpace(raw), "W/")
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+	version, ok := parseETagVersion(value)
+	if !ok {
+		return nil, scim.BadRequest(scim.ErrInvalidSyntax, "If-Match must be a single entity tag")
+	}
+	return &version, nil
+}
+
+func scimBaseURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil || strings.EqualFold(r.Header.Get("X-Forwarded-Proto"), "https") {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + "/scim/v2"
+}
+
+func scimServiceProviderConfig() map[string]any {
+	supported := func(value bool) map[string]bool { return map[string]bool{"supported": value} }
+	return map[string]any{
+		"schemas":        []string{scim.ServiceProviderConfigSchema},
+		"patch":          supported(true),
+		"bulk":           map[string]any{"supported": false, "maxOperations": 0, "maxPayloadSize": 0},
+		"filter":         map[string]any{"supported": true, "maxResults": scimMaxCount},
+		"changePassword": supported(false),
+		"sort":           supported(false),
+		"etag":           supported(true),
+		"authenticationSchemes": []map[string]any{{
+			"type":        "oauthbearertoken",
+			"name":        "Bearer token",
+			"description": "An API key or a JWT in the Authorization header",
+			"primary":     true,
+		}},
+	}
+}
+
+func scimResourceTypes(r *http.Request) []any {
+	base := scimBaseURL(r)
+	return []any{
+		map[string]any{
+			"schemas":          []string{scim.ResourceTypeSchema},
+			"id":               "User",
+			"name":             "User",
+			"endpoint":         "/Users",
+			"schema":           scim.UserSchema,
+			"schemaExtensions": []map[string]any{{"schema": scim.EnterpriseUserSchema, "required": false}},
+			"meta":             map[string]string{"resourceType": "ResourceType", "location": base + "/ResourceTypes/User"},
+		},
+		map[string]any{
+			"schemas":  []string{scim.ResourceTypeSchema},
+			"id":       "Group",
+			"name":     "Group",
+			"endpoint": "/Groups",
+			"schema":   scim.GroupSchema,
+			"meta":     map[string]string{"resourceType": "ResourceType", "location": base + "/ResourceTypes/Group"},
+		},
+	}
+}
+
+func (h *Handler) respondWithSCIMError(w http.ResponseWriter, r *http.Request, err error) {
+	var scimErr *scim.Error
+	if errors.As(err, &scimErr) {
+		writeSCIMError(w, scimErr)
+		return
+	}
+
+	switch apperror.GetCode(err) {
+	case apperror.CodeValidation:
+		writeSCIMError(w, scim.BadRequest(scim.ErrInvalidValue, "%s", err.Error()))
+	case apperror.CodeNotFound:
+		writeSCIMError(w, &scim.Error{Status: http.StatusNotFound, Detail: err.Error()})
+	case apperror.CodeConflict:
+		writeSCIMError(w, &scim.Error{Status: http.StatusConflict, Detail: err.Error()})
+	case apperror.CodePreconditionFailed:
+		writeSCIMError(w, &scim.Error{Status: http.StatusPreconditionFailed, Detail: err.Error()})
+	case apperror.CodeForbidden:
+		writeSCIMError(w, &scim.Error{Status: http.StatusForbidden, Detail: err.Error()})
+	default:
+		h.logger.ErrorContext(r.Context(), "unexpected error", "error", err)
+		writeSCIMError(w, &scim.Error{Status: http.StatusInternalServerError, Detail: "internal server error"})
+	}
+}
+
+func writeSCIM(w http.ResponseWriter, status int, payload any) {
+	w.Header().Set("Content-Type", scim.ContentType)
+	w.WriteHeader(status)
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func writeSCIMError(w http.ResponseWriter, err *scim.Error) {
+	writeSCIM(w, err.Status, err.Response())
+}
@@ -0,0 +1,236 @@
+package httpapi
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"hitalent-go-task/internal/service"
+)
+
+// WithWebhooks serves the /webhooks subscription management endpoints.
+func WithWebhooks(manager service.WebhookManager) Option {
+	return func(h *Handler) {
+		h.webhooks = manager
+	}
+}
+
+type createWebhookRequest struct {
+	URL          string   `json:"url"`
+	EventTypes   []string `json:"event_types"`
+	DepartmentID *uint    `json:"department_id"`
+}
+
+type updateWebhookRequest struct {
+	URL          *string      `json:"url"`
+	EventTypes   *[]string    `json:"event_types"`
+	DepartmentID optionalUint `json:"department_id"`
+	Active       *bool        `json:"active"`
+	RotateSecret bool         `json:"rotate_secret"`
+}
+
+type webhookListResponse struct {
+	Webhooks []service.WebhookDTO `json:"webhooks"`
+}
+
+type deliveryListResponse struct {
+	Deliveries []service.WebhookDeliveryDTO `json:"deliveries"`
+	// NextBeforeID is the before_id of the next page, if there may be one.
+	NextBeforeID *uint `json:"next_before_id,omitempty"`
+}
+
+func (h *Handler) serveWebhooks(w http.ResponseWriter, r *http.Request, parts []string) {
+	if len(parts) == 1 {
+		switch r.Method {
+		case http.MethodGet:
+			h.handleListWebhooks(w, r)
+		case http.MethodPost:
+			h.serveIdempotent(w, r, h.handleCreateWebhook)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+		return
+	}
+
+	webhookID, err := parseUintID(parts[1])
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid webhook id")
+		return
+	}
+
+	switch {
+	case len(parts) == 2:
+		switch r.Method {
+		case http.MethodGet:
+			h.handleGetWebhook(w, r, webhookID)
+		case http.MethodPatch:
+			h.handleUpdateWebhook(w, r, webhookID)
+		case http.MethodDelete:
+			h.handleDeleteWebhook(w, r, webhookID)
+		default:
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+		}
+
+	case len(parts) == 3 && parts[2] == "deliveries":
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		h.handleListWebhookDeliveries(w, r, webhookID)
+
+	case len(parts) == 5 && parts[2] == "deliveries" && parts[4] == "redeliver":
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "method not allowed")
+			return
+		}
+		deliveryID, err := parseUintID(parts[3])
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "invalid delivery id")
+			return
+		}
+		h.serveIdempotent(w, r, func(w http.ResponseWriter, r *http.Request) {
+			h.handleRedeliverWebhook(w, r, webhookID, deliveryID)
+		})
+
+	default:
+		writeError(w, http.StatusNotFound, "route not found")
+	}
+}
+
+// webhookRouteTemplate mirrors the switch in serveWebhooks.
+func webhookRouteTemplate(parts []string) string {
+	switch {
+	case len(parts) == 1:
+		return "/webhooks"
+	case len(parts) == 2:
+		return "/webhooks/{id}"
+	case len(parts) == 3 && parts[2] == "deliveries":
+		return "/webhooks/{id}/deliveries"
+	case len(parts) == 5 && parts[2] == "deliveries" && parts[4] == "redeliver":
+		return "/webhooks/{id}/deliveries/{delivery_id}/redeliver"
+	}
+	return ""
+}
+
+func (h *Handler) handleListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.webhooks.ListWebhooks(r.Context())
+	if err != nil {
+		h.respondWithError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, webhookListResponse{Webhooks: webhooks})
+}
+
+func (h *Handler) handleCreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var req createWebhookRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhook, err := h.webhooks.CreateWebhook(r.Context(), service.CreateWebhookInput{
+		URL:          req.URL,
+		EventTypes:   req.EventTypes,
+		DepartmentID: req.DepartmentID,
+	})
+	if err != nil {
+		h.respondWithError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusCreated, webhook)
+}
+
+func (h *Handler) handleGetWebhook(w http.ResponseWriter, r *http.Request, webhookID uint) {
+	webhook, err := h.webhooks.GetWebhook(r.Context(), webhookID)
+	if err != nil {
+		h.respondWithError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, webhook)
+}
+
+func (h *Handler) handleUpdateWebhook(w http.ResponseWriter, r *http.Request, webhookID uint) {
+	var req updateWebhookRequest
+	if err := decodeJSON(r, &req); err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	webhook, err := h.webhooks.UpdateWebhook(r.Context(), webhookID, service.UpdateWebhookInput{
+		URL:             req.URL,
+		EventTypes:      req.EventTypes,
+		DepartmentIDSet: req.DepartmentID.Set,
+		DepartmentID:    req.DepartmentID.Value,
+		Active:          req.Active,
+		RotateSecret:    req.RotateSecret,
+	})
+	if err != nil {
+		h.respondWithError(w, r, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, webhook)
+}
+
+func (h *Handler) handleDeleteWebhook(w http.ResponseWriter, r *http.Request, webhookID uint) {
+	if err := h.webhooks.DeleteWebhook(r.Context(), webhookID); err != nil {
+		h.respondWithError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (h *Handler) handleListWebhookDeliveries(w http.ResponseWriter, r *http.Request, webhookID uint) {
+	options, err := parseListDeliveriesOptions(r)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	deliveries, err := h.webhooks.ListWebhookDeliveries(r.Context(), webhookID, options)
+	if err != nil {
+		h.respondWithError(w, r, err)
+		return
+	}
+
+	response := deliveryListResponse{Deliveries: deliveries}
+	if options.Limit > 0 && len(deliveries) == options.Limit {
+		next := deliveries[len(deliveries)-1].ID
+		response.NextBeforeID = &next
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func (h *Handler) handleRedeliverWebhook(w http.ResponseWriter, r *http.Request, webhookID uint, deliveryID uint) {
+	if err := h.webhooks.RedeliverWebhook(r.Context(), webhookID, deliveryID); err != nil {
+		h.respondWithError(w, r, err)
+		return
+	}
+	w.WriteHeader(http.StatusAccepted)
+}
+
+const defaultDeliveryPageSize = 50
+
+func parseListDeliveriesOptions(r *http.Request) (service.ListDeliveriesOptions, error) {
+	query := r.URL.Query()
+	options := service.ListDeliveriesOptions{
+		Status: strings.TrimSpace(strings.ToLower(query.Get("status"))),
+		Limit:  defaultDeliveryPageSize,
+	}
+
+	if raw := strings.TrimSpace(query.Get("limit")); raw != "" {
+		limit, err := strconv.Atoi(raw)
+		if err != nil || limit <= 0 {
+			return service.ListDeliveriesOptions{}, errors.New("limit must be a positive integer")
+		}
+		options.Limit = limit
+	}
+	if raw := strings.TrimSpace(query.Get("before_id")); raw != "" {
+		beforeID, err := parseUintID(raw)
+		if err != nil {
+			return service.ListDeliveriesOptions{}, errors.New("before_id must be a positive integer")
+		}
+		options.BeforeID = beforeID
+	}
+	return options, nil
+}
@@ -0,0 +1,122 @@
+package idempotency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"hitalent-go-task/internal/models"
+)
+
+type GormStore struct {
+	db *gorm.DB
+}
+
+func NewGormStore(db *gorm.DB) *GormStore {
+	return &GormStore{db: db}
+}
+
+func (s *GormStore) Reserve(ctx context.Context, key string, fingerprint string, expiresAt time.Time, leaseExpiresAt time.Time) (Record, bool, error) {
+	// Reservations from before leases existed have none and are taken over.
+	now := time.Now().UTC()
+	if err := s.db.WithContext(ctx).
+		Where("idempotency_key = ?", key).
+		Where("expires_at <= ? OR (status_code IS NULL AND (lease_expires_at IS NULL OR lease_expires_at <= ?))", now, now).
+		Delete(&models.IdempotencyKey{}).Error; err != nil {
+		return Record{}, false, fmt.Errorf("delete expired idempotency key: %w", err)
+	}
+
+	lease := leaseExpiresAt.UTC()
+	token := newLeaseToken()
+	row := models.IdempotencyKey{
+		IdempotencyKey: key,
+		Fingerprint:    fingerprint,
+		ExpiresAt:      expiresAt.UTC(),
+		LeaseExpiresAt: &lease,
+		LeaseToken:     &token,
+	}
+	result := s.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&row)
+	if result.Error != nil {
+		return Record{}, false, fmt.Errorf("reserve idempotency key: %w", result.Error)
+	}
+	if result.RowsAffected == 1 {
+		return rowToRecord(row), true, nil
+	}
+
+	var existing models.IdempotencyKey
+	if err := s.db.WithContext(ctx).Where("idempotency_key = ?", key).First(&existing).Error; err != nil {
+		return Record{}, false, fmt.Errorf("load idempotency key: %w", err)
+	}
+	return rowToRecord(existing), false, nil
+}
+
+func (s *GormStore) Complete(ctx context.Context, key string, leaseToken string, statusCode int, header http.Header, body []byte) error {
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return fmt.Errorf("encode idempotent response headers: %w", err)
+	}
+	result := s.db.WithContext(ctx).
+		Model(&models.IdempotencyKey{}).
+		Where("idempotency_key = ? AND lease_token = ?", key, leaseToken).
+		Updates(map[string]interface{}{
+			"status_code":      statusCode,
+			"response_headers": string(encodedHeader),
+			"response_body":    body,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("complete idempotency key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (s *GormStore) Release(ctx context.Context, key string, leaseToken string) error {
+	result := s.db.WithContext(ctx).
+		Where("idempotency_key = ? AND lease_token = ?", key, leaseToken).
+		Delete(&models.IdempotencyKey{})
+	if result.Error != nil {
+		return fmt.Errorf("release idempotency key: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrLeaseLost
+	}
+	return nil
+}
+
+func (s *GormStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	result := s.db.WithContext(ctx).Where("expires_at <= ?", now.UTC()).Delete(&models.IdempotencyKey{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("delete expired idempotency keys: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+func rowToRecord(row models.IdempotencyKey) Record {
+	record := Record{
+		Key:         row.IdempotencyKey,
+		Fingerprint: row.Fingerprint,
+		Body:        row.ResponseBody,
+		ExpiresAt:   row.ExpiresAt,
+	}
+	if row.StatusCode != nil {
+		record.StatusCode = *row.StatusCode
+	}
+	if row.LeaseExpiresAt != nil {
+		record.LeaseExpiresAt = *row.LeaseExpiresAt
+	}
+	if row.LeaseToken != nil {
+		record.LeaseToken = *row.LeaseToken
+	}
+	if row.ResponseHeaders != nil {
+		// Responses stored before headers were kept replay without them.
+		_ = json.Unmarshal([]byte(*row.ResponseHeaders), &record.Header)
+	}
+	return record
+}
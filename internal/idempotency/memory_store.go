@@ -0,0 +1,88 @@
+package idempotency
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+)
+
+type MemoryStore struct {
+	mu      sync.Mutex
+	records map[string]Record
+	now     func() time.Time
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{
+		records: make(map[string]Record),
+		now:     time.Now,
+	}
+}
+
+func (s *MemoryStore) Reserve(ctx context.Context, key string, fingerprint string, expiresAt time.Time, leaseExpiresAt time.Time) (Record, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now()
+	if existing, ok := s.records[key]; ok && existing.ExpiresAt.After(now) &&
+		(existing.Completed() || existing.LeaseExpiresAt.After(now)) {
+		return cloneRecord(existing), false, nil
+	}
+
+	record := Record{
+		Key:            key,
+		Fingerprint:    fingerprint,
+		ExpiresAt:      expiresAt,
+		LeaseExpiresAt: leaseExpiresAt,
+		LeaseToken:     newLeaseToken(),
+	}
+	s.records[key] = record
+	return record, true, nil
+}
+
+func (s *MemoryStore) Complete(ctx context.Context, key string, leaseToken string, statusCode int, header http.Header, body []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	record, ok := s.records[key]
+	if !ok || record.LeaseToken != leaseToken {
+		return ErrLeaseLost
+	}
+	record.StatusCode = statusCode
+	record.Header = header.Clone()
+	record.Body = append([]byte(nil), body...)
+	s.records[key] = record
+	return nil
+}
+
+func (s *MemoryStore) Release(ctx context.Context, key string, leaseToken string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if record, ok := s.records[key]; !ok || record.LeaseToken != leaseToken {
+		return ErrLeaseLost
+	}
+	delete(s.records, key)
+	return nil
+}
+
+func (s *MemoryStore) DeleteExpired(ctx context.Context, now time.Time) (int64, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var deleted int64
+	for key, record := range s.records {
+		if !record.ExpiresAt.After(now) {
+			delete(s.records, key)
+			deleted++
+		}
+	}
+	return deleted, nil
+}
+
+func cloneRecord(record Record) Record {
+	record.Header = record.Header.Clone()
+	record.Body = append([]byte(nil), record.Body...)
+	return record
+}
@@ -0,0 +1,50 @@
+package idempotency
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// ErrLeaseLost is returned by Complete and Release when the reservation was
+// taken over by a retry after its lease passed.
+var ErrLeaseLost = errors.New("idempotency lease lost")
+
+type Record struct {
+	Key         string
+	Fingerprint string
+	StatusCode  int
+	// Header holds the response headers that are replayed with the body.
+	Header    http.Header
+	Body      []byte
+	ExpiresAt time.Time
+	// LeaseExpiresAt is when an unfinished reservation may be taken over,
+	// e.g. because the server handling it stopped.
+	LeaseExpiresAt time.Time
+	// LeaseToken identifies the reservation; only its holder may complete or
+	// release the key.
+	LeaseToken string
+}
+
+// Completed reports whether the original request has finished and its response is stored.
+func (r Record) Completed() bool {
+	return r.StatusCode != 0
+}
+
+type Store interface {
+	// Reserve claims the key for a new request. When the key is already known and
+	// not expired, the existing record is returned with reserved=false. An
+	// unfinished reservation whose lease has passed is taken over.
+	Reserve(ctx context.Context, key string, fingerprint string, expiresAt time.Time, leaseExpiresAt time.Time) (record Record, reserved bool, err error)
+	// Complete and Release act only on the reservation holding leaseToken and
+	// return ErrLeaseLost otherwise.
+	Complete(ctx context.Context, key string, leaseToken string, statusCode int, header http.Header, body []byte) error
+	Release(ctx context.Context, key string, leaseToken string) error
+	DeleteExpired(ctx context.Context, now time.Time) (int64, error)
+}
+
+func newLeaseToken() string {
+	return rand.Text()
+}
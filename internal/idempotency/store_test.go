@@ -0,0 +1,64 @@
+package idempotency
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"hitalent-go-task/internal/config"
+	"hitalent-go-task/internal/db"
+)
+
+func TestStoresRejectLostLeases(t *testing.T) {
+	database, err := db.Connect(config.Config{
+		DatabaseDriver: config.DriverSQLite,
+		DatabaseURL:    "sqlite://" + filepath.Join(t.TempDir(), "idempotency.db"),
+	}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	migrator, err := db.NewMigrator(database, config.DriverSQLite)
+	if err != nil {
+		t.Fatalf("create migrator: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+
+	for name, store := range map[string]Store{"memory": NewMemoryStore(), "gorm": NewGormStore(database)} {
+		t.Run(name, func(t *testing.T) {
+			ctx := context.Background()
+			now := time.Now()
+			stale, reserved, err := store.Reserve(ctx, "import-42", "fingerprint", now.Add(time.Hour), now.Add(-time.Second))
+			if err != nil || !reserved {
+				t.Fatalf("reserve: %v, reserved %v", err, reserved)
+			}
+			current, reserved, err := store.Reserve(ctx, "import-42", "fingerprint", now.Add(time.Hour), now.Add(time.Minute))
+			if err != nil || !reserved || current.LeaseToken == stale.LeaseToken {
+				t.Fatalf("expected the stale reservation to be taken over: %v, reserved %v", err, reserved)
+			}
+
+			// The request that lost its lease must not touch the new reservation.
+			if err := store.Complete(ctx, "import-42", stale.LeaseToken, http.StatusCreated, nil, []byte("stale")); !errors.Is(err, ErrLeaseLost) {
+				t.Fatalf("expected ErrLeaseLost from Complete, got %v", err)
+			}
+			if err := store.Release(ctx, "import-42", stale.LeaseToken); !errors.Is(err, ErrLeaseLost) {
+				t.Fatalf("expected ErrLeaseLost from Release, got %v", err)
+			}
+			if record, reserved, err := store.Reserve(ctx, "import-42", "fingerprint", now.Add(time.Hour), now.Add(time.Minute)); err != nil || reserved || record.Completed() {
+				t.Fatalf("expected the new reservation to stay in progress, got %+v, reserved %v, %v", record, reserved, err)
+			}
+
+			if err := store.Complete(ctx, "import-42", current.LeaseToken, http.StatusCreated, nil, []byte("current")); err != nil {
+				t.Fatalf("complete: %v", err)
+			}
+			if record, _, err := store.Reserve(ctx, "import-42", "fingerprint", now.Add(time.Hour), now.Add(time.Minute)); err != nil || string(record.Body) != "current" {
+				t.Fatalf("expected the current response, got %q, %v", record.Body, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,116 @@
+// Package ldif writes LDAP Data Interchange Format content records
+// (RFC 2849) and builds distinguished names (RFC 4514).
+package ldif
+
+import (
+	"encoding/base64"
+	"io"
+	"strings"
+	"unicode/utf8"
+)
+
+// ContentType is the media type commonly used for LDIF files.
+const ContentType = "text/x-ldif; charset=utf-8"
+
+// maxLineLength is where long lines are folded, as RFC 2849 recommends.
+const maxLineLength = 76
+
+type Attribute struct {
+	Name   string
+	Values []string
+}
+
+type Entry struct {
+	DN         string
+	Attributes []Attribute
+}
+
+// Writer writes entries one by one, so that large exports are streamed.
+type Writer struct {
+	w       io.Writer
+	started bool
+}
+
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: w}
+}
+
+func (w *Writer) Write(entry Entry) error {
+	var b strings.Builder
+	if w.started {
+		b.WriteByte('\n')
+	} else {
+		b.WriteString("version: 1\n\n")
+		w.started = true
+	}
+	writeLine(&b, "dn", entry.DN)
+	for _, attribute := range entry.Attributes {
+		for _, value := range attribute.Values {
+			writeLine(&b, attribute.Name, value)
+		}
+	}
+	_, err := io.WriteString(w.w, b.String())
+	return err
+}
+
+// writeLine writes "name: value", base64-encoding values that are not safe
+// strings (non-ASCII, or starting with a space, colon or "<", or ending
+// with a space), and folds lines longer than maxLineLength.
+func writeLine(b *strings.Builder, name string, value string) {
+	line := name + ": " + value
+	if !isSafeString(value) {
+		line = name + ":: " + base64.StdEncoding.EncodeToString([]byte(value))
+	}
+	// Continuation lines start with a space, which counts towards the length.
+	for width := maxLineLength; len(line) > width; width = maxLineLength - 1 {
+		b.WriteString(line[:width])
+		b.WriteString("\n ")
+		line = line[width:]
+	}
+	b.WriteString(line)
+	b.WriteByte('\n')
+}
+
+func isSafeString(value string) bool {
+	if value == "" {
+		return true
+	}
+	switch value[0] {
+	case ' ', ':', '<':
+		return false
+	}
+	if value[len(value)-1] == ' ' {
+		return false
+	}
+	for i := 0; i < len(value); i++ {
+		if c := value[i]; c == 0 || c == '\n' || c == '\r' || c >= utf8.RuneSelf {
+			return false
+		}
+	}
+	return true
+}
+
+// RDN returns "attribute=value" with the value escaped for use in a DN.
+func RDN(attribute string, value string) string {
+	return attribute + "=" + EscapeDNValue(value)
+}
+
+// EscapeDNValue escapes the characters RFC 4514 requires in an attribute
+// value of a DN. Other characters, including non-ASCII ones, stay as they are.
+func EscapeDNValue(value string) string {
+	var b strings.Builder
+	for i := 0; i < len(value); i++ {
+		c := value[i]
+		switch {
+		case c == 0:
+			b.WriteString(`\00`)
+			continue
+		case strings.IndexByte(`"+,;<>\`, c) >= 0,
+			i == 0 && (c == ' ' || c == '#'),
+			i == len(value)-1 && c == ' ':
+			b.WriteByte('\\')
+		}
+		b.WriteByte(c)
+	}
+	return b.String()
+}
@@ -0,0 +1,54 @@
+package ldif
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestWriter(t *testing.T) {
+	var b strings.Builder
+	w := NewWriter(&b)
+	entries := []Entry{
+		{DN: "ou=Backend,dc=example,dc=com", Attributes: []Attribute{{Name: "objectClass", Values: []string{"top", "organizationalUnit"}}, {Name: "ou", Values: []string{"Backend"}}}},
+		{DN: "uid=7,ou=Backend,dc=example,dc=com", Attributes: []Attribute{{Name: "cn", Values: []string{"Иван Петров"}}, {Name: "title", Values: []string{":lead "}}}},
+		{DN: "ou=" + strings.Repeat("a", 80)},
+	}
+	for _, entry := range entries {
+		if err := w.Write(entry); err != nil {
+			t.Fatalf("write entry: %v", err)
+		}
+	}
+
+	want := "version: 1\n\n" +
+		"dn: ou=Backend,dc=example,dc=com\n" +
+		"objectClass: top\n" +
+		"objectClass: organizationalUnit\n" +
+		"ou: Backend\n" +
+		"\n" +
+		"dn: uid=7,ou=Backend,dc=example,dc=com\n" +
+		"cn:: 0JjQstCw0L0g0J/QtdGC0YDQvtCy\n" +
+		"title:: OmxlYWQg\n" +
+		"\n" +
+		"dn: ou=" + strings.Repeat("a", 69) + "\n" +
+		" " + strings.Repeat("a", 11) + "\n"
+	if b.String() != want {
+		t.Fatalf("unexpected LDIF:\n%s\nwant:\n%s", b.String(), want)
+	}
+}
+
+func TestEscapeDNValue(t *testing.T) {
+	cases := map[string]string{
+		"Backend":      "Backend",
+		"R&D, Moscow":  `R&D\, Moscow`,
+		" #1 ":         `\ #1\ `,
+		"#hash":        `\#hash`,
+		`a+b="c";<d>\`: `a\+b=\"c\"\;\<d\>\\`,
+		"Отдел продаж": "Отдел продаж",
+		"nul\x00byte":  `nul\00byte`,
+	}
+	for value, want := range cases {
+		if got := EscapeDNValue(value); got != want {
+			t.Fatalf("EscapeDNValue(%q) = %q, want %q", value, got, want)
+		}
+	}
+}
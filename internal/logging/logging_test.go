@@ -0,0 +1,34 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestLoggerAddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	logger, err := New(&buf, slog.LevelDebug, FormatJSON)
+	if err != nil {
+		t.Fatalf("new logger: %v", err)
+	}
+
+	logger.With("component", "test").InfoContext(WithRequestID(context.Background(), "req-42"), "hello")
+
+	var record map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("decode log line %q: %v", buf.String(), err)
+	}
+	if record["request_id"] != "req-42" || record["component"] != "test" {
+		t.Fatalf("unexpected record %v", record)
+	}
+
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Fatalf("expected unknown level to be rejected")
+	}
+	if _, err := New(&buf, slog.LevelInfo, "xml"); err == nil {
+		t.Fatalf("expected unknown format to be rejected")
+	}
+}
@@ -0,0 +1,33 @@
+package metrics
+
+import "github.com/prometheus/client_golang/prometheus"
+
+// CacheStatsFunc returns the number of hits and misses since start.
+type CacheStatsFunc func() (hits uint64, misses uint64)
+
+type cacheCollector struct {
+	stats  CacheStatsFunc
+	hits   *prometheus.Desc
+	misses *prometheus.Desc
+}
+
+// NewCacheCollector exports the hit and miss counters of the named cache.
+func NewCacheCollector(name string, stats CacheStatsFunc) prometheus.Collector {
+	labels := prometheus.Labels{"cache": name}
+	return &cacheCollector{
+		stats:  stats,
+		hits:   prometheus.NewDesc("cache_hits_total", "Cache lookups served from the cache.", nil, labels),
+		misses: prometheus.NewDesc("cache_misses_total", "Cache lookups that fell through to the database.", nil, labels),
+	}
+}
+
+func (c *cacheCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.hits
+	ch <- c.misses
+}
+
+func (c *cacheCollector) Collect(ch chan<- prometheus.Metric) {
+	hits, misses := c.stats()
+	ch <- prometheus.MustNewConstMetric(c.hits, prometheus.CounterValue, float64(hits))
+	ch <- prometheus.MustNewConstMetric(c.misses, prometheus.CounterValue, float64(misses))
+}
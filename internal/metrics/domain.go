@@ -0,0 +1,50 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// CountFunc returns the current number of departments and employees.
+type CountFunc func(ctx context.Context) (departments int64, employees int64, err error)
+
+type domainCollector struct {
+	count       CountFunc
+	timeout     time.Duration
+	departments *prometheus.Desc
+	employees   *prometheus.Desc
+	up          *prometheus.Desc
+}
+
+// NewDomainCollector queries the totals on every scrape, so the gauges never go stale.
+func NewDomainCollector(count CountFunc) prometheus.Collector {
+	return &domainCollector{
+		count:       count,
+		timeout:     5 * time.Second,
+		departments: prometheus.NewDesc("departments_total", "Number of departments.", nil, nil),
+		employees:   prometheus.NewDesc("employees_total", "Number of employees, including erased ones.", nil, nil),
+		up:          prometheus.NewDesc("domain_stats_up", "Whether the last domain statistics query succeeded.", nil, nil),
+	}
+}
+
+func (c *domainCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- c.departments
+	ch <- c.employees
+	ch <- c.up
+}
+
+func (c *domainCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), c.timeout)
+	defer cancel()
+
+	departments, employees, err := c.count(ctx)
+	if err != nil {
+		ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 0)
+		return
+	}
+	ch <- prometheus.MustNewConstMetric(c.up, prometheus.GaugeValue, 1)
+	ch <- prometheus.MustNewConstMetric(c.departments, prometheus.GaugeValue, float64(departments))
+	ch <- prometheus.MustNewConstMetric(c.employees, prometheus.GaugeValue, float64(employees))
+}
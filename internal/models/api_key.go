@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type APIKey struct {
+	ID      uint   `gorm:"primaryKey"`
+	Name    string `gorm:"type:varchar(100);not null;uniqueIndex"`
+	KeyHash string `gorm:"type:char(64);not null;uniqueIndex"`
+	Role    string `gorm:"type:varchar(20);not null"`
+	// ScopeDepartmentID is not a foreign key on purpose: when the department is
+	// deleted the key loses access instead of widening to the whole tree.
+	ScopeDepartmentID *uint
+	CreatedAt         time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	RevokedAt         *time.Time
+}
@@ -0,0 +1,12 @@
+package models
+
+import "time"
+
+type AuditEntry struct {
+	ID         uint      `gorm:"primaryKey"`
+	Actor      string    `gorm:"type:varchar(255);not null"`
+	Action     string    `gorm:"type:varchar(100);not null"`
+	EntityType string    `gorm:"type:varchar(50);not null"`
+	EntityID   uint      `gorm:"not null"`
+	CreatedAt  time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
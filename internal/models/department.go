@@ -9,5 +9,6 @@ type Department struct {
 	Parent    *Department  `gorm:"foreignKey:ParentID;references:ID"`
 	Children  []Department `gorm:"foreignKey:ParentID;references:ID"`
 	Employees []Employee   `gorm:"foreignKey:DepartmentID;references:ID"`
+	Version   int64        `gorm:"not null;default:1"`
 	CreatedAt time.Time    `gorm:"not null;default:CURRENT_TIMESTAMP"`
 }
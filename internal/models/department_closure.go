@@ -0,0 +1,9 @@
+package models
+
+// DepartmentClosure stores one row per (ancestor, descendant) pair, including
+// the department itself at depth 0.
+type DepartmentClosure struct {
+	AncestorID   uint `gorm:"primaryKey"`
+	DescendantID uint `gorm:"primaryKey;index"`
+	Depth        int  `gorm:"not null"`
+}
@@ -9,5 +9,7 @@ type Employee struct {
 	FullName     string     `gorm:"type:varchar(200);not null"`
 	Position     string     `gorm:"type:varchar(200);not null"`
 	HiredAt      *time.Time `gorm:"type:date"`
-	CreatedAt    time.Time  `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	Version      int64      `gorm:"not null;default:1"`
+	ErasedAt     *time.Time
+	CreatedAt    time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
 }
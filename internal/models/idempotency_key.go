@@ -0,0 +1,15 @@
+package models
+
+import "time"
+
+type IdempotencyKey struct {
+	IdempotencyKey  string    `gorm:"primaryKey;type:varchar(255)"`
+	Fingerprint     string    `gorm:"type:char(64);not null"`
+	StatusCode      *int      `gorm:"type:int"`
+	ResponseHeaders *string   `gorm:"type:text"`
+	ResponseBody    []byte    `gorm:"type:bytea"`
+	CreatedAt       time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	ExpiresAt       time.Time `gorm:"not null;index"`
+	LeaseExpiresAt  *time.Time
+	LeaseToken      *string `gorm:"type:varchar(32)"`
+}
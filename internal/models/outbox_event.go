@@ -0,0 +1,26 @@
+package models
+
+import "time"
+
+// OutboxEvent is a change event written in the same transaction as the
+// change itself and published later by the outbox relay.
+type OutboxEvent struct {
+	ID         uint   `gorm:"primaryKey"`
+	EventType  string `gorm:"type:varchar(100);not null"`
+	EntityType string `gorm:"type:varchar(50);not null"`
+	EntityID   uint   `gorm:"not null"`
+	Actor      string `gorm:"type:varchar(255);not null"`
+	Payload    string `gorm:"type:text;not null"`
+	// Scope lists the departments whose subtrees the event concerns as
+	// ",1,4,7,": the ancestry of the entity, before and after a move.
+	Scope         string    `gorm:"type:text;not null;default:''"`
+	CreatedAt     time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	PublishedAt   *time.Time
+	Attempts      int `gorm:"not null;default:0"`
+	NextAttemptAt *time.Time
+	// FeedPosition is assigned by the feed in commit order.
+	FeedPosition *uint
+	// ClaimedUntil is set while a relay is publishing the event.
+	ClaimedUntil *time.Time
+	LastError    string `gorm:"type:text;not null;default:''"`
+}
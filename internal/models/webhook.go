@@ -0,0 +1,49 @@
+package models
+
+import "time"
+
+// WebhookSubscription sends the change events matching EventTypes (a comma
+// separated list, empty for all) to URL.
+type WebhookSubscription struct {
+	ID         uint   `gorm:"primaryKey"`
+	URL        string `gorm:"column:url;type:varchar(2048);not null"`
+	Secret     string `gorm:"type:varchar(255);not null"`
+	EventTypes string `gorm:"type:text;not null;default:''"`
+	// DepartmentID limits the subscription to events within this subtree. Like
+	// APIKey.ScopeDepartmentID it is not a foreign key: a deleted department
+	// silences the subscription instead of widening it to the whole tree.
+	DepartmentID *uint
+	Active       bool      `gorm:"not null;default:true"`
+	CreatedBy    string    `gorm:"type:varchar(255);not null"`
+	CreatedAt    time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	UpdatedAt    time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+}
+
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookDelivery is one outbox event queued for one subscription. The event
+// is copied so that it outlives the outbox retention; Payload holds its data.
+type WebhookDelivery struct {
+	ID             uint      `gorm:"primaryKey"`
+	SubscriptionID uint      `gorm:"not null"`
+	EventID        uint      `gorm:"not null"`
+	EventType      string    `gorm:"type:varchar(100);not null"`
+	EntityType     string    `gorm:"type:varchar(50);not null"`
+	EntityID       uint      `gorm:"not null"`
+	Actor          string    `gorm:"type:varchar(255);not null"`
+	OccurredAt     time.Time `gorm:"not null"`
+	Payload        string    `gorm:"type:text;not null"`
+	Status         string    `gorm:"type:varchar(20);not null;default:'pending'"`
+	Attempts       int       `gorm:"not null;default:0"`
+	NextAttemptAt  *time.Time
+	// ClaimedUntil is set while an instance is sending the delivery.
+	ClaimedUntil   *time.Time
+	LastStatusCode int       `gorm:"not null;default:0"`
+	LastError      string    `gorm:"type:text;not null;default:''"`
+	CreatedAt      time.Time `gorm:"not null;default:CURRENT_TIMESTAMP"`
+	DeliveredAt    *time.Time
+}
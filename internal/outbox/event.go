@@ -0,0 +1,69 @@
+package outbox
+
+import (
+	"encoding/json"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"hitalent-go-task/internal/models"
+)
+
+// Event is the envelope delivered to sinks. ID grows with every event, so
+// consumers can use it to drop duplicates of an at-least-once delivery.
+type Event struct {
+	ID         uint            `json:"id"`
+	Type       string          `json:"type"`
+	EntityType string          `json:"entity_type"`
+	EntityID   uint            `json:"entity_id"`
+	Actor      string          `json:"actor"`
+	OccurredAt time.Time       `json:"occurred_at"`
+	Data       json.RawMessage `json:"data"`
+	// Scope lists the departments whose subtrees the event belongs to. It is
+	// used for routing and not published.
+	Scope []uint `json:"-"`
+	// Position orders the events by commit for the feed; zero until the
+	// feed has numbered the event.
+	Position uint `json:"-"`
+}
+
+// InScope reports whether the event belongs to the subtree of departmentID.
+func (e Event) InScope(departmentID uint) bool {
+	return slices.Contains(e.Scope, departmentID)
+}
+
+// Key identifies the entity; events with the same key are delivered in order.
+func (e Event) Key() string {
+	return e.EntityType + ":" + strconv.FormatUint(uint64(e.EntityID), 10)
+}
+
+func eventFromRow(row models.OutboxEvent) Event {
+	var position uint
+	if row.FeedPosition != nil {
+		position = *row.FeedPosition
+	}
+	return Event{
+		ID:         row.ID,
+		Type:       row.EventType,
+		EntityType: row.EntityType,
+		EntityID:   row.EntityID,
+		Actor:      row.Actor,
+		OccurredAt: row.CreatedAt.UTC(),
+		Data:       json.RawMessage(row.Payload),
+		Scope:      parseScope(row.Scope),
+		Position:   position,
+	}
+}
+
+// parseScope decodes models.OutboxEvent.Scope, e.g. ",1,4,7,".
+func parseScope(raw string) []uint {
+	var scope []uint
+	for _, part := range strings.Split(strings.Trim(raw, ","), ",") {
+		id, err := strconv.ParseUint(part, 10, 64)
+		if err == nil {
+			scope = append(scope, uint(id))
+		}
+	}
+	return scope
+}
@@ -0,0 +1,313 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/models"
+)
+
+const (
+	defaultFeedInterval   = time.Second
+	defaultFeedBufferSize = 256
+	feedBatchSize         = 500
+)
+
+// sequenceLockKey is the PostgreSQL advisory lock held while positions are
+// assigned, so that instances do not hand out the same ones.
+const sequenceLockKey = 0x73657175656e6365
+
+var (
+	// ErrSubscriberTooSlow ends a subscription whose buffer overflowed; the
+	// subscriber can resume from its last event with ReadEvents.
+	ErrSubscriberTooSlow = errors.New("subscriber too slow")
+	ErrFeedClosed        = errors.New("event feed closed")
+)
+
+// Feed follows the outbox table and hands new events to in-process
+// subscribers, so that any number of streams cost one query per interval.
+//
+// Ids are taken when a transaction inserts its event, not when it commits, so
+// a later id can become visible first, however late the earlier one commits.
+// The feed therefore orders events by position instead: every poll numbers
+// the events that have become visible since the last one, so positions
+// follow the commit order and a reader that has seen a position has seen
+// everything before it.
+type Feed struct {
+	db         *gorm.DB
+	logger     *slog.Logger
+	interval   time.Duration
+	bufferSize int
+
+	ready       chan struct{}
+	mu          sync.Mutex
+	head        uint
+	closed      bool
+	subscribers map[*Subscription]struct{}
+}
+
+type FeedOption func(*Feed)
+
+// WithFeedInterval sets how often the table is polled.
+func WithFeedInterval(interval time.Duration) FeedOption {
+	return func(f *Feed) {
+		f.interval = interval
+	}
+}
+
+func NewFeed(db *gorm.DB, logger *slog.Logger, opts ...FeedOption) *Feed {
+	f := &Feed{
+		db:          db,
+		logger:      logger,
+		interval:    defaultFeedInterval,
+		bufferSize:  defaultFeedBufferSize,
+		ready:       make(chan struct{}),
+		subscribers: make(map[*Subscription]struct{}),
+	}
+	for _, opt := range opts {
+		opt(f)
+	}
+	return f
+}
+
+// Subscription receives the events after Head in position order. Earlier
+// events are read from the table with ReadEvents.
+type Subscription struct {
+	events chan Event
+	head   uint
+	err    error
+}
+
+// Events is closed when the subscription ends; Err tells why.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) Head() uint {
+	return s.head
+}
+
+func (s *Subscription) Err() error {
+	return s.err
+}
+
+// Head returns the position up to which events are handed out.
+func (f *Feed) Head() uint {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.head
+}
+
+// Subscribe waits until the feed knows the current head and registers a
+// subscriber for the events after it.
+func (f *Feed) Subscribe(ctx context.Context) (*Subscription, error) {
+	select {
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	case <-f.ready:
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		return nil, ErrFeedClosed
+	}
+	subscription := &Subscription{events: make(chan Event, f.bufferSize), head: f.head}
+	f.subscribers[subscription] = struct{}{}
+	return subscription, nil
+}
+
+func (f *Feed) Unsubscribe(subscription *Subscription) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if _, ok := f.subscribers[subscription]; ok {
+		delete(f.subscribers, subscription)
+		close(subscription.events)
+	}
+}
+
+// Run polls the table until ctx is cancelled and then ends all subscriptions.
+func (f *Feed) Run(ctx context.Context) {
+	defer f.close()
+
+	for {
+		err := f.start(ctx)
+		if err == nil {
+			break
+		}
+		if ctx.Err() != nil {
+			return
+		}
+		f.logger.Error("event feed start failed", "error", err)
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(f.interval):
+		}
+	}
+
+	timer := time.NewTimer(f.interval)
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		more, err := f.poll(ctx)
+		if err != nil && ctx.Err() == nil {
+			f.logger.Error("event feed poll failed", "error", err)
+		}
+		if more && err == nil {
+			timer.Reset(0)
+		} else {
+			timer.Reset(f.interval)
+		}
+	}
+}
+
+// start skips the events already stored: subscribers read those with
+// ReadEvents.
+func (f *Feed) start(ctx context.Context) error {
+	var head uint
+	if err := f.db.WithContext(ctx).Model(&models.OutboxEvent{}).Select("COALESCE(MAX(feed_position), 0)").Scan(&head).Error; err != nil {
+		return fmt.Errorf("load outbox head: %w", err)
+	}
+	f.mu.Lock()
+	f.head = head
+	f.mu.Unlock()
+	close(f.ready)
+	return nil
+}
+
+func (f *Feed) poll(ctx context.Context) (more bool, err error) {
+	sequenced, err := f.sequence(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	f.mu.Lock()
+	head := f.head
+	f.mu.Unlock()
+
+	events, err := f.ReadEvents(ctx, head, feedBatchSize)
+	if err != nil {
+		return false, err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for _, event := range events {
+		f.head = event.Position
+		f.broadcast(event)
+	}
+	return sequenced == feedBatchSize || len(events) == feedBatchSize, nil
+}
+
+// sequence gives the next positions to the committed events that have none,
+// in id order. Only committed events are visible here, so an event that
+// commits late gets a position after everything handed out before.
+func (f *Feed) sequence(ctx context.Context) (int, error) {
+	var ids []uint
+	err := f.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if tx.Dialector.Name() == "postgres" {
+			if err := tx.Exec("SELECT pg_advisory_xact_lock(?)", sequenceLockKey).Error; err != nil {
+				return fmt.Errorf("acquire outbox sequence lock: %w", err)
+			}
+		}
+		if err := tx.Model(&models.OutboxEvent{}).
+			Where("feed_position IS NULL").
+			Order("id").
+			Limit(feedBatchSize).
+			Pluck("id", &ids).Error; err != nil {
+			return fmt.Errorf("load unsequenced outbox events: %w", err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+		var position uint
+		if err := tx.Model(&models.OutboxEvent{}).Select("COALESCE(MAX(feed_position), 0)").Scan(&position).Error; err != nil {
+			return fmt.Errorf("load outbox position: %w", err)
+		}
+		for _, id := range ids {
+			position++
+			if err := tx.Model(&models.OutboxEvent{}).Where("id = ?", id).Update("feed_position", position).Error; err != nil {
+				return fmt.Errorf("sequence outbox event: %w", err)
+			}
+		}
+		return nil
+	})
+	return len(ids), err
+}
+
+// broadcast must be called with mu held. A full buffer ends the subscription
+// rather than blocking the feed for everyone.
+func (f *Feed) broadcast(event Event) {
+	for subscription := range f.subscribers {
+		select {
+		case subscription.events <- event:
+		default:
+			subscription.err = ErrSubscriberTooSlow
+			delete(f.subscribers, subscription)
+			close(subscription.events)
+		}
+	}
+}
+
+func (f *Feed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.closed = true
+	for subscription := range f.subscribers {
+		subscription.err = ErrFeedClosed
+		delete(f.subscribers, subscription)
+		close(subscription.events)
+	}
+	select {
+	case <-f.ready:
+	default:
+		close(f.ready)
+	}
+}
+
+// ReadEvents returns up to limit sequenced events after the position in
+// position order.
+func (f *Feed) ReadEvents(ctx context.Context, afterPosition uint, limit int) ([]Event, error) {
+	var rows []models.OutboxEvent
+	if err := f.db.WithContext(ctx).
+		Where("feed_position > ?", afterPosition).
+		Order("feed_position").
+		Limit(limit).
+		Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("load outbox events: %w", err)
+	}
+	events := make([]Event, 0, len(rows))
+	for _, row := range rows {
+		events = append(events, eventFromRow(row))
+	}
+	return events, nil
+}
+
+// Retained reports whether the events after the position are all still
+// stored. Purge keeps the newest event, so a position beyond it means nothing
+// was missed; positions have no gaps otherwise.
+func (f *Feed) Retained(ctx context.Context, afterPosition uint) (bool, error) {
+	var bounds struct {
+		Oldest uint
+		Newest uint
+	}
+	if err := f.db.WithContext(ctx).
+		Model(&models.OutboxEvent{}).
+		Select("COALESCE(MIN(feed_position), 0) AS oldest, COALESCE(MAX(feed_position), 0) AS newest").
+		Scan(&bounds).Error; err != nil {
+		return false, fmt.Errorf("load outbox bounds: %w", err)
+	}
+	return bounds.Newest <= afterPosition || bounds.Oldest <= afterPosition+1, nil
+}
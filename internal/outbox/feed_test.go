@@ -0,0 +1,145 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/models"
+)
+
+func insertEvent(t *testing.T, database *gorm.DB, id uint) {
+	t.Helper()
+	event := models.OutboxEvent{ID: id, EventType: "department.renamed", EntityType: "department", EntityID: 1, Actor: "system", Payload: "{}"}
+	if err := database.Create(&event).Error; err != nil {
+		t.Fatalf("insert event %d: %v", id, err)
+	}
+}
+
+func receivedEvents(subscription *Subscription) []Event {
+	var events []Event
+	for {
+		select {
+		case event, ok := <-subscription.Events():
+			if !ok {
+				return events
+			}
+			events = append(events, event)
+		default:
+			return events
+		}
+	}
+}
+
+func received(subscription *Subscription) []uint {
+	var ids []uint
+	for _, event := range receivedEvents(subscription) {
+		ids = append(ids, event.ID)
+	}
+	return ids
+}
+
+func TestFeedOrdersByCommit(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	insertEvent(t, database, 1)
+
+	feed := NewFeed(database, slog.New(slog.DiscardHandler))
+	if _, err := feed.sequence(ctx); err != nil {
+		t.Fatalf("sequence: %v", err)
+	}
+	if err := feed.start(ctx); err != nil {
+		t.Fatalf("start feed: %v", err)
+	}
+	subscription, err := feed.Subscribe(ctx)
+	if err != nil || subscription.Head() != 1 {
+		t.Fatalf("expected a subscription after position 1, got %+v, %v", subscription, err)
+	}
+
+	// Event 2 is still uncommitted when event 3 becomes visible; event 3 is
+	// handed out without waiting.
+	insertEvent(t, database, 3)
+	if _, err := feed.poll(ctx); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	// Event 2 commits much later, e.g. after serialization retries. It comes
+	// after event 3 instead of being skipped.
+	insertEvent(t, database, 2)
+	if _, err := feed.poll(ctx); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+
+	var ids, positions []uint
+	for _, event := range receivedEvents(subscription) {
+		ids = append(ids, event.ID)
+		positions = append(positions, event.Position)
+	}
+	if len(ids) != 2 || ids[0] != 3 || ids[1] != 2 || positions[0] != 2 || positions[1] != 3 {
+		t.Fatalf("expected events 3 and 2 at positions 2 and 3, got %v at %v", ids, positions)
+	}
+
+	// A reader that had seen position 2 still gets the late event.
+	events, err := feed.ReadEvents(ctx, 2, 10)
+	if err != nil || len(events) != 1 || events[0].ID != 2 {
+		t.Fatalf("expected the late event after position 2, got %+v, %v", events, err)
+	}
+}
+
+func TestFeedDropsSlowSubscriber(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	feed := NewFeed(database, slog.New(slog.DiscardHandler))
+	feed.bufferSize = 1
+	if err := feed.start(ctx); err != nil {
+		t.Fatalf("start feed: %v", err)
+	}
+	slow, _ := feed.Subscribe(ctx)
+
+	insertEvent(t, database, 1)
+	insertEvent(t, database, 2)
+	if _, err := feed.poll(ctx); err != nil {
+		t.Fatalf("poll: %v", err)
+	}
+	if ids := received(slow); len(ids) != 1 || !errors.Is(slow.Err(), ErrSubscriberTooSlow) {
+		t.Fatalf("expected the subscription to end after one event, got %v, %v", ids, slow.Err())
+	}
+	// The feed itself goes on.
+	fast, _ := feed.Subscribe(ctx)
+	insertEvent(t, database, 3)
+	_, _ = feed.poll(ctx)
+	if ids := received(fast); len(ids) != 1 || ids[0] != 3 {
+		t.Fatalf("expected event 3, got %v", ids)
+	}
+}
+
+func TestFeedRetained(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	feed := NewFeed(database, slog.New(slog.DiscardHandler))
+	for id := uint(1); id <= 3; id++ {
+		insertEvent(t, database, id)
+	}
+	if _, err := feed.sequence(ctx); err != nil {
+		t.Fatalf("sequence: %v", err)
+	}
+	if err := database.Delete(&models.OutboxEvent{}, 1).Error; err != nil {
+		t.Fatalf("purge event: %v", err)
+	}
+
+	if retained, err := feed.Retained(ctx, 0); err != nil || retained {
+		t.Fatalf("event 1 is gone, got %v, %v", retained, err)
+	}
+	if retained, err := feed.Retained(ctx, 1); err != nil || !retained {
+		t.Fatalf("events after 1 are stored, got %v, %v", retained, err)
+	}
+	if retained, err := feed.Retained(ctx, 3); err != nil || !retained {
+		t.Fatalf("nothing after the newest event can be lost, got %v, %v", retained, err)
+	}
+	events, err := feed.ReadEvents(ctx, 1, 10)
+	if err != nil || len(events) != 2 || events[0].ID != 2 {
+		t.Fatalf("expected events 2 and 3, got %+v, %v", events, err)
+	}
+}
@@ -0,0 +1,257 @@
+package outbox
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/models"
+)
+
+const (
+	defaultBatchSize  = 100
+	defaultInterval   = time.Second
+	defaultMaxBackoff = 5 * time.Minute
+	initialBackoff    = time.Second
+	// claimTimeout is how long an event stays claimed by an instance that
+	// stopped before recording the result; it must outlive a publish.
+	claimTimeout = 5 * time.Minute
+)
+
+// Relay publishes outbox events in id order. Delivery is at least once: an
+// event is marked published only after the sink accepted it. When an event
+// fails, the following events of the same entity wait until it succeeds;
+// events of other entities keep flowing.
+//
+// With several server instances every relay publishes. An event is claimed
+// before it is published, and a claimed event holds back the entity's later
+// ones everywhere, so the order holds across instances without keeping a
+// transaction open while the sink is called.
+type Relay struct {
+	db         *gorm.DB
+	sink       Sink
+	logger     *slog.Logger
+	batchSize  int
+	interval   time.Duration
+	maxBackoff time.Duration
+	now        func() time.Time
+}
+
+type RelayOption func(*Relay)
+
+// WithBatchSize limits the events attempted per pass.
+func WithBatchSize(size int) RelayOption {
+	return func(r *Relay) {
+		r.batchSize = size
+	}
+}
+
+// WithInterval sets the pause between passes when nothing is pending.
+func WithInterval(interval time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.interval = interval
+	}
+}
+
+// WithMaxBackoff caps the exponential delay between retries of an event.
+func WithMaxBackoff(backoff time.Duration) RelayOption {
+	return func(r *Relay) {
+		r.maxBackoff = backoff
+	}
+}
+
+func NewRelay(db *gorm.DB, sink Sink, logger *slog.Logger, opts ...RelayOption) *Relay {
+	r := &Relay{
+		db:         db,
+		sink:       sink,
+		logger:     logger,
+		batchSize:  defaultBatchSize,
+		interval:   defaultInterval,
+		maxBackoff: defaultMaxBackoff,
+		now:        time.Now,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+	return r
+}
+
+// Run publishes pending events until ctx is cancelled.
+func (r *Relay) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		_, more, err := r.flush(ctx)
+		if err != nil && ctx.Err() == nil {
+			r.logger.Error("outbox relay failed", "error", err)
+		}
+		if more && err == nil {
+			timer.Reset(0)
+		} else {
+			timer.Reset(r.interval)
+		}
+	}
+}
+
+// Flush makes one pass over the pending events and returns how many were
+// published.
+func (r *Relay) Flush(ctx context.Context) (int, error) {
+	published, _, err := r.flush(ctx)
+	return published, err
+}
+
+// flush attempts up to batchSize events. Events of entities that wait for a
+// retry are skipped without counting, so a failing entity cannot starve the
+// others. Every result is committed on its own.
+func (r *Relay) flush(ctx context.Context) (published int, more bool, err error) {
+	now := r.now().UTC()
+	blocked := make(map[string]bool)
+	attempted := 0
+	var cursor uint
+
+	for attempted < r.batchSize {
+		var rows []models.OutboxEvent
+		if err := r.db.WithContext(ctx).
+			Where("published_at IS NULL AND id > ?", cursor).
+			Order("id").
+			Limit(r.batchSize).
+			Find(&rows).Error; err != nil {
+			return published, false, fmt.Errorf("load outbox events: %w", err)
+		}
+		if len(rows) == 0 {
+			return published, false, nil
+		}
+
+		for _, row := range rows {
+			cursor = row.ID
+			event := eventFromRow(row)
+			if blocked[event.Key()] {
+				continue
+			}
+			if row.NextAttemptAt != nil && row.NextAttemptAt.After(now) {
+				blocked[event.Key()] = true
+				continue
+			}
+
+			claimed, err := r.claim(ctx, row, now)
+			if err != nil {
+				return published, false, err
+			}
+			if !claimed {
+				// Another instance is publishing it, or has just retried it.
+				blocked[event.Key()] = true
+				continue
+			}
+
+			attempted++
+			// The result is recorded even if the pass is being cancelled.
+			recordCtx := context.WithoutCancel(ctx)
+			if err := r.sink.Publish(ctx, event); err != nil {
+				if ctx.Err() != nil {
+					r.release(recordCtx, row)
+					return published, false, ctx.Err()
+				}
+				blocked[event.Key()] = true
+				if err := r.scheduleRetry(recordCtx, row, err); err != nil {
+					return published, false, err
+				}
+				continue
+			}
+			if err := r.db.WithContext(recordCtx).
+				Model(&models.OutboxEvent{}).
+				Where("id = ?", row.ID).
+				Updates(map[string]any{"published_at": r.now().UTC(), "claimed_until": nil}).Error; err != nil {
+				return published, false, fmt.Errorf("mark outbox event published: %w", err)
+			}
+			published++
+			if attempted == r.batchSize {
+				return published, true, nil
+			}
+		}
+	}
+	return published, true, nil
+}
+
+// claim takes the event unless it is claimed elsewhere or no longer due.
+func (r *Relay) claim(ctx context.Context, row models.OutboxEvent, now time.Time) (bool, error) {
+	result := r.db.WithContext(ctx).
+		Model(&models.OutboxEvent{}).
+		Where("id = ? AND published_at IS NULL", row.ID).
+		Where("claimed_until IS NULL OR claimed_until <= ?", now).
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Update("claimed_until", now.Add(claimTimeout))
+	if result.Error != nil {
+		return false, fmt.Errorf("claim outbox event: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// release gives up a claim without an attempt, e.g. on shutdown.
+func (r *Relay) release(ctx context.Context, row models.OutboxEvent) {
+	if err := r.db.WithContext(ctx).
+		Model(&models.OutboxEvent{}).
+		Where("id = ?", row.ID).
+		Update("claimed_until", nil).Error; err != nil {
+		r.logger.Warn("release outbox event", "event_id", row.ID, "error", err)
+	}
+}
+
+func (r *Relay) scheduleRetry(ctx context.Context, row models.OutboxEvent, cause error) error {
+	attempts := row.Attempts + 1
+	retryAt := r.now().UTC().Add(r.backoff(attempts))
+	r.logger.Warn("outbox event delivery failed",
+		"event_id", row.ID,
+		"type", row.EventType,
+		"attempts", attempts,
+		"retry_at", retryAt,
+		"error", cause,
+	)
+	if err := r.db.WithContext(ctx).
+		Model(&models.OutboxEvent{}).
+		Where("id = ?", row.ID).
+		Updates(map[string]any{
+			"attempts":        attempts,
+			"next_attempt_at": retryAt,
+			"last_error":      cause.Error(),
+			"claimed_until":   nil,
+		}).Error; err != nil {
+		return fmt.Errorf("schedule outbox retry: %w", err)
+	}
+	return nil
+}
+
+// backoff doubles the delay with every failed attempt up to maxBackoff.
+func (r *Relay) backoff(attempts int) time.Duration {
+	delay := initialBackoff
+	for i := 1; i < attempts && delay < r.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, r.maxBackoff)
+}
+
+// Purge deletes published events created before the cutoff. Pending events
+// are deleted too when includePending is set, i.e. no relay will ever
+// publish them. The newest sequenced event is always kept, and events without
+// a position are never deleted: the feed has not handed them out yet.
+func Purge(ctx context.Context, db *gorm.DB, before time.Time, includePending bool) (int64, error) {
+	newest := db.Model(&models.OutboxEvent{}).Select("MAX(feed_position)")
+	query := db.WithContext(ctx).Where("created_at < ? AND feed_position < (?)", before.UTC(), newest)
+	if !includePending {
+		query = query.Where("published_at IS NOT NULL")
+	}
+	result := query.Delete(&models.OutboxEvent{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("purge outbox events: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
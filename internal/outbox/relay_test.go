@@ -0,0 +1,151 @@
+package outbox
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/config"
+	"hitalent-go-task/internal/db"
+	"hitalent-go-task/internal/models"
+)
+
+func openTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+	database, err := db.Connect(config.Config{
+		DatabaseDriver: config.DriverSQLite,
+		DatabaseURL:    "sqlite://" + filepath.Join(t.TempDir(), "outbox.db"),
+	}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	migrator, err := db.NewMigrator(database, config.DriverSQLite)
+	if err != nil {
+		t.Fatalf("create migrator: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+	return database
+}
+
+type recordingSink struct {
+	fail      map[uint]int
+	published []uint
+}
+
+func (s *recordingSink) Publish(_ context.Context, event Event) error {
+	if s.fail[event.ID] > 0 {
+		s.fail[event.ID]--
+		return errors.New("sink unavailable")
+	}
+	s.published = append(s.published, event.ID)
+	return nil
+}
+
+func TestRelayKeepsPerEntityOrder(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	for _, entityID := range []uint{1, 1, 2} {
+		event := models.OutboxEvent{EventType: "department.renamed", EntityType: "department", EntityID: entityID, Actor: "system", Payload: "{}"}
+		if err := database.Create(&event).Error; err != nil {
+			t.Fatalf("insert event: %v", err)
+		}
+	}
+
+	sink := &recordingSink{fail: map[uint]int{1: 1}}
+	relay := NewRelay(database, sink, slog.New(slog.DiscardHandler))
+	now := time.Now()
+	relay.now = func() time.Time { return now }
+
+	if published, err := relay.Flush(ctx); err != nil || published != 1 {
+		t.Fatalf("expected only the other entity's event, got %d, %v", published, err)
+	}
+	var failed models.OutboxEvent
+	if err := database.First(&failed, 1).Error; err != nil || failed.Attempts != 1 || failed.LastError == "" {
+		t.Fatalf("expected the failure to be recorded, got %+v, %v", failed, err)
+	}
+	if published, _ := relay.Flush(ctx); published != 0 {
+		t.Fatalf("the retry must wait for its backoff, published %d", published)
+	}
+
+	now = now.Add(initialBackoff)
+	if published, err := relay.Flush(ctx); err != nil || published != 2 {
+		t.Fatalf("expected the retried events, got %d, %v", published, err)
+	}
+	if len(sink.published) != 3 || sink.published[0] != 3 || sink.published[1] != 1 || sink.published[2] != 2 {
+		t.Fatalf("unexpected delivery order %v", sink.published)
+	}
+
+	// Only events the feed has handed out are purged.
+	if _, err := NewFeed(database, slog.New(slog.DiscardHandler)).sequence(ctx); err != nil {
+		t.Fatalf("sequence: %v", err)
+	}
+	removed, err := Purge(ctx, database, now.Add(time.Hour), false)
+	if err != nil || removed != 2 {
+		t.Fatalf("expected 2 published events purged, got %d, %v", removed, err)
+	}
+	var newest models.OutboxEvent
+	if err := database.First(&newest, 3).Error; err != nil {
+		t.Fatalf("expected the newest event to be kept: %v", err)
+	}
+}
+
+func TestRelaySkipsEventsClaimedElsewhere(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	for _, entityID := range []uint{1, 1, 2} {
+		event := models.OutboxEvent{EventType: "department.renamed", EntityType: "department", EntityID: entityID, Actor: "system", Payload: "{}"}
+		if err := database.Create(&event).Error; err != nil {
+			t.Fatalf("insert event: %v", err)
+		}
+	}
+	// Another instance is publishing the first event of entity 1.
+	now := time.Now().UTC()
+	if err := database.Model(&models.OutboxEvent{}).Where("id = ?", 1).Update("claimed_until", now.Add(time.Minute)).Error; err != nil {
+		t.Fatalf("claim event: %v", err)
+	}
+
+	sink := &recordingSink{}
+	relay := NewRelay(database, sink, slog.New(slog.DiscardHandler))
+	relay.now = func() time.Time { return now }
+	if published, err := relay.Flush(ctx); err != nil || published != 1 {
+		t.Fatalf("expected only the other entity's event, got %d, %v", published, err)
+	}
+
+	// The instance stopped; its claim expires and the entity goes on in order.
+	now = now.Add(claimTimeout)
+	if published, err := relay.Flush(ctx); err != nil || published != 2 {
+		t.Fatalf("expected the expired claim to be taken over, got %d, %v", published, err)
+	}
+	if len(sink.published) != 3 || sink.published[0] != 3 || sink.published[1] != 1 || sink.published[2] != 2 {
+		t.Fatalf("unexpected delivery order %v", sink.published)
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	status := http.StatusServiceUnavailable
+	var eventID string
+	receiver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		eventID = r.Header.Get("X-Event-ID")
+		w.WriteHeader(status)
+	}))
+	defer receiver.Close()
+
+	sink := NewWebhookSink(receiver.URL, receiver.Client())
+	event := Event{ID: 7, Type: "employee.created", EntityType: "employee", EntityID: 3, Data: []byte(`{}`)}
+	if err := sink.Publish(context.Background(), event); err == nil {
+		t.Fatalf("expected an error for status %d", status)
+	}
+	status = http.StatusNoContent
+	if err := sink.Publish(context.Background(), event); err != nil || eventID != "7" {
+		t.Fatalf("expected delivery of event 7, got %q, %v", eventID, err)
+	}
+}
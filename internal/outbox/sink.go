@@ -0,0 +1,121 @@
+package outbox
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sync"
+)
+
+// Sink publishes events. An error makes the relay retry the event later and
+// hold back the following events of the same entity.
+type Sink interface {
+	Publish(ctx context.Context, event Event) error
+}
+
+// MultiSink publishes every event to all its sinks in order. A failing sink
+// makes the relay retry the event on all of them, so the others may see it
+// twice.
+type MultiSink []Sink
+
+func (m MultiSink) Publish(ctx context.Context, event Event) error {
+	for _, sink := range m {
+		if err := sink.Publish(ctx, event); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// FileSink appends events as JSON lines; meant for local testing.
+type FileSink struct {
+	mu   sync.Mutex
+	file *os.File
+}
+
+func NewFileSink(path string) (*FileSink, error) {
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("open outbox file: %w", err)
+	}
+	return &FileSink{file: file}, nil
+}
+
+func (s *FileSink) Publish(_ context.Context, event Event) error {
+	line, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.file.Write(append(line, '\n'))
+	return err
+}
+
+func (s *FileSink) Close() error {
+	return s.file.Close()
+}
+
+// WebhookSink POSTs every event as JSON to a fixed URL and expects a 2xx
+// response. X-Event-ID lets the receiver drop redelivered events.
+type WebhookSink struct {
+	url    string
+	client *http.Client
+}
+
+func NewWebhookSink(url string, client *http.Client) *WebhookSink {
+	return &WebhookSink{url: url, client: client}
+}
+
+func (s *WebhookSink) Publish(ctx context.Context, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("X-Event-ID", fmt.Sprint(event.ID))
+	request.Header.Set("X-Event-Type", event.Type)
+
+	response, err := s.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(response.Body, 64<<10))
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		return fmt.Errorf("webhook responded with status %d", response.StatusCode)
+	}
+	return nil
+}
+
+// Publisher is the part of a NATS or Kafka client the BrokerSink needs. The
+// key is the entity key: partitioning by it keeps per-entity order on Kafka.
+type Publisher interface {
+	Publish(ctx context.Context, topic string, key string, value []byte) error
+}
+
+// BrokerSink publishes events to a message broker topic (a subject on NATS).
+type BrokerSink struct {
+	publisher Publisher
+	topic     string
+}
+
+func NewBrokerSink(publisher Publisher, topic string) *BrokerSink {
+	return &BrokerSink{publisher: publisher, topic: topic}
+}
+
+func (s *BrokerSink) Publish(ctx context.Context, event Event) error {
+	value, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	return s.publisher.Publish(ctx, s.topic, event.Key(), value)
+}
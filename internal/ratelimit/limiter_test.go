@@ -0,0 +1,31 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimiter(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	limiter := NewLimiter(2, 3)
+	limiter.now = func() time.Time { return now }
+
+	for i := 0; i < 3; i++ {
+		if decision := limiter.Allow("importer"); !decision.Allowed || decision.Remaining != 2-i {
+			t.Fatalf("request %d: unexpected decision %+v", i, decision)
+		}
+	}
+
+	denied := limiter.Allow("importer")
+	if denied.Allowed || denied.RetryAfter != 500*time.Millisecond || denied.Reset != 1500*time.Millisecond {
+		t.Fatalf("expected denial with retry after 500ms, got %+v", denied)
+	}
+	if other := limiter.Allow("viewer"); !other.Allowed {
+		t.Fatalf("expected separate bucket per key, got %+v", other)
+	}
+
+	now = now.Add(500 * time.Millisecond)
+	if decision := limiter.Allow("importer"); !decision.Allowed || decision.Remaining != 0 {
+		t.Fatalf("expected one refilled token, got %+v", decision)
+	}
+}
@@ -0,0 +1,21 @@
+package gormrepo
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/models"
+)
+
+type auditRepository struct {
+	db *gorm.DB
+}
+
+func (r auditRepository) Record(ctx context.Context, entry *models.AuditEntry) error {
+	if err := r.db.WithContext(ctx).Create(entry).Error; err != nil {
+		return fmt.Errorf("record audit entry: %w", err)
+	}
+	return nil
+}
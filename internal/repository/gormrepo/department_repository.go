@@ -0,0 +1,272 @@
+package gormrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+)
+
+type departmentRepository struct {
+	db *gorm.DB
+}
+
+func (r departmentRepository) Get(ctx context.Context, id uint) (models.Department, error) {
+	var department models.Department
+	if err := r.db.WithContext(ctx).First(&department, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return models.Department{}, repository.ErrNotFound
+		}
+		return models.Department{}, fmt.Errorf("load department: %w", err)
+	}
+	return department, nil
+}
+
+func (r departmentRepository) Exists(ctx context.Context, id uint) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).Model(&models.Department{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("check department existence: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r departmentRepository) List(ctx context.Context, filter repository.DepartmentFilter) ([]models.Department, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.Department{})
+	if filter.SubtreeOf != nil {
+		query = query.Where("id IN (?)", subtreeIDs(r.db, *filter.SubtreeOf))
+	}
+	if filter.RootsOnly {
+		query = query.Where("parent_id IS NULL")
+	}
+	if filter.IDs != nil {
+		query = query.Where("id IN ?", filter.IDs)
+	}
+	query = whereTextMatches(query, "name", filter.Name)
+
+	departments, total, err := findPage[models.Department](query, filter.Offset, filter.Limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list departments: %w", err)
+	}
+	return departments, total, nil
+}
+
+func (r departmentRepository) ListDescendants(ctx context.Context, id uint, maxDepth int) ([]models.Department, error) {
+	query := r.db.WithContext(ctx).
+		Model(&models.Department{}).
+		Joins("JOIN department_closures c ON c.descendant_id = departments.id").
+		Where("c.ancestor_id = ? AND c.depth > 0", id)
+	if maxDepth >= 0 {
+		query = query.Where("c.depth <= ?", maxDepth)
+	}
+
+	var descendants []models.Department
+	if err := query.Order("departments.name ASC").Find(&descendants).Error; err != nil {
+		return nil, fmt.Errorf("load subtree: %w", err)
+	}
+	return descendants, nil
+}
+
+func (r departmentRepository) ListDescendantsPage(ctx context.Context, id uint, maxDepth int, after repository.DescendantCursor, limit int) ([]repository.DescendantNode, error) {
+	query := r.db.WithContext(ctx).
+		Model(&models.Department{}).
+		Select("departments.*, c.depth").
+		Joins("JOIN department_closures c ON c.descendant_id = departments.id").
+		Where("c.ancestor_id = ? AND c.depth > 0", id).
+		Where("(c.depth > ? OR (c.depth = ? AND departments.id > ?))", after.Depth, after.Depth, after.ID)
+	if maxDepth >= 0 {
+		query = query.Where("c.depth <= ?", maxDepth)
+	}
+
+	var rows []struct {
+		models.Department
+		Depth int
+	}
+	if err := query.Order("c.depth ASC, departments.id ASC").Limit(limit).Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("load subtree page: %w", err)
+	}
+
+	nodes := make([]repository.DescendantNode, 0, len(rows))
+	for _, row := range rows {
+		nodes = append(nodes, repository.DescendantNode{Department: row.Department, Depth: row.Depth})
+	}
+	return nodes, nil
+}
+
+func (r departmentRepository) AncestorIDs(ctx context.Context, id uint) ([]uint, error) {
+	var ids []uint
+	if err := r.db.WithContext(ctx).
+		Model(&models.DepartmentClosure{}).
+		Where("descendant_id = ?", id).
+		Order("depth ASC").
+		Pluck("ancestor_id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("load parent chain: %w", err)
+	}
+	return ids, nil
+}
+
+func (r departmentRepository) IsAncestor(ctx context.Context, ancestorID uint, descendantID uint) (bool, error) {
+	var count int64
+	if err := r.db.WithContext(ctx).
+		Model(&models.DepartmentClosure{}).
+		Where("ancestor_id = ? AND descendant_id = ?", ancestorID, descendantID).
+		Count(&count).Error; err != nil {
+		return false, fmt.Errorf("check ancestry: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r departmentRepository) SiblingNameExists(ctx context.Context, parentID *uint, name string, excludeID *uint) (bool, error) {
+	query := r.db.WithContext(ctx).Model(&models.Department{}).Where("LOWER(name) = LOWER(?)", name)
+	if parentID == nil {
+		query = query.Where("parent_id IS NULL")
+	} else {
+		query = query.Where("parent_id = ?", *parentID)
+	}
+	if excludeID != nil {
+		query = query.Where("id <> ?", *excludeID)
+	}
+
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, fmt.Errorf("check sibling uniqueness: %w", err)
+	}
+	return count > 0, nil
+}
+
+func (r departmentRepository) Create(ctx context.Context, department *models.Department) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Create(department).Error; err != nil {
+		return mapDatabaseError(err)
+	}
+
+	self := models.DepartmentClosure{AncestorID: department.ID, DescendantID: department.ID}
+	if err := db.Create(&self).Error; err != nil {
+		return fmt.Errorf("insert department closure: %w", err)
+	}
+	if department.ParentID == nil {
+		return nil
+	}
+
+	if err := db.Exec(`
+		INSERT INTO department_closures (ancestor_id, descendant_id, depth)
+		SELECT ancestor_id, CAST(? AS BIGINT), depth + 1 FROM department_closures WHERE descendant_id = ?`,
+		department.ID, *department.ParentID,
+	).Error; err != nil {
+		return fmt.Errorf("insert department closure: %w", err)
+	}
+	return nil
+}
+
+func (r departmentRepository) Update(ctx context.Context, id uint, expectedVersion int64, changes repository.DepartmentChanges) (bool, error) {
+	updates := map[string]interface{}{
+		"version": gorm.Expr("version + 1"),
+	}
+	if changes.Name != nil {
+		updates["name"] = *changes.Name
+	}
+	if changes.ParentIDSet {
+		updates["parent_id"] = changes.ParentID
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&models.Department{}).
+		Where("id = ? AND version = ?", id, expectedVersion).
+		Updates(updates)
+	if result.Error != nil {
+		return false, mapDatabaseError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return false, nil
+	}
+
+	if changes.ParentIDSet {
+		if err := r.moveSubtree(ctx, id, changes.ParentID); err != nil {
+			return false, err
+		}
+	}
+	return true, nil
+}
+
+func (r departmentRepository) ReparentChildren(ctx context.Context, fromParentID uint, toParentID *uint) error {
+	var childIDs []uint
+	if err := r.db.WithContext(ctx).
+		Model(&models.Department{}).
+		Where("parent_id = ?", fromParentID).
+		Pluck("id", &childIDs).Error; err != nil {
+		return fmt.Errorf("load child departments: %w", err)
+	}
+	if len(childIDs) == 0 {
+		return nil
+	}
+
+	if err := r.db.WithContext(ctx).
+		Model(&models.Department{}).
+		Where("id IN ?", childIDs).
+		Updates(map[string]interface{}{
+			"parent_id": toParentID,
+			"version":   gorm.Expr("version + 1"),
+		}).Error; err != nil {
+		return mapDatabaseError(err)
+	}
+
+	for _, childID := range childIDs {
+		if err := r.moveSubtree(ctx, childID, toParentID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// moveSubtree rewires closure rows after the department got a new parent:
+// paths from outside the subtree are dropped and rebuilt through newParentID.
+func (r departmentRepository) moveSubtree(ctx context.Context, id uint, newParentID *uint) error {
+	db := r.db.WithContext(ctx)
+	if err := db.Exec(`
+		DELETE FROM department_closures
+		WHERE descendant_id IN (SELECT descendant_id FROM department_closures WHERE ancestor_id = ?)
+		  AND ancestor_id NOT IN (SELECT descendant_id FROM department_closures WHERE ancestor_id = ?)`,
+		id, id,
+	).Error; err != nil {
+		return fmt.Errorf("detach department closure: %w", err)
+	}
+
+	if newParentID == nil {
+		return nil
+	}
+
+	if err := db.Exec(`
+		INSERT INTO department_closures (ancestor_id, descendant_id, depth)
+		SELECT above.ancestor_id, below.descendant_id, above.depth + below.depth + 1
+		FROM department_closures above
+		JOIN department_closures below ON below.ancestor_id = ?
+		WHERE above.descendant_id = ?`,
+		id, *newParentID,
+	).Error; err != nil {
+		return fmt.Errorf("attach department closure: %w", err)
+	}
+	return nil
+}
+
+func (r departmentRepository) Delete(ctx context.Context, id uint) error {
+	if err := r.db.WithContext(ctx).Delete(&models.Department{}, id).Error; err != nil {
+		return mapDatabaseError(err)
+	}
+	return nil
+}
+
+func (r departmentRepository) BumpVersions(ctx context.Context, ids ...uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	if err := r.db.WithContext(ctx).Model(&models.Department{}).
+		Where("id IN ?", ids).
+		UpdateColumn("version", gorm.Expr("version + 1")).Error; err != nil {
+		return fmt.Errorf("bump department versions: %w", err)
+	}
+	return nil
+}
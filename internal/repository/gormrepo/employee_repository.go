@@ -0,0 +1,131 @@
+package gormrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+)
+
+type employeeRepository struct {
+	db *gorm.DB
+}
+
+func (r employeeRepository) Get(ctx context.Context, id uint) (models.Employee, error) {
+	var employee models.Employee
+	err := r.db.WithContext(ctx).First(&employee, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.Employee{}, repository.ErrNotFound
+	}
+	if err != nil {
+		return models.Employee{}, fmt.Errorf("load employee: %w", err)
+	}
+	return employee, nil
+}
+
+func (r employeeRepository) List(ctx context.Context, filter repository.EmployeeFilter) ([]models.Employee, int64, error) {
+	query := r.db.WithContext(ctx).Model(&models.Employee{})
+	if filter.SubtreeOf != nil {
+		query = query.Where("department_id IN (?)", subtreeIDs(r.db, *filter.SubtreeOf))
+	}
+	if filter.IDs != nil {
+		query = query.Where("id IN ?", filter.IDs)
+	}
+	if filter.DepartmentIDs != nil {
+		query = query.Where("department_id IN ?", filter.DepartmentIDs)
+	}
+	query = whereTextMatches(query, "full_name", filter.FullName)
+	query = whereTextMatches(query, "position", filter.Position)
+	if filter.Erased != nil {
+		if *filter.Erased {
+			query = query.Where("erased_at IS NOT NULL")
+		} else {
+			query = query.Where("erased_at IS NULL")
+		}
+	}
+
+	employees, total, err := findPage[models.Employee](query, filter.Offset, filter.Limit)
+	if err != nil {
+		return nil, 0, fmt.Errorf("list employees: %w", err)
+	}
+	return employees, total, nil
+}
+
+func (r employeeRepository) Create(ctx context.Context, employee *models.Employee) error {
+	if err := r.db.WithContext(ctx).Create(employee).Error; err != nil {
+		return mapDatabaseError(err)
+	}
+	return nil
+}
+
+func (r employeeRepository) Update(ctx context.Context, id uint, expectedVersion int64, changes repository.EmployeeChanges) (bool, error) {
+	updates := map[string]interface{}{
+		"version": gorm.Expr("version + 1"),
+	}
+	if changes.FullName != nil {
+		updates["full_name"] = *changes.FullName
+	}
+	if changes.Position != nil {
+		updates["position"] = *changes.Position
+	}
+	if changes.DepartmentID != nil {
+		updates["department_id"] = *changes.DepartmentID
+	}
+
+	result := r.db.WithContext(ctx).
+		Model(&models.Employee{}).
+		Where("id = ? AND version = ?", id, expectedVersion).
+		Updates(updates)
+	if result.Error != nil {
+		return false, mapDatabaseError(result.Error)
+	}
+	return result.RowsAffected > 0, nil
+}
+
+func (r employeeRepository) ListByDepartments(ctx context.Context, departmentIDs []uint) ([]models.Employee, error) {
+	if len(departmentIDs) == 0 {
+		return nil, nil
+	}
+
+	var employees []models.Employee
+	if err := r.db.WithContext(ctx).
+		Where("department_id IN ?", departmentIDs).
+		Order("full_name ASC").
+		Find(&employees).Error; err != nil {
+		return nil, fmt.Errorf("load employees: %w", err)
+	}
+	return employees, nil
+}
+
+func (r employeeRepository) Reassign(ctx context.Context, fromDepartmentID uint, toDepartmentID uint) error {
+	if err := r.db.WithContext(ctx).
+		Model(&models.Employee{}).
+		Where("department_id = ?", fromDepartmentID).
+		Updates(map[string]interface{}{
+			"department_id": toDepartmentID,
+			"version":       gorm.Expr("version + 1"),
+		}).Error; err != nil {
+		return mapDatabaseError(err)
+	}
+	return nil
+}
+
+func (r employeeRepository) Anonymize(ctx context.Context, id uint, placeholderName string, erasedAt time.Time) error {
+	if err := r.db.WithContext(ctx).
+		Model(&models.Employee{}).
+		Where("id = ?", id).
+		Updates(map[string]interface{}{
+			"full_name": placeholderName,
+			"hired_at":  nil,
+			"erased_at": erasedAt.UTC(),
+			"version":   gorm.Expr("version + 1"),
+		}).Error; err != nil {
+		return fmt.Errorf("anonymize employee: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,49 @@
+package gormrepo
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+)
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// whereTextMatches compares column case-insensitively with LOWER, which the
+// SQLite driver replaces with a Unicode-aware version.
+func whereTextMatches(query *gorm.DB, column string, matches []repository.TextMatch) *gorm.DB {
+	for _, match := range matches {
+		switch match.Op {
+		case repository.MatchContains:
+			query = query.Where("LOWER("+column+`) LIKE LOWER(?) ESCAPE '\'`, "%"+likeEscaper.Replace(match.Value)+"%")
+		case repository.MatchStartsWith:
+			query = query.Where("LOWER("+column+`) LIKE LOWER(?) ESCAPE '\'`, likeEscaper.Replace(match.Value)+"%")
+		default:
+			query = query.Where("LOWER("+column+") = LOWER(?)", match.Value)
+		}
+	}
+	return query
+}
+
+// subtreeIDs selects the ids of the department and its descendants.
+func subtreeIDs(db *gorm.DB, departmentID uint) *gorm.DB {
+	return db.Model(&models.DepartmentClosure{}).Select("descendant_id").Where("ancestor_id = ?", departmentID)
+}
+
+// findPage counts the rows matched by query and loads the requested page.
+func findPage[T any](query *gorm.DB, offset int, limit int) ([]T, int64, error) {
+	var total int64
+	if err := query.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+	var rows []T
+	if limit == 0 || int64(offset) >= total {
+		return rows, total, nil
+	}
+	if err := query.Order("id ASC").Offset(offset).Limit(limit).Find(&rows).Error; err != nil {
+		return nil, 0, err
+	}
+	return rows, total, nil
+}
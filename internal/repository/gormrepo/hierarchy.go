@@ -0,0 +1,75 @@
+package gormrepo
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+)
+
+// expectedClosureCTE derives closure rows from parent_id. The depth guard keeps
+// the recursion finite even if parent_id data contains a cycle.
+const expectedClosureCTE = `
+	WITH RECURSIVE expected AS (
+		SELECT id AS ancestor_id, id AS descendant_id, 0 AS depth FROM departments
+		UNION ALL
+		SELECT e.ancestor_id, d.id, e.depth + 1
+		FROM expected e JOIN departments d ON d.parent_id = e.descendant_id
+		WHERE e.depth < (SELECT COUNT(*) FROM departments)
+	)`
+
+type HierarchyReport struct {
+	Departments int64
+	// Missing counts closure rows implied by parent_id but absent from the table.
+	Missing int64
+	// Stale counts closure rows that parent_id does not justify (or with a wrong depth).
+	Stale int64
+}
+
+func (r HierarchyReport) Consistent() bool {
+	return r.Missing == 0 && r.Stale == 0
+}
+
+func (s *Store) CheckHierarchy(ctx context.Context) (HierarchyReport, error) {
+	var report HierarchyReport
+	err := s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("departments").Count(&report.Departments).Error; err != nil {
+			return fmt.Errorf("count departments: %w", err)
+		}
+
+		if err := tx.Raw(expectedClosureCTE + `
+			SELECT COUNT(*) FROM (
+				SELECT ancestor_id, descendant_id, depth FROM expected
+				EXCEPT
+				SELECT ancestor_id, descendant_id, depth FROM department_closures
+			) missing`).Scan(&report.Missing).Error; err != nil {
+			return fmt.Errorf("count missing closure rows: %w", err)
+		}
+
+		if err := tx.Raw(expectedClosureCTE + `
+			SELECT COUNT(*) FROM (
+				SELECT ancestor_id, descendant_id, depth FROM department_closures
+				EXCEPT
+				SELECT ancestor_id, descendant_id, depth FROM expected
+			) stale`).Scan(&report.Stale).Error; err != nil {
+			return fmt.Errorf("count stale closure rows: %w", err)
+		}
+		return nil
+	})
+	return report, err
+}
+
+// RebuildHierarchy recomputes the closure table from parent_id.
+func (s *Store) RebuildHierarchy(ctx context.Context) error {
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM department_closures").Error; err != nil {
+			return fmt.Errorf("clear closure table: %w", err)
+		}
+		if err := tx.Exec(expectedClosureCTE + `
+			INSERT INTO department_closures (ancestor_id, descendant_id, depth)
+			SELECT ancestor_id, descendant_id, depth FROM expected`).Error; err != nil {
+			return fmt.Errorf("fill closure table: %w", err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,37 @@
+package gormrepo
+
+import (
+	"context"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/models"
+)
+
+type outboxRepository struct {
+	db *gorm.DB
+}
+
+func (r outboxRepository) Append(ctx context.Context, event *models.OutboxEvent) error {
+	if err := r.db.WithContext(ctx).Create(event).Error; err != nil {
+		return fmt.Errorf("append outbox event: %w", err)
+	}
+	return nil
+}
+
+func (r outboxRepository) Redact(ctx context.Context, entityType string, entityID uint, payload string) error {
+	if err := r.db.WithContext(ctx).
+		Model(&models.OutboxEvent{}).
+		Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Update("payload", payload).Error; err != nil {
+		return fmt.Errorf("redact outbox events: %w", err)
+	}
+	if err := r.db.WithContext(ctx).
+		Model(&models.WebhookDelivery{}).
+		Where("entity_type = ? AND entity_id = ?", entityType, entityID).
+		Update("payload", payload).Error; err != nil {
+		return fmt.Errorf("redact webhook deliveries: %w", err)
+	}
+	return nil
+}
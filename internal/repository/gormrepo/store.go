@@ -0,0 +1,151 @@
+package gormrepo
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/glebarez/go-sqlite"
+	"github.com/jackc/pgx/v5/pgconn"
+	"gorm.io/gorm"
+	sqlite3 "modernc.org/sqlite/lib"
+
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+)
+
+const maxTransactionAttempts = 8
+
+type Store struct {
+	db *gorm.DB
+}
+
+func New(db *gorm.DB) *Store {
+	return &Store{db: db}
+}
+
+func (s *Store) Departments() repository.DepartmentRepository {
+	return departmentRepository{db: s.db}
+}
+
+func (s *Store) Employees() repository.EmployeeRepository {
+	return employeeRepository{db: s.db}
+}
+
+func (s *Store) Audit() repository.AuditRepository {
+	return auditRepository{db: s.db}
+}
+
+func (s *Store) Outbox() repository.OutboxRepository {
+	return outboxRepository{db: s.db}
+}
+
+func (s *Store) Webhooks() repository.WebhookRepository {
+	return webhookRepository{db: s.db}
+}
+
+// Counts returns the total number of departments and employees.
+func (s *Store) Counts(ctx context.Context) (departments int64, employees int64, err error) {
+	if err := s.db.WithContext(ctx).Model(&models.Department{}).Count(&departments).Error; err != nil {
+		return 0, 0, fmt.Errorf("count departments: %w", err)
+	}
+	if err := s.db.WithContext(ctx).Model(&models.Employee{}).Count(&employees).Error; err != nil {
+		return 0, 0, fmt.Errorf("count employees: %w", err)
+	}
+	return departments, employees, nil
+}
+
+// WithinTransaction runs fn in a serializable transaction so that the checks done
+// inside (existence, sibling names, cycles) stay valid until commit. Postgres
+// aborts one of two conflicting transactions; those are retried with backoff.
+func (s *Store) WithinTransaction(ctx context.Context, fn func(tx repository.Repositories) error) error {
+	var err error
+	for attempt := 1; attempt <= maxTransactionAttempts; attempt++ {
+		err = s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+			return fn(txRepositories{db: tx})
+		}, &sql.TxOptions{Isolation: sql.LevelSerializable})
+		if !isRetryableTransactionError(err) || attempt == maxTransactionAttempts {
+			break
+		}
+
+		backoff := time.Duration(attempt*attempt) * 5 * time.Millisecond
+		backoff += time.Duration(rand.Int64N(int64(backoff)))
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+	}
+	if isRetryableTransactionError(err) {
+		return repository.ErrConcurrentModification
+	}
+	if err == nil {
+		repository.RecordWrite(ctx)
+	}
+	return err
+}
+
+type txRepositories struct {
+	db *gorm.DB
+}
+
+func (t txRepositories) Departments() repository.DepartmentRepository {
+	return departmentRepository{db: t.db}
+}
+
+func (t txRepositories) Employees() repository.EmployeeRepository {
+	return employeeRepository{db: t.db}
+}
+
+func (t txRepositories) Audit() repository.AuditRepository {
+	return auditRepository{db: t.db}
+}
+
+func (t txRepositories) Outbox() repository.OutboxRepository {
+	return outboxRepository{db: t.db}
+}
+
+func (t txRepositories) Webhooks() repository.WebhookRepository {
+	return webhookRepository{db: t.db}
+}
+
+func isRetryableTransactionError(err error) bool {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		// serialization_failure, deadlock_detected
+		return pgErr.Code == "40001" || pgErr.Code == "40P01"
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		primary := sqliteErr.Code() & 0xff
+		return primary == sqlite3.SQLITE_BUSY || primary == sqlite3.SQLITE_LOCKED
+	}
+	return false
+}
+
+func mapDatabaseError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		if pgErr.Code == "23505" {
+			return repository.ErrDuplicate
+		}
+		if pgErr.Code == "23503" {
+			return repository.ErrInvalidReference
+		}
+	}
+
+	var sqliteErr *sqlite.Error
+	if errors.As(err, &sqliteErr) {
+		switch sqliteErr.Code() {
+		case sqlite3.SQLITE_CONSTRAINT_UNIQUE, sqlite3.SQLITE_CONSTRAINT_PRIMARYKEY:
+			return repository.ErrDuplicate
+		case sqlite3.SQLITE_CONSTRAINT_FOREIGNKEY:
+			return repository.ErrInvalidReference
+		}
+	}
+	return err
+}
@@ -0,0 +1,112 @@
+package gormrepo
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+)
+
+type webhookRepository struct {
+	db *gorm.DB
+}
+
+func (r webhookRepository) Create(ctx context.Context, subscription *models.WebhookSubscription) error {
+	if err := r.db.WithContext(ctx).Create(subscription).Error; err != nil {
+		return mapDatabaseError(err)
+	}
+	return nil
+}
+
+func (r webhookRepository) Get(ctx context.Context, id uint) (models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	err := r.db.WithContext(ctx).First(&subscription, id).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return models.WebhookSubscription{}, repository.ErrNotFound
+	}
+	if err != nil {
+		return models.WebhookSubscription{}, fmt.Errorf("load webhook subscription: %w", err)
+	}
+	return subscription, nil
+}
+
+func (r webhookRepository) List(ctx context.Context) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	if err := r.db.WithContext(ctx).Order("id").Find(&subscriptions).Error; err != nil {
+		return nil, fmt.Errorf("load webhook subscriptions: %w", err)
+	}
+	return subscriptions, nil
+}
+
+func (r webhookRepository) Update(ctx context.Context, subscription *models.WebhookSubscription) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.WebhookSubscription{}).
+		Where("id = ?", subscription.ID).
+		Updates(map[string]any{
+			"url":           subscription.URL,
+			"secret":        subscription.Secret,
+			"event_types":   subscription.EventTypes,
+			"department_id": subscription.DepartmentID,
+			"active":        subscription.Active,
+			"updated_at":    subscription.UpdatedAt,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("update webhook subscription: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r webhookRepository) Delete(ctx context.Context, id uint) error {
+	if err := r.db.WithContext(ctx).Where("subscription_id = ?", id).Delete(&models.WebhookDelivery{}).Error; err != nil {
+		return fmt.Errorf("delete webhook deliveries: %w", err)
+	}
+	result := r.db.WithContext(ctx).Delete(&models.WebhookSubscription{}, id)
+	if result.Error != nil {
+		return fmt.Errorf("delete webhook subscription: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
+
+func (r webhookRepository) ListDeliveries(ctx context.Context, subscriptionID uint, filter repository.DeliveryFilter) ([]models.WebhookDelivery, error) {
+	query := r.db.WithContext(ctx).Where("subscription_id = ?", subscriptionID)
+	if filter.Status != "" {
+		query = query.Where("status = ?", filter.Status)
+	}
+	if filter.BeforeID > 0 {
+		query = query.Where("id < ?", filter.BeforeID)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("id DESC").Limit(filter.Limit).Find(&deliveries).Error; err != nil {
+		return nil, fmt.Errorf("load webhook deliveries: %w", err)
+	}
+	return deliveries, nil
+}
+
+func (r webhookRepository) Redeliver(ctx context.Context, subscriptionID uint, deliveryID uint) error {
+	result := r.db.WithContext(ctx).
+		Model(&models.WebhookDelivery{}).
+		Where("id = ? AND subscription_id = ?", deliveryID, subscriptionID).
+		Updates(map[string]any{
+			"status":          models.WebhookDeliveryPending,
+			"attempts":        0,
+			"next_attempt_at": nil,
+		})
+	if result.Error != nil {
+		return fmt.Errorf("redeliver webhook: %w", result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return repository.ErrNotFound
+	}
+	return nil
+}
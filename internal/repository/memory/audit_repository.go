@@ -0,0 +1,24 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"hitalent-go-task/internal/models"
+)
+
+type auditRepository struct {
+	access access
+	now    func() time.Time
+}
+
+func (r auditRepository) Record(ctx context.Context, entry *models.AuditEntry) error {
+	return r.access.write(func(st *state) error {
+		entry.ID = uint(len(st.audit) + 1)
+		if entry.CreatedAt.IsZero() {
+			entry.CreatedAt = r.now()
+		}
+		st.audit = append(st.audit, *entry)
+		return nil
+	})
+}
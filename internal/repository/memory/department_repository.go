@@ -0,0 +1,333 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"strings"
+	"time"
+
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+)
+
+type departmentRepository struct {
+	access access
+	now    func() time.Time
+}
+
+func (r departmentRepository) Get(ctx context.Context, id uint) (models.Department, error) {
+	var department models.Department
+	err := r.access.read(func(st *state) error {
+		found, ok := st.departments[id]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		department = stripDepartment(found)
+		return nil
+	})
+	return department, err
+}
+
+func (r departmentRepository) Exists(ctx context.Context, id uint) (bool, error) {
+	var exists bool
+	err := r.access.read(func(st *state) error {
+		_, exists = st.departments[id]
+		return nil
+	})
+	return exists, err
+}
+
+func (r departmentRepository) List(ctx context.Context, filter repository.DepartmentFilter) ([]models.Department, int64, error) {
+	departments := make([]models.Department, 0)
+	err := r.access.read(func(st *state) error {
+		var subtree map[uint]bool
+		if filter.SubtreeOf != nil {
+			subtree = st.subtreeSet(*filter.SubtreeOf)
+		}
+		for _, department := range st.departments {
+			if subtree != nil && !subtree[department.ID] {
+				continue
+			}
+			if filter.RootsOnly && department.ParentID != nil {
+				continue
+			}
+			if !idFilter(filter.IDs, department.ID) || !textMatches(department.Name, filter.Name) {
+				continue
+			}
+			departments = append(departments, stripDepartment(department))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.Slice(departments, func(i, j int) bool {
+		return departments[i].ID < departments[j].ID
+	})
+	return page(departments, filter.Offset, filter.Limit), int64(len(departments)), nil
+}
+
+func (r departmentRepository) ListDescendants(ctx context.Context, id uint, maxDepth int) ([]models.Department, error) {
+	var descendants []models.Department
+	err := r.access.read(func(st *state) error {
+		descendants = st.descendants(id, maxDepth)
+		return nil
+	})
+	return descendants, err
+}
+
+func (r departmentRepository) ListDescendantsPage(ctx context.Context, id uint, maxDepth int, after repository.DescendantCursor, limit int) ([]repository.DescendantNode, error) {
+	var nodes []repository.DescendantNode
+	err := r.access.read(func(st *state) error {
+		level := []uint{id}
+		for depth := 1; len(level) > 0 && (maxDepth < 0 || depth <= maxDepth); depth++ {
+			var next []uint
+			var levelNodes []repository.DescendantNode
+			for _, parentID := range level {
+				for _, child := range st.children(parentID) {
+					next = append(next, child.ID)
+					if depth > after.Depth || (depth == after.Depth && child.ID > after.ID) {
+						levelNodes = append(levelNodes, repository.DescendantNode{Department: child, Depth: depth})
+					}
+				}
+			}
+			sort.Slice(levelNodes, func(i, j int) bool {
+				return levelNodes[i].Department.ID < levelNodes[j].Department.ID
+			})
+			nodes = append(nodes, levelNodes...)
+			if len(nodes) >= limit {
+				nodes = nodes[:limit]
+				break
+			}
+			level = next
+		}
+		return nil
+	})
+	return nodes, err
+}
+
+func (r departmentRepository) IsAncestor(ctx context.Context, ancestorID uint, descendantID uint) (bool, error) {
+	var isAncestor bool
+	err := r.access.read(func(st *state) error {
+		for _, id := range st.ancestorIDs(descendantID) {
+			if id == ancestorID {
+				isAncestor = true
+				break
+			}
+		}
+		return nil
+	})
+	return isAncestor, err
+}
+
+func (r departmentRepository) AncestorIDs(ctx context.Context, id uint) ([]uint, error) {
+	var ids []uint
+	err := r.access.read(func(st *state) error {
+		ids = st.ancestorIDs(id)
+		return nil
+	})
+	return ids, err
+}
+
+func (r departmentRepository) SiblingNameExists(ctx context.Context, parentID *uint, name string, excludeID *uint) (bool, error) {
+	var exists bool
+	err := r.access.read(func(st *state) error {
+		exists = st.siblingNameTaken(parentID, name, excludeID)
+		return nil
+	})
+	return exists, err
+}
+
+func (r departmentRepository) Create(ctx context.Context, department *models.Department) error {
+	return r.access.write(func(st *state) error {
+		if department.ParentID != nil {
+			if _, ok := st.departments[*department.ParentID]; !ok {
+				return repository.ErrInvalidReference
+			}
+		}
+		if st.siblingNameTaken(department.ParentID, department.Name, nil) {
+			return repository.ErrDuplicate
+		}
+
+		department.ID = st.nextDepartmentID
+		st.nextDepartmentID++
+		if department.Version == 0 {
+			department.Version = 1
+		}
+		if department.CreatedAt.IsZero() {
+			department.CreatedAt = r.now()
+		}
+		st.departments[department.ID] = stripDepartment(*department)
+		return nil
+	})
+}
+
+func (r departmentRepository) Update(ctx context.Context, id uint, expectedVersion int64, changes repository.DepartmentChanges) (bool, error) {
+	updated := false
+	err := r.access.write(func(st *state) error {
+		department, ok := st.departments[id]
+		if !ok || department.Version != expectedVersion {
+			return nil
+		}
+
+		if changes.Name != nil {
+			department.Name = *changes.Name
+		}
+		if changes.ParentIDSet {
+			if changes.ParentID != nil {
+				if _, ok := st.departments[*changes.ParentID]; !ok {
+					return repository.ErrInvalidReference
+				}
+			}
+			department.ParentID = copyUintPtr(changes.ParentID)
+		}
+		if st.siblingNameTaken(department.ParentID, department.Name, &id) {
+			return repository.ErrDuplicate
+		}
+
+		department.Version++
+		st.departments[id] = department
+		updated = true
+		return nil
+	})
+	return updated, err
+}
+
+func (r departmentRepository) ReparentChildren(ctx context.Context, fromParentID uint, toParentID *uint) error {
+	return r.access.write(func(st *state) error {
+		for _, child := range st.children(fromParentID) {
+			child.ParentID = copyUintPtr(toParentID)
+			child.Version++
+			if st.siblingNameTaken(child.ParentID, child.Name, &child.ID) {
+				return repository.ErrDuplicate
+			}
+			st.departments[child.ID] = child
+		}
+		return nil
+	})
+}
+
+func (r departmentRepository) Delete(ctx context.Context, id uint) error {
+	return r.access.write(func(st *state) error {
+		st.deleteSubtree(id)
+		return nil
+	})
+}
+
+func (r departmentRepository) BumpVersions(ctx context.Context, ids ...uint) error {
+	return r.access.write(func(st *state) error {
+		bumped := make(map[uint]bool)
+		for _, id := range ids {
+			department, ok := st.departments[id]
+			if !ok || bumped[id] {
+				continue
+			}
+			department.Version++
+			st.departments[id] = department
+			bumped[id] = true
+		}
+		return nil
+	})
+}
+
+func (st *state) children(parentID uint) []models.Department {
+	children := make([]models.Department, 0)
+	for _, department := range st.departments {
+		if department.ParentID != nil && *department.ParentID == parentID {
+			children = append(children, stripDepartment(department))
+		}
+	}
+	sort.Slice(children, func(i, j int) bool {
+		if children[i].Name != children[j].Name {
+			return children[i].Name < children[j].Name
+		}
+		return children[i].ID < children[j].ID
+	})
+	return children
+}
+
+func (st *state) descendants(id uint, maxDepth int) []models.Department {
+	descendants := make([]models.Department, 0)
+	level := []uint{id}
+	for depth := 1; len(level) > 0 && (maxDepth < 0 || depth <= maxDepth); depth++ {
+		var next []uint
+		for _, parentID := range level {
+			for _, child := range st.children(parentID) {
+				descendants = append(descendants, child)
+				next = append(next, child.ID)
+			}
+		}
+		level = next
+	}
+	sort.SliceStable(descendants, func(i, j int) bool {
+		return descendants[i].Name < descendants[j].Name
+	})
+	return descendants
+}
+
+func (st *state) ancestorIDs(id uint) []uint {
+	var ids []uint
+	seen := make(map[uint]bool)
+	current, ok := st.departments[id]
+	for ok && !seen[current.ID] {
+		seen[current.ID] = true
+		ids = append(ids, current.ID)
+		if current.ParentID == nil {
+			break
+		}
+		current, ok = st.departments[*current.ParentID]
+	}
+	return ids
+}
+
+// siblingNameTaken mirrors the uniq_departments_parent_name index.
+func (st *state) siblingNameTaken(parentID *uint, name string, excludeID *uint) bool {
+	for _, department := range st.departments {
+		if excludeID != nil && department.ID == *excludeID {
+			continue
+		}
+		if equalUintPtr(department.ParentID, parentID) && strings.EqualFold(department.Name, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// deleteSubtree mirrors the ON DELETE CASCADE foreign keys.
+func (st *state) deleteSubtree(id uint) {
+	if _, ok := st.departments[id]; !ok {
+		return
+	}
+	for _, child := range st.children(id) {
+		st.deleteSubtree(child.ID)
+	}
+	for employeeID, employee := range st.employees {
+		if employee.DepartmentID == id {
+			delete(st.employees, employeeID)
+		}
+	}
+	delete(st.departments, id)
+}
+
+func stripDepartment(department models.Department) models.Department {
+	department.ParentID = copyUintPtr(department.ParentID)
+	department.Parent = nil
+	department.Children = nil
+	department.Employees = nil
+	return department
+}
+
+func copyUintPtr(value *uint) *uint {
+	if value == nil {
+		return nil
+	}
+	copied := *value
+	return &copied
+}
+
+func equalUintPtr(a *uint, b *uint) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return *a == *b
+}
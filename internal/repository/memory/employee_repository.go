@@ -0,0 +1,181 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+)
+
+type employeeRepository struct {
+	access access
+	now    func() time.Time
+}
+
+func (r employeeRepository) Get(ctx context.Context, id uint) (models.Employee, error) {
+	var employee models.Employee
+	err := r.access.read(func(st *state) error {
+		found, ok := st.employees[id]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		employee = copyEmployee(found)
+		return nil
+	})
+	return employee, err
+}
+
+func (r employeeRepository) List(ctx context.Context, filter repository.EmployeeFilter) ([]models.Employee, int64, error) {
+	employees := make([]models.Employee, 0)
+	err := r.access.read(func(st *state) error {
+		var subtree map[uint]bool
+		if filter.SubtreeOf != nil {
+			subtree = st.subtreeSet(*filter.SubtreeOf)
+		}
+		for _, employee := range st.employees {
+			if subtree != nil && !subtree[employee.DepartmentID] {
+				continue
+			}
+			if !idFilter(filter.IDs, employee.ID) || !idFilter(filter.DepartmentIDs, employee.DepartmentID) {
+				continue
+			}
+			if !textMatches(employee.FullName, filter.FullName) || !textMatches(employee.Position, filter.Position) {
+				continue
+			}
+			if filter.Erased != nil && *filter.Erased != (employee.ErasedAt != nil) {
+				continue
+			}
+			employees = append(employees, copyEmployee(employee))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	sort.Slice(employees, func(i, j int) bool {
+		return employees[i].ID < employees[j].ID
+	})
+	return page(employees, filter.Offset, filter.Limit), int64(len(employees)), nil
+}
+
+func (r employeeRepository) Create(ctx context.Context, employee *models.Employee) error {
+	return r.access.write(func(st *state) error {
+		if _, ok := st.departments[employee.DepartmentID]; !ok {
+			return repository.ErrInvalidReference
+		}
+
+		employee.ID = st.nextEmployeeID
+		st.nextEmployeeID++
+		if employee.Version == 0 {
+			employee.Version = 1
+		}
+		if employee.CreatedAt.IsZero() {
+			employee.CreatedAt = r.now()
+		}
+		st.employees[employee.ID] = copyEmployee(*employee)
+		return nil
+	})
+}
+
+func (r employeeRepository) Update(ctx context.Context, id uint, expectedVersion int64, changes repository.EmployeeChanges) (bool, error) {
+	updated := false
+	err := r.access.write(func(st *state) error {
+		employee, ok := st.employees[id]
+		if !ok || employee.Version != expectedVersion {
+			return nil
+		}
+
+		if changes.FullName != nil {
+			employee.FullName = *changes.FullName
+		}
+		if changes.Position != nil {
+			employee.Position = *changes.Position
+		}
+		if changes.DepartmentID != nil {
+			if _, ok := st.departments[*changes.DepartmentID]; !ok {
+				return repository.ErrInvalidReference
+			}
+			employee.DepartmentID = *changes.DepartmentID
+		}
+
+		employee.Version++
+		st.employees[id] = employee
+		updated = true
+		return nil
+	})
+	return updated, err
+}
+
+func (r employeeRepository) ListByDepartments(ctx context.Context, departmentIDs []uint) ([]models.Employee, error) {
+	employees := make([]models.Employee, 0)
+	err := r.access.read(func(st *state) error {
+		for _, departmentID := range departmentIDs {
+			employees = append(employees, st.employeesOf(departmentID)...)
+		}
+		return nil
+	})
+	sort.SliceStable(employees, func(i, j int) bool {
+		return employees[i].FullName < employees[j].FullName
+	})
+	return employees, err
+}
+
+func (r employeeRepository) Reassign(ctx context.Context, fromDepartmentID uint, toDepartmentID uint) error {
+	return r.access.write(func(st *state) error {
+		if _, ok := st.departments[toDepartmentID]; !ok {
+			return repository.ErrInvalidReference
+		}
+		for _, employee := range st.employeesOf(fromDepartmentID) {
+			employee.DepartmentID = toDepartmentID
+			employee.Version++
+			st.employees[employee.ID] = employee
+		}
+		return nil
+	})
+}
+
+func (r employeeRepository) Anonymize(ctx context.Context, id uint, placeholderName string, erasedAt time.Time) error {
+	return r.access.write(func(st *state) error {
+		employee, ok := st.employees[id]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		employee.FullName = placeholderName
+		employee.HiredAt = nil
+		employee.ErasedAt = &erasedAt
+		employee.Version++
+		st.employees[id] = employee
+		return nil
+	})
+}
+
+func (st *state) employeesOf(departmentID uint) []models.Employee {
+	employees := make([]models.Employee, 0)
+	for _, employee := range st.employees {
+		if employee.DepartmentID == departmentID {
+			employees = append(employees, copyEmployee(employee))
+		}
+	}
+	sort.Slice(employees, func(i, j int) bool {
+		if employees[i].FullName != employees[j].FullName {
+			return employees[i].FullName < employees[j].FullName
+		}
+		return employees[i].ID < employees[j].ID
+	})
+	return employees
+}
+
+func copyEmployee(employee models.Employee) models.Employee {
+	if employee.HiredAt != nil {
+		hiredAt := *employee.HiredAt
+		employee.HiredAt = &hiredAt
+	}
+	if employee.ErasedAt != nil {
+		erasedAt := *employee.ErasedAt
+		employee.ErasedAt = &erasedAt
+	}
+	employee.Department = models.Department{}
+	return employee
+}
@@ -0,0 +1,61 @@
+package memory
+
+import (
+	"strings"
+
+	"hitalent-go-task/internal/repository"
+)
+
+func textMatches(value string, matches []repository.TextMatch) bool {
+	value = strings.ToLower(value)
+	for _, match := range matches {
+		expected := strings.ToLower(match.Value)
+		var ok bool
+		switch match.Op {
+		case repository.MatchContains:
+			ok = strings.Contains(value, expected)
+		case repository.MatchStartsWith:
+			ok = strings.HasPrefix(value, expected)
+		default:
+			ok = value == expected
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+// idFilter reports whether id passes a filter list; a nil list passes all.
+func idFilter(ids []uint, id uint) bool {
+	if ids == nil {
+		return true
+	}
+	for _, candidate := range ids {
+		if candidate == id {
+			return true
+		}
+	}
+	return false
+}
+
+// subtreeSet returns the ids of the department and its descendants.
+func (st *state) subtreeSet(departmentID uint) map[uint]bool {
+	set := map[uint]bool{}
+	if _, ok := st.departments[departmentID]; !ok {
+		return set
+	}
+	set[departmentID] = true
+	for _, department := range st.descendants(departmentID, -1) {
+		set[department.ID] = true
+	}
+	return set
+}
+
+// page cuts the requested page out of rows sorted by id.
+func page[T any](rows []T, offset int, limit int) []T {
+	if limit == 0 || offset >= len(rows) {
+		return rows[:0]
+	}
+	return rows[offset:min(offset+limit, len(rows))]
+}
@@ -0,0 +1,37 @@
+package memory
+
+import (
+	"context"
+	"time"
+
+	"hitalent-go-task/internal/models"
+)
+
+type outboxRepository struct {
+	access access
+	now    func() time.Time
+}
+
+func (r outboxRepository) Append(ctx context.Context, event *models.OutboxEvent) error {
+	return r.access.write(func(st *state) error {
+		event.ID = uint(len(st.outbox) + 1)
+		if event.CreatedAt.IsZero() {
+			event.CreatedAt = r.now()
+		}
+		st.outbox = append(st.outbox, *event)
+		return nil
+	})
+}
+
+func (r outboxRepository) Redact(ctx context.Context, entityType string, entityID uint, payload string) error {
+	return r.access.write(func(st *state) error {
+		// The slice is shared with the state the transaction started from.
+		st.outbox = append([]models.OutboxEvent(nil), st.outbox...)
+		for i := range st.outbox {
+			if st.outbox[i].EntityType == entityType && st.outbox[i].EntityID == entityID {
+				st.outbox[i].Payload = payload
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+)
+
+type Store struct {
+	mu    sync.RWMutex
+	state *state
+	now   func() time.Time
+}
+
+func NewStore() *Store {
+	return &Store{
+		state: newState(),
+		now:   time.Now,
+	}
+}
+
+func (s *Store) Departments() repository.DepartmentRepository {
+	return departmentRepository{access: storeAccess{store: s}, now: s.now}
+}
+
+func (s *Store) Employees() repository.EmployeeRepository {
+	return employeeRepository{access: storeAccess{store: s}, now: s.now}
+}
+
+func (s *Store) Audit() repository.AuditRepository {
+	return auditRepository{access: storeAccess{store: s}, now: s.now}
+}
+
+func (s *Store) Outbox() repository.OutboxRepository {
+	return outboxRepository{access: storeAccess{store: s}, now: s.now}
+}
+
+func (s *Store) Webhooks() repository.WebhookRepository {
+	return webhookRepository{access: storeAccess{store: s}, now: s.now}
+}
+
+// OutboxEvents returns a copy of the written change events.
+func (s *Store) OutboxEvents() []models.OutboxEvent {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.OutboxEvent(nil), s.state.outbox...)
+}
+
+// AuditEntries returns a copy of the recorded audit trail.
+func (s *Store) AuditEntries() []models.AuditEntry {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]models.AuditEntry(nil), s.state.audit...)
+}
+
+// WithinTransaction serializes transactions with a store-wide lock and runs fn
+// against a copy of the data, which replaces the live state only on success.
+func (s *Store) WithinTransaction(ctx context.Context, fn func(tx repository.Repositories) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	working := s.state.clone()
+	if err := fn(txRepositories{access: txAccess{state: working}, now: s.now}); err != nil {
+		return err
+	}
+	s.state = working
+	return nil
+}
+
+type state struct {
+	departments      map[uint]models.Department
+	employees        map[uint]models.Employee
+	webhooks         map[uint]models.WebhookSubscription
+	nextDepartmentID uint
+	nextEmployeeID   uint
+	nextWebhookID    uint
+	audit            []models.AuditEntry
+	outbox           []models.OutboxEvent
+}
+
+func newState() *state {
+	return &state{
+		departments:      make(map[uint]models.Department),
+		employees:        make(map[uint]models.Employee),
+		webhooks:         make(map[uint]models.WebhookSubscription),
+		nextDepartmentID: 1,
+		nextEmployeeID:   1,
+		nextWebhookID:    1,
+	}
+}
+
+func (s *state) clone() *state {
+	cloned := &state{
+		departments:      make(map[uint]models.Department, len(s.departments)),
+		employees:        make(map[uint]models.Employee, len(s.employees)),
+		webhooks:         make(map[uint]models.WebhookSubscription, len(s.webhooks)),
+		nextDepartmentID: s.nextDepartmentID,
+		nextEmployeeID:   s.nextEmployeeID,
+		nextWebhookID:    s.nextWebhookID,
+		audit:            s.audit[:len(s.audit):len(s.audit)],
+		outbox:           s.outbox[:len(s.outbox):len(s.outbox)],
+	}
+	for id, department := range s.departments {
+		cloned.departments[id] = department
+	}
+	for id, employee := range s.employees {
+		cloned.employees[id] = employee
+	}
+	for id, subscription := range s.webhooks {
+		cloned.webhooks[id] = subscription
+	}
+	return cloned
+}
+
+// access abstracts over locking: calls on the store lock it, calls inside a
+// transaction run under the lock already held by WithinTransaction.
+type access interface {
+	read(fn func(st *state) error) error
+	write(fn func(st *state) error) error
+}
+
+type storeAccess struct {
+	store *Store
+}
+
+func (a storeAccess) read(fn func(st *state) error) error {
+	a.store.mu.RLock()
+	defer a.store.mu.RUnlock()
+	return fn(a.store.state)
+}
+
+func (a storeAccess) write(fn func(st *state) error) error {
+	a.store.mu.Lock()
+	defer a.store.mu.Unlock()
+
+	working := a.store.state.clone()
+	if err := fn(working); err != nil {
+		return err
+	}
+	a.store.state = working
+	return nil
+}
+
+type txAccess struct {
+	state *state
+}
+
+func (a txAccess) read(fn func(st *state) error) error {
+	return fn(a.state)
+}
+
+func (a txAccess) write(fn func(st *state) error) error {
+	return fn(a.state)
+}
+
+type txRepositories struct {
+	access access
+	now    func() time.Time
+}
+
+func (t txRepositories) Departments() repository.DepartmentRepository {
+	return departmentRepository{access: t.access, now: t.now}
+}
+
+func (t txRepositories) Employees() repository.EmployeeRepository {
+	return employeeRepository{access: t.access, now: t.now}
+}
+
+func (t txRepositories) Audit() repository.AuditRepository {
+	return auditRepository{access: t.access, now: t.now}
+}
+
+func (t txRepositories) Outbox() repository.OutboxRepository {
+	return outboxRepository{access: t.access, now: t.now}
+}
+
+func (t txRepositories) Webhooks() repository.WebhookRepository {
+	return webhookRepository{access: t.access, now: t.now}
+}
@@ -0,0 +1,99 @@
+package memory
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+)
+
+// webhookRepository keeps subscriptions only: without a database there is no
+// dispatcher, so no delivery is ever queued.
+type webhookRepository struct {
+	access access
+	now    func() time.Time
+}
+
+func (r webhookRepository) Create(ctx context.Context, subscription *models.WebhookSubscription) error {
+	return r.access.write(func(st *state) error {
+		subscription.ID = st.nextWebhookID
+		st.nextWebhookID++
+		if subscription.CreatedAt.IsZero() {
+			subscription.CreatedAt = r.now()
+		}
+		if subscription.UpdatedAt.IsZero() {
+			subscription.UpdatedAt = subscription.CreatedAt
+		}
+		st.webhooks[subscription.ID] = copyWebhook(*subscription)
+		return nil
+	})
+}
+
+func (r webhookRepository) Get(ctx context.Context, id uint) (models.WebhookSubscription, error) {
+	var subscription models.WebhookSubscription
+	err := r.access.read(func(st *state) error {
+		found, ok := st.webhooks[id]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		subscription = copyWebhook(found)
+		return nil
+	})
+	return subscription, err
+}
+
+func (r webhookRepository) List(ctx context.Context) ([]models.WebhookSubscription, error) {
+	var subscriptions []models.WebhookSubscription
+	err := r.access.read(func(st *state) error {
+		for _, subscription := range st.webhooks {
+			subscriptions = append(subscriptions, copyWebhook(subscription))
+		}
+		return nil
+	})
+	sort.Slice(subscriptions, func(i, j int) bool {
+		return subscriptions[i].ID < subscriptions[j].ID
+	})
+	return subscriptions, err
+}
+
+func (r webhookRepository) Update(ctx context.Context, subscription *models.WebhookSubscription) error {
+	return r.access.write(func(st *state) error {
+		current, ok := st.webhooks[subscription.ID]
+		if !ok {
+			return repository.ErrNotFound
+		}
+		updated := copyWebhook(*subscription)
+		updated.CreatedBy = current.CreatedBy
+		updated.CreatedAt = current.CreatedAt
+		st.webhooks[subscription.ID] = updated
+		return nil
+	})
+}
+
+func (r webhookRepository) Delete(ctx context.Context, id uint) error {
+	return r.access.write(func(st *state) error {
+		if _, ok := st.webhooks[id]; !ok {
+			return repository.ErrNotFound
+		}
+		delete(st.webhooks, id)
+		return nil
+	})
+}
+
+func (r webhookRepository) ListDeliveries(ctx context.Context, subscriptionID uint, filter repository.DeliveryFilter) ([]models.WebhookDelivery, error) {
+	return nil, nil
+}
+
+func (r webhookRepository) Redeliver(ctx context.Context, subscriptionID uint, deliveryID uint) error {
+	return repository.ErrNotFound
+}
+
+func copyWebhook(subscription models.WebhookSubscription) models.WebhookSubscription {
+	if subscription.DepartmentID != nil {
+		departmentID := *subscription.DepartmentID
+		subscription.DepartmentID = &departmentID
+	}
+	return subscription
+}
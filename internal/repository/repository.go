@@ -0,0 +1,175 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"hitalent-go-task/internal/apperror"
+	"hitalent-go-task/internal/models"
+)
+
+var (
+	ErrNotFound = errors.New("record not found")
+
+	ErrDuplicate              = apperror.New(apperror.CodeConflict, "resource with the same unique attributes already exists")
+	ErrInvalidReference       = apperror.New(apperror.CodeValidation, "invalid foreign key reference")
+	ErrConcurrentModification = apperror.New(apperror.CodeConflict, "concurrent modification detected, please retry")
+)
+
+type DepartmentChanges struct {
+	Name        *string
+	ParentIDSet bool
+	ParentID    *uint
+}
+
+type EmployeeChanges struct {
+	FullName     *string
+	Position     *string
+	DepartmentID *uint
+}
+
+// MatchOp is a case-insensitive text comparison.
+type MatchOp string
+
+const (
+	MatchEquals     MatchOp = "eq"
+	MatchContains   MatchOp = "co"
+	MatchStartsWith MatchOp = "sw"
+)
+
+type TextMatch struct {
+	Op    MatchOp
+	Value string
+}
+
+// EmployeeFilter selects a page of employees ordered by id. All conditions
+// must hold; a nil slice does not filter, an empty one matches nothing.
+type EmployeeFilter struct {
+	// SubtreeOf limits the result to the department and its descendants.
+	SubtreeOf     *uint
+	IDs           []uint
+	DepartmentIDs []uint
+	FullName      []TextMatch
+	Position      []TextMatch
+	Erased        *bool
+	Offset        int
+	// Limit 0 only counts the matches.
+	Limit int
+}
+
+// DepartmentFilter selects a page of departments ordered by id, like
+// EmployeeFilter.
+type DepartmentFilter struct {
+	SubtreeOf *uint
+	// RootsOnly selects departments without a parent.
+	RootsOnly bool
+	IDs       []uint
+	Name      []TextMatch
+	Offset    int
+	Limit     int
+}
+
+// DescendantNode is a department together with its distance from the subtree root.
+type DescendantNode struct {
+	Department models.Department
+	Depth      int
+}
+
+// DescendantCursor points at the last node returned by ListDescendantsPage.
+// The zero value starts from the beginning.
+type DescendantCursor struct {
+	Depth int
+	ID    uint
+}
+
+type DepartmentRepository interface {
+	Get(ctx context.Context, id uint) (models.Department, error)
+	Exists(ctx context.Context, id uint) (bool, error)
+	// List returns a page of the matching departments and their total count.
+	List(ctx context.Context, filter DepartmentFilter) ([]models.Department, int64, error)
+	// ListDescendants returns the subtree below id (without id itself) down to
+	// maxDepth levels, ordered by name. A negative maxDepth means unlimited.
+	ListDescendants(ctx context.Context, id uint, maxDepth int) ([]models.Department, error)
+	// ListDescendantsPage returns up to limit nodes of the same subtree ordered
+	// by depth and id, starting after cursor. Parents always precede children.
+	ListDescendantsPage(ctx context.Context, id uint, maxDepth int, after DescendantCursor, limit int) ([]DescendantNode, error)
+	// AncestorIDs returns the chain from id itself up to the root.
+	AncestorIDs(ctx context.Context, id uint) ([]uint, error)
+	// IsAncestor reports whether ancestorID is descendantID or one of its ancestors.
+	IsAncestor(ctx context.Context, ancestorID uint, descendantID uint) (bool, error)
+	SiblingNameExists(ctx context.Context, parentID *uint, name string, excludeID *uint) (bool, error)
+	Create(ctx context.Context, department *models.Department) error
+	// Update applies changes only if the stored version equals expectedVersion
+	// and bumps the version. It reports false when the version did not match.
+	Update(ctx context.Context, id uint, expectedVersion int64, changes DepartmentChanges) (bool, error)
+	ReparentChildren(ctx context.Context, fromParentID uint, toParentID *uint) error
+	// Delete removes the department together with its subtree and employees.
+	Delete(ctx context.Context, id uint) error
+	// BumpVersions increments versions of the given departments. Callers pass
+	// the departments whose own representation changed, i.e. the parents of
+	// changed children and employees; ancestors further up are left alone.
+	BumpVersions(ctx context.Context, ids ...uint) error
+}
+
+type EmployeeRepository interface {
+	Get(ctx context.Context, id uint) (models.Employee, error)
+	// List returns a page of the matching employees and their total count.
+	List(ctx context.Context, filter EmployeeFilter) ([]models.Employee, int64, error)
+	Create(ctx context.Context, employee *models.Employee) error
+	// Update applies changes only if the stored version equals expectedVersion
+	// and bumps the version. It reports false when the version did not match.
+	Update(ctx context.Context, id uint, expectedVersion int64, changes EmployeeChanges) (bool, error)
+	ListByDepartments(ctx context.Context, departmentIDs []uint) ([]models.Employee, error)
+	Reassign(ctx context.Context, fromDepartmentID uint, toDepartmentID uint) error
+	// Anonymize replaces personal data with a placeholder, keeping the record
+	// so that headcounts stay correct, and bumps the version.
+	Anonymize(ctx context.Context, id uint, placeholderName string, erasedAt time.Time) error
+}
+
+type AuditRepository interface {
+	Record(ctx context.Context, entry *models.AuditEntry) error
+}
+
+type OutboxRepository interface {
+	Append(ctx context.Context, event *models.OutboxEvent) error
+	// Redact replaces the payload of every stored event about the entity,
+	// published or not, including the copies queued for webhooks.
+	Redact(ctx context.Context, entityType string, entityID uint, payload string) error
+}
+
+// DeliveryFilter selects a page of a subscription's deliveries, newest first.
+type DeliveryFilter struct {
+	// Status is one of the models.WebhookDelivery* values; empty means any.
+	Status   string
+	BeforeID uint
+	Limit    int
+}
+
+type WebhookRepository interface {
+	Create(ctx context.Context, subscription *models.WebhookSubscription) error
+	Get(ctx context.Context, id uint) (models.WebhookSubscription, error)
+	List(ctx context.Context) ([]models.WebhookSubscription, error)
+	Update(ctx context.Context, subscription *models.WebhookSubscription) error
+	// Delete removes the subscription together with its deliveries.
+	Delete(ctx context.Context, id uint) error
+	ListDeliveries(ctx context.Context, subscriptionID uint, filter DeliveryFilter) ([]models.WebhookDelivery, error)
+	// Redeliver puts a delivery of the subscription back into the queue with
+	// a fresh attempt count.
+	Redeliver(ctx context.Context, subscriptionID uint, deliveryID uint) error
+}
+
+type Repositories interface {
+	Departments() DepartmentRepository
+	Employees() EmployeeRepository
+	Audit() AuditRepository
+	Outbox() OutboxRepository
+	Webhooks() WebhookRepository
+}
+
+type Store interface {
+	Repositories
+	// WithinTransaction runs fn atomically and in isolation from concurrent
+	// transactions. Implementations may call fn more than once.
+	WithinTransaction(ctx context.Context, fn func(tx Repositories) error) error
+}
@@ -0,0 +1,45 @@
+package repository
+
+import (
+	"context"
+	"sync/atomic"
+)
+
+type sessionKey struct{}
+
+type primaryKey struct{}
+
+type session struct {
+	wrote atomic.Bool
+}
+
+// WithSession starts a read-your-writes session, usually one per HTTP
+// request. Once a write has been committed in the session, stores with a
+// read replica serve its remaining reads from the primary.
+func WithSession(ctx context.Context) context.Context {
+	return context.WithValue(ctx, sessionKey{}, &session{})
+}
+
+// RecordWrite marks the session of ctx, if any, as having committed a write.
+func RecordWrite(ctx context.Context) {
+	if s, ok := ctx.Value(sessionKey{}).(*session); ok {
+		s.wrote.Store(true)
+	}
+}
+
+// WithPrimaryReads serves every read in ctx from the primary, for results
+// that outlive the request, such as cache entries.
+func WithPrimaryReads(ctx context.Context) context.Context {
+	return context.WithValue(ctx, primaryKey{}, true)
+}
+
+// ReadFromPrimary reports whether reads in ctx must see the session's own
+// writes, or are marked by WithPrimaryReads, and therefore cannot use a
+// lagging replica.
+func ReadFromPrimary(ctx context.Context) bool {
+	if primary, _ := ctx.Value(primaryKey{}).(bool); primary {
+		return true
+	}
+	s, ok := ctx.Value(sessionKey{}).(*session)
+	return ok && s.wrote.Load()
+}
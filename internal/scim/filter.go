@@ -0,0 +1,130 @@
+package scim
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// Comparison operators that filters may use.
+const (
+	OpEqual      = "eq"
+	OpContains   = "co"
+	OpStartsWith = "sw"
+)
+
+// Comparison is one "attribute operator value" expression of a filter. The
+// attribute and operator are lower-cased; Value is a string, bool, float64
+// or nil as decoded from JSON.
+type Comparison struct {
+	Attribute string
+	Operator  string
+	Value     any
+}
+
+// ParseFilter parses a filter made of comparisons joined with "and", which
+// covers what identity providers send to look up users and groups. Other
+// operators, "or", "not" and grouping are rejected as invalidFilter.
+func ParseFilter(raw string) ([]Comparison, error) {
+	tokens, err := tokenize(raw)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, BadRequest(ErrInvalidFilter, "filter is empty")
+	}
+
+	var comparisons []Comparison
+	for {
+		if len(tokens) < 2 {
+			return nil, BadRequest(ErrInvalidFilter, "expected attribute, operator and value")
+		}
+		attribute := strings.ToLower(tokens[0].text)
+		operator := strings.ToLower(tokens[1].text)
+		if tokens[0].quoted || tokens[1].quoted {
+			return nil, BadRequest(ErrInvalidFilter, "expected attribute and operator, got a string")
+		}
+		switch operator {
+		case OpEqual, OpContains, OpStartsWith:
+		case "ne", "ew", "pr", "gt", "ge", "lt", "le":
+			return nil, BadRequest(ErrInvalidFilter, "operator %s is not supported", operator)
+		default:
+			return nil, BadRequest(ErrInvalidFilter, "unknown operator %q", tokens[1].text)
+		}
+		if len(tokens) < 3 {
+			return nil, BadRequest(ErrInvalidFilter, "operator %s needs a value", operator)
+		}
+		value, err := tokens[2].value()
+		if err != nil {
+			return nil, err
+		}
+		comparisons = append(comparisons, Comparison{Attribute: attribute, Operator: operator, Value: value})
+
+		tokens = tokens[3:]
+		if len(tokens) == 0 {
+			return comparisons, nil
+		}
+		switch strings.ToLower(tokens[0].text) {
+		case "and":
+			tokens = tokens[1:]
+		case "or", "not":
+			return nil, BadRequest(ErrInvalidFilter, "%s is not supported, only and", strings.ToLower(tokens[0].text))
+		default:
+			return nil, BadRequest(ErrInvalidFilter, "expected and, got %q", tokens[0].text)
+		}
+	}
+}
+
+type token struct {
+	text   string
+	quoted bool
+}
+
+// value decodes a comparison value: a JSON string, true, false, null or a
+// number.
+func (t token) value() (any, error) {
+	if t.quoted {
+		return t.text, nil
+	}
+	var value any
+	if err := json.Unmarshal([]byte(t.text), &value); err != nil {
+		return nil, BadRequest(ErrInvalidFilter, "invalid value %q", t.text)
+	}
+	return value, nil
+}
+
+func tokenize(raw string) ([]token, error) {
+	var tokens []token
+	for i := 0; i < len(raw); {
+		switch c := raw[i]; {
+		case c == ' ' || c == '\t':
+			i++
+		case c == '(' || c == ')' || c == '[' || c == ']':
+			return nil, BadRequest(ErrInvalidFilter, "grouping and complex attribute filters are not supported")
+		case c == '"':
+			end := i + 1
+			for end < len(raw) && raw[end] != '"' {
+				if raw[end] == '\\' {
+					end++
+				}
+				end++
+			}
+			if end >= len(raw) {
+				return nil, BadRequest(ErrInvalidFilter, "unterminated string")
+			}
+			var text string
+			if err := json.Unmarshal([]byte(raw[i:end+1]), &text); err != nil {
+				return nil, BadRequest(ErrInvalidFilter, "invalid string %s", raw[i:end+1])
+			}
+			tokens = append(tokens, token{text: text, quoted: true})
+			i = end + 1
+		default:
+			end := i
+			for end < len(raw) && !strings.ContainsRune(" \t()[]\"", rune(raw[end])) {
+				end++
+			}
+			tokens = append(tokens, token{text: raw[i:end]})
+			i = end
+		}
+	}
+	return tokens, nil
+}
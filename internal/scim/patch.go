@@ -0,0 +1,107 @@
+package scim
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+)
+
+// PATCH operation types.
+const (
+	PatchAdd     = "add"
+	PatchRemove  = "remove"
+	PatchReplace = "replace"
+)
+
+// PatchOperation is one operation of a PatchOp request. Op and Path are
+// lower-cased, as attribute names are case-insensitive.
+type PatchOperation struct {
+	Op    string          `json:"op"`
+	Path  string          `json:"path"`
+	Value json.RawMessage `json:"value"`
+}
+
+type patchRequest struct {
+	Schemas    []string         `json:"schemas"`
+	Operations []PatchOperation `json:"Operations"`
+}
+
+// ParsePatch reads a PatchOp request. An add or replace without a path is
+// split into one operation per attribute of its value, so that callers only
+// deal with paths: {"name": {"formatted": "x"}} becomes path "name.formatted".
+func ParsePatch(body io.Reader) ([]PatchOperation, error) {
+	var request patchRequest
+	if err := json.NewDecoder(body).Decode(&request); err != nil {
+		return nil, BadRequest(ErrInvalidSyntax, "invalid JSON body")
+	}
+	if !containsFold(request.Schemas, PatchOpSchema) {
+		return nil, BadRequest(ErrInvalidSyntax, "schemas must contain %s", PatchOpSchema)
+	}
+	if len(request.Operations) == 0 {
+		return nil, BadRequest(ErrInvalidSyntax, "Operations must not be empty")
+	}
+
+	var operations []PatchOperation
+	for _, operation := range request.Operations {
+		operation.Op = strings.ToLower(operation.Op)
+		operation.Path = strings.ToLower(strings.TrimSpace(operation.Path))
+		switch operation.Op {
+		case PatchAdd, PatchReplace:
+			if len(operation.Value) == 0 {
+				return nil, BadRequest(ErrInvalidValue, "%s needs a value", operation.Op)
+			}
+		case PatchRemove:
+			if operation.Path == "" {
+				return nil, BadRequest(ErrNoTarget, "remove needs a path")
+			}
+		default:
+			return nil, BadRequest(ErrInvalidSyntax, "unknown operation %q", operation.Op)
+		}
+
+		if operation.Path != "" {
+			operations = append(operations, operation)
+			continue
+		}
+		expanded, err := expandOperation(operation)
+		if err != nil {
+			return nil, err
+		}
+		operations = append(operations, expanded...)
+	}
+	return operations, nil
+}
+
+func expandOperation(operation PatchOperation) ([]PatchOperation, error) {
+	var attributes map[string]json.RawMessage
+	if err := json.Unmarshal(operation.Value, &attributes); err != nil {
+		return nil, BadRequest(ErrInvalidValue, "%s without a path needs an object value", operation.Op)
+	}
+
+	var operations []PatchOperation
+	for name, value := range attributes {
+		name = strings.ToLower(name)
+		var subAttributes map[string]json.RawMessage
+		if json.Unmarshal(value, &subAttributes) != nil {
+			operations = append(operations, PatchOperation{Op: operation.Op, Path: name, Value: value})
+			continue
+		}
+		// Extension attributes are addressed as "urn:...:User:department".
+		separator := "."
+		if strings.HasPrefix(name, "urn:") {
+			separator = ":"
+		}
+		for subName, subValue := range subAttributes {
+			operations = append(operations, PatchOperation{Op: operation.Op, Path: name + separator + strings.ToLower(subName), Value: subValue})
+		}
+	}
+	return operations, nil
+}
+
+func containsFold(values []string, target string) bool {
+	for _, value := range values {
+		if strings.EqualFold(value, target) {
+			return true
+		}
+	}
+	return false
+}
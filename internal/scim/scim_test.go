@@ -0,0 +1,67 @@
+package scim
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseFilter(t *testing.T) {
+	comparisons, err := ParseFilter(`userName Eq "ivan \"the\" petrov" and active eq true and title sw "Dev"`)
+	if err != nil {
+		t.Fatalf("parse filter: %v", err)
+	}
+	want := []Comparison{
+		{Attribute: "username", Operator: OpEqual, Value: `ivan "the" petrov`},
+		{Attribute: "active", Operator: OpEqual, Value: true},
+		{Attribute: "title", Operator: OpStartsWith, Value: "Dev"},
+	}
+	if !reflect.DeepEqual(comparisons, want) {
+		t.Fatalf("expected %+v, got %+v", want, comparisons)
+	}
+
+	for _, raw := range []string{
+		``,
+		`userName`,
+		`userName eq`,
+		`userName gt "a"`,
+		`userName eq "a" or userName eq "b"`,
+		`not (userName eq "a")`,
+		`emails[type eq "work"]`,
+		`userName eq "a`,
+		`userName eq bob`,
+	} {
+		_, err := ParseFilter(raw)
+		var scimErr *Error
+		if !errors.As(err, &scimErr) || scimErr.ScimType != ErrInvalidFilter {
+			t.Fatalf("expected invalidFilter for %q, got %v", raw, err)
+		}
+	}
+}
+
+func TestParsePatch(t *testing.T) {
+	body := `{
+		"schemas": ["urn:ietf:params:scim:api:messages:2.0:PatchOp"],
+		"Operations": [
+			{"op": "Replace", "value": {"displayName": "Anna", "name": {"formatted": "Anna"}}},
+			{"op": "add", "path": "members", "value": [{"value": "7"}]}
+		]
+	}`
+	operations, err := ParsePatch(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("parse patch: %v", err)
+	}
+	paths := map[string]string{}
+	for _, operation := range operations {
+		paths[operation.Path] = operation.Op
+	}
+	want := map[string]string{"displayname": PatchReplace, "name.formatted": PatchReplace, "members": PatchAdd}
+	if !reflect.DeepEqual(paths, want) {
+		t.Fatalf("expected %v, got %v", want, paths)
+	}
+
+	if _, err := ParsePatch(strings.NewReader(`{"schemas": [], "Operations": [{"op": "add", "path": "title", "value": "x"}]}`)); err == nil {
+		t.Fatal("expected an error without the PatchOp schema")
+	}
+}
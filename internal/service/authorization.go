@@ -0,0 +1,43 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"hitalent-go-task/internal/apperror"
+	"hitalent-go-task/internal/auth"
+	"hitalent-go-task/internal/repository"
+)
+
+var errOutOfScope = apperror.New(apperror.CodeForbidden, "department is outside of your scope")
+
+// authorize checks that the caller has at least the given role and that every
+// target department lies within its scope. A nil target stands for the top of
+// the tree, which only unscoped callers may touch. Calls without a principal
+// come from trusted code (CLI, authentication disabled) and are allowed.
+func authorize(ctx context.Context, repos repository.Repositories, role auth.Role, targets ...*uint) error {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	if !principal.Role.Includes(role) {
+		return apperror.New(apperror.CodeForbidden, fmt.Sprintf("role %s or higher is required", role))
+	}
+	if principal.ScopeDepartmentID == nil {
+		return nil
+	}
+
+	for _, target := range targets {
+		if target == nil {
+			return errOutOfScope
+		}
+		inScope, err := repos.Departments().IsAncestor(ctx, *principal.ScopeDepartmentID, *target)
+		if err != nil {
+			return err
+		}
+		if !inScope {
+			return errOutOfScope
+		}
+	}
+	return nil
+}
@@ -0,0 +1,157 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"hitalent-go-task/internal/apperror"
+	"hitalent-go-task/internal/outbox"
+)
+
+// Change operations in the change feed.
+const (
+	ChangeUpsert = "upsert"
+	ChangeDelete = "delete"
+)
+
+const (
+	defaultChangesLimit = 100
+	maxChangesLimit     = 1000
+)
+
+var errCursorExpired = apperror.New(apperror.CodeGone, "changes after the cursor were purged, resync from a full export")
+
+type ListChangesOptions struct {
+	// Since is the cursor of the last applied change. Without it only the
+	// current cursor is returned, to start from after a full export.
+	Since        *uint
+	DepartmentID *uint
+	Limit        int
+}
+
+// Change is one entry of the change feed. An upsert carries the entity as
+// of that change; a delete is a tombstone with the id only.
+type Change struct {
+	Cursor     string         `json:"cursor"`
+	Op         string         `json:"op"`
+	EntityType string         `json:"entity_type"`
+	EntityID   uint           `json:"entity_id"`
+	ChangedAt  time.Time      `json:"changed_at"`
+	Department *DepartmentDTO `json:"department,omitempty"`
+	Employee   *EmployeeDTO   `json:"employee,omitempty"`
+}
+
+type ChangePage struct {
+	Changes []Change `json:"changes"`
+	// NextCursor is the since of the next request; it moves on even when
+	// the changes of a page were all filtered out.
+	NextCursor string `json:"next_cursor"`
+	HasMore    bool   `json:"has_more"`
+}
+
+type ChangeLister interface {
+	ListChanges(ctx context.Context, options ListChangesOptions) (ChangePage, error)
+}
+
+// ListChanges reads the change feed from the outbox: every change is one
+// event, so a cascade delete reports each removed department and employee.
+// Access and masking are the same as for the event stream.
+func (s *EventService) ListChanges(ctx context.Context, options ListChangesOptions) (ChangePage, error) {
+	limit := options.Limit
+	if limit == 0 {
+		limit = defaultChangesLimit
+	}
+	if limit < 0 || limit > maxChangesLimit {
+		return ChangePage{}, apperror.New(apperror.CodeValidation, fmt.Sprintf("limit must be between 1 and %d", maxChangesLimit))
+	}
+	departmentID, err := s.streamScope(ctx, options.DepartmentID)
+	if err != nil {
+		return ChangePage{}, err
+	}
+
+	// Cursors are feed positions, which follow the commit order.
+	head := s.feed.Head()
+	if options.Since == nil {
+		return ChangePage{Changes: []Change{}, NextCursor: formatCursor(head)}, nil
+	}
+	since := *options.Since
+	page := ChangePage{Changes: []Change{}, NextCursor: formatCursor(since)}
+	if since >= head {
+		return page, nil
+	}
+	retained, err := s.feed.Retained(ctx, since)
+	if err != nil {
+		return ChangePage{}, err
+	}
+	if !retained {
+		return ChangePage{}, errCursorExpired
+	}
+
+	events, err := s.feed.ReadEvents(ctx, since, limit)
+	if err != nil {
+		return ChangePage{}, err
+	}
+	// A short page means everything up to the head was read.
+	next := head
+	if len(events) == limit {
+		next = min(events[len(events)-1].Position, head)
+	}
+	mask := s.departments.employeeMask(ctx)
+	for _, event := range events {
+		if event.Position > next {
+			break
+		}
+		if departmentID != nil && !event.InScope(*departmentID) {
+			continue
+		}
+		change, err := changeFromEvent(event, mask)
+		if err != nil {
+			return ChangePage{}, err
+		}
+		page.Changes = append(page.Changes, change)
+	}
+	page.NextCursor = formatCursor(next)
+	page.HasMore = next < head
+	return page, nil
+}
+
+func changeFromEvent(event outbox.Event, mask func(EmployeeDTO) EmployeeDTO) (Change, error) {
+	change := Change{
+		Cursor:     formatCursor(event.Position),
+		Op:         ChangeUpsert,
+		EntityType: event.EntityType,
+		EntityID:   event.EntityID,
+		ChangedAt:  event.OccurredAt,
+	}
+	if event.Type == EventDepartmentDeleted || event.Type == EventEmployeeDeleted {
+		change.Op = ChangeDelete
+		return change, nil
+	}
+
+	switch event.EntityType {
+	case entityDepartment:
+		var payload DepartmentEvent
+		if err := json.Unmarshal(event.Data, &payload); err != nil {
+			return Change{}, fmt.Errorf("decode %s event: %w", event.Type, err)
+		}
+		change.Department = &payload.Department
+	case entityEmployee:
+		var payload EmployeeEvent
+		if err := json.Unmarshal(event.Data, &payload); err != nil {
+			return Change{}, fmt.Errorf("decode %s event: %w", event.Type, err)
+		}
+		employee := payload.Employee
+		if mask != nil {
+			employee = mask(employee)
+		}
+		change.Employee = &employee
+	}
+	return change, nil
+}
+
+func formatCursor(position uint) string {
+	return strconv.FormatUint(uint64(position), 10)
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"slices"
+	"strconv"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/apperror"
+	"hitalent-go-task/internal/auth"
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/outbox"
+	"hitalent-go-task/internal/repository/gormrepo"
+)
+
+// waitForFeed waits until the feed has caught up with the stored events.
+func waitForFeed(t *testing.T, database *gorm.DB, feed *outbox.Feed) {
+	t.Helper()
+	var newest uint
+	if err := database.Model(&models.OutboxEvent{}).Select("COALESCE(MAX(id), 0)").Scan(&newest).Error; err != nil {
+		t.Fatalf("load newest event: %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for feed.Head() < newest {
+		if time.Now().After(deadline) {
+			t.Fatalf("feed stuck at %d, expected %d", feed.Head(), newest)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestListChangesAfterCascadeDelete(t *testing.T) {
+	database := openSQLiteTestDatabase(t)
+	departments := NewDepartmentService(gormrepo.New(database))
+	feed := outbox.NewFeed(database, slog.New(slog.DiscardHandler), outbox.WithFeedInterval(5*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go feed.Run(ctx)
+	changes := NewEventService(departments, feed)
+
+	root := mustCreateDepartment(t, departments, "Company", nil)
+	engineering := mustCreateDepartment(t, departments, "Engineering", &root.ID)
+	backend := mustCreateDepartment(t, departments, "Backend", &engineering.ID)
+	sales := mustCreateDepartment(t, departments, "Sales", &root.ID)
+	lead, err := departments.CreateEmployee(ctx, engineering.ID, CreateEmployeeInput{FullName: "Anna Smirnova", Position: "Lead"})
+	if err != nil {
+		t.Fatalf("create employee: %v", err)
+	}
+	developer, err := departments.CreateEmployee(ctx, backend.ID, CreateEmployeeInput{FullName: "Ivan Petrov", Position: "Developer"})
+	if err != nil {
+		t.Fatalf("create employee: %v", err)
+	}
+	waitForFeed(t, database, feed)
+
+	start, err := changes.ListChanges(ctx, ListChangesOptions{})
+	if err != nil || len(start.Changes) != 0 || start.HasMore {
+		t.Fatalf("expected only the current cursor, got %+v, %v", start, err)
+	}
+	if err := departments.DeleteDepartment(ctx, engineering.ID, DeleteModeCascade, nil); err != nil {
+		t.Fatalf("delete department: %v", err)
+	}
+	waitForFeed(t, database, feed)
+
+	// Page through the changes two at a time.
+	var got []string
+	cursor, _ := strconv.ParseUint(start.NextCursor, 10, 64)
+	for {
+		since := uint(cursor)
+		page, err := changes.ListChanges(ctx, ListChangesOptions{Since: &since, Limit: 2})
+		if err != nil {
+			t.Fatalf("list changes: %v", err)
+		}
+		for _, change := range page.Changes {
+			got = append(got, fmt.Sprintf("%s %s %d", change.Op, change.EntityType, change.EntityID))
+		}
+		cursor, _ = strconv.ParseUint(page.NextCursor, 10, 64)
+		if !page.HasMore {
+			break
+		}
+	}
+	want := []string{
+		fmt.Sprintf("delete employee %d", lead.ID),
+		fmt.Sprintf("delete employee %d", developer.ID),
+		fmt.Sprintf("delete department %d", backend.ID),
+		fmt.Sprintf("delete department %d", engineering.ID),
+	}
+	slices.Sort(got)
+	slices.Sort(want)
+	if !slices.Equal(got, want) {
+		t.Fatalf("expected tombstones %v, got %v", want, got)
+	}
+
+	// A scoped caller only gets its subtree, with the same masking as reads.
+	zero := uint(0)
+	viewer := auth.WithPrincipal(ctx, auth.Principal{Subject: "mirror", Method: auth.MethodJWT, Role: auth.RoleViewer, ScopeDepartmentID: &sales.ID})
+	scoped, err := changes.ListChanges(viewer, ListChangesOptions{Since: &zero})
+	if err != nil || len(scoped.Changes) != 1 || scoped.Changes[0].Department == nil || scoped.Changes[0].Department.ID != sales.ID {
+		t.Fatalf("expected only the creation of sales, got %+v, %v", scoped, err)
+	}
+
+	_, err = changes.ListChanges(ctx, ListChangesOptions{Since: &zero, Limit: maxChangesLimit + 1})
+	assertCode(t, err, apperror.CodeValidation)
+	if err := database.Where("id <= ?", 2).Delete(&models.OutboxEvent{}).Error; err != nil {
+		t.Fatalf("purge events: %v", err)
+	}
+	_, err = changes.ListChanges(ctx, ListChangesOptions{Since: &zero})
+	assertCode(t, err, apperror.CodeGone)
+}
@@ -2,195 +2,563 @@ package service
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"strings"
+	"time"
 	"unicode/utf8"
 
 	"hitalent-go-task/internal/apperror"
+	"hitalent-go-task/internal/auth"
 	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+)
+
+var (
+	errDepartmentNotFound = apperror.New(apperror.CodeNotFound, "department not found")
+	errVersionMismatch    = apperror.New(apperror.CodePreconditionFailed, "department was modified by another request")
+	errSiblingNameTaken   = apperror.New(apperror.CodeConflict, "department name must be unique under the same parent")
+	errEmployeeNotFound   = apperror.New(apperror.CodeNotFound, "employee not found")
+	errEmployeeModified   = apperror.New(apperror.CodePreconditionFailed, "employee was modified by another request")
+	errEmployeeErased     = apperror.New(apperror.CodeConflict, "full name of an erased employee cannot be changed")
+)
+
+// erasedEmployeeName replaces the name of an erased employee.
+const erasedEmployeeName = "[erased]"
 
-	"github.com/jackc/pgx/v5/pgconn"
-	"gorm.io/gorm"
+const (
+	defaultMaxDepth       = 5
+	defaultMaxFieldLength = 200
+	streamBatchSize       = 500
 )
 
 type DepartmentService struct {
-	db *gorm.DB
+	store          repository.Store
+	maxDepth       int
+	maxFieldLength int
+	batchSize      int
+	employeeFields EmployeeFieldPolicy
+	trees          *treeCache
 }
 
-func NewDepartmentService(db *gorm.DB) *DepartmentService {
-	return &DepartmentService{db: db}
+type Option func(*DepartmentService)
+
+// WithMaxDepth limits how deep a subtree may be requested. A negative value
+// removes the limit.
+func WithMaxDepth(depth int) Option {
+	return func(s *DepartmentService) {
+		s.maxDepth = depth
+	}
 }
 
-func (s *DepartmentService) CreateDepartment(ctx context.Context, input CreateDepartmentInput) (DepartmentDTO, error) {
-	name, err := normalizeRequiredString(input.Name, "name")
-	if err != nil {
-		return DepartmentDTO{}, err
+// WithMaxFieldLength limits department names and employee full names and
+// positions, counted in characters.
+func WithMaxFieldLength(length int) Option {
+	return func(s *DepartmentService) {
+		s.maxFieldLength = length
 	}
+}
 
-	if input.ParentID != nil {
-		if err := s.ensureDepartmentExists(ctx, *input.ParentID); err != nil {
-			return DepartmentDTO{}, err
-		}
+// WithEmployeeFieldPolicy replaces DefaultEmployeeFieldPolicy.
+func WithEmployeeFieldPolicy(policy EmployeeFieldPolicy) Option {
+	return func(s *DepartmentService) {
+		s.employeeFields = policy
+	}
+}
+
+func NewDepartmentService(store repository.Store, opts ...Option) *DepartmentService {
+	s := &DepartmentService{
+		store:          store,
+		maxDepth:       defaultMaxDepth,
+		maxFieldLength: defaultMaxFieldLength,
+		batchSize:      streamBatchSize,
+		employeeFields: DefaultEmployeeFieldPolicy(),
 	}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
 
-	exists, err := s.siblingNameExists(ctx, input.ParentID, name, nil)
+func (s *DepartmentService) CreateDepartment(ctx context.Context, input CreateDepartmentInput) (DepartmentDTO, error) {
+	name, err := s.normalizeRequiredString(input.Name, "name")
 	if err != nil {
 		return DepartmentDTO{}, err
 	}
-	if exists {
-		return DepartmentDTO{}, apperror.New(apperror.CodeConflict, "department name must be unique under the same parent")
-	}
 
-	department := models.Department{
-		Name:     name,
-		ParentID: input.ParentID,
-	}
+	var department models.Department
+	var stale []string
+	err = s.store.WithinTransaction(ctx, func(tx repository.Repositories) error {
+		if input.ParentID != nil {
+			if err := ensureDepartmentExists(ctx, tx, *input.ParentID); err != nil {
+				return err
+			}
+		}
+		if err := authorize(ctx, tx, auth.RoleEditor, input.ParentID); err != nil {
+			return err
+		}
 
-	if err := s.db.WithContext(ctx).Create(&department).Error; err != nil {
-		return DepartmentDTO{}, mapDatabaseError(err)
+		exists, err := tx.Departments().SiblingNameExists(ctx, input.ParentID, name, nil)
+		if err != nil {
+			return err
+		}
+		if exists {
+			return errSiblingNameTaken
+		}
+
+		department = models.Department{
+			Name:     name,
+			ParentID: input.ParentID,
+		}
+		if err := tx.Departments().Create(ctx, &department); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, "department.create", "department", department.ID); err != nil {
+			return err
+		}
+		scope, err := eventScope(ctx, tx, department.ID)
+		if err != nil {
+			return err
+		}
+		if err := recordDepartmentEvent(ctx, tx, EventDepartmentCreated, DepartmentEvent{Department: departmentToDTO(department)}, scope); err != nil {
+			return err
+		}
+		if input.ParentID == nil {
+			return nil
+		}
+		if stale, err = s.staleTrees(ctx, tx, *input.ParentID); err != nil {
+			return err
+		}
+		return tx.Departments().BumpVersions(ctx, *input.ParentID)
+	})
+	if err != nil {
+		return DepartmentDTO{}, err
 	}
+	s.invalidateTrees(ctx, stale)
 
 	return departmentToDTO(department), nil
 }
 
 func (s *DepartmentService) CreateEmployee(ctx context.Context, departmentID uint, input CreateEmployeeInput) (EmployeeDTO, error) {
-	fullName, err := normalizeRequiredString(input.FullName, "full_name")
+	fullName, err := s.normalizeRequiredString(input.FullName, "full_name")
 	if err != nil {
 		return EmployeeDTO{}, err
 	}
 
-	position, err := normalizeRequiredString(input.Position, "position")
+	position, err := s.normalizeRequiredString(input.Position, "position")
 	if err != nil {
 		return EmployeeDTO{}, err
 	}
 
-	if err := s.ensureDepartmentExists(ctx, departmentID); err != nil {
-		return EmployeeDTO{}, err
-	}
-
-	employee := models.Employee{
-		DepartmentID: departmentID,
-		FullName:     fullName,
-		Position:     position,
-		HiredAt:      input.HiredAt,
-	}
+	var employee models.Employee
+	var stale []string
+	err = s.store.WithinTransaction(ctx, func(tx repository.Repositories) error {
+		if err := ensureDepartmentExists(ctx, tx, departmentID); err != nil {
+			return err
+		}
+		if err := authorize(ctx, tx, auth.RoleEditor, &departmentID); err != nil {
+			return err
+		}
 
-	if err := s.db.WithContext(ctx).Create(&employee).Error; err != nil {
-		return EmployeeDTO{}, mapDatabaseError(err)
+		employee = models.Employee{
+			DepartmentID: departmentID,
+			FullName:     fullName,
+			Position:     position,
+			HiredAt:      input.HiredAt,
+		}
+		if err := tx.Employees().Create(ctx, &employee); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, "employee.create", "employee", employee.ID); err != nil {
+			return err
+		}
+		scope, err := eventScope(ctx, tx, departmentID)
+		if err != nil {
+			return err
+		}
+		if err := recordEmployeeEvent(ctx, tx, EventEmployeeCreated, EmployeeEvent{Employee: employeeToDTO(employee)}, scope); err != nil {
+			return err
+		}
+		if stale, err = s.staleTrees(ctx, tx, departmentID); err != nil {
+			return err
+		}
+		return tx.Departments().BumpVersions(ctx, departmentID)
+	})
+	if err != nil {
+		return EmployeeDTO{}, err
 	}
+	s.invalidateTrees(ctx, stale)
 
-	return employeeToDTO(employee), nil
+	return s.employeeView(ctx)(employee), nil
 }
 
 func (s *DepartmentService) GetDepartment(ctx context.Context, departmentID uint, options GetDepartmentOptions) (DepartmentTree, error) {
-	var department models.Department
-	if err := s.db.WithContext(ctx).First(&department, departmentID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return DepartmentTree{}, apperror.New(apperror.CodeNotFound, "department not found")
+	depth, err := s.resolveDepth(options.Depth)
+	if err != nil {
+		return DepartmentTree{}, err
+	}
+
+	cacheKey := s.treeCacheKeyFor(departmentID, depth, options.IncludeEmployees)
+	if cacheKey != "" {
+		if tree, ok := s.cachedTree(ctx, cacheKey); ok {
+			if err := authorize(ctx, s.store, auth.RoleViewer, &departmentID); err != nil {
+				return DepartmentTree{}, err
+			}
+			if err := s.checkWholeSubtree(ctx, departmentID, options.Depth); err != nil {
+				return DepartmentTree{}, err
+			}
+			if mask := s.employeeMask(ctx); mask != nil {
+				maskTree(&tree, mask)
+			}
+			return tree, nil
 		}
-		return DepartmentTree{}, fmt.Errorf("load department: %w", err)
+		// A lagging replica could refill an entry just dropped by a write and
+		// keep it stale for the whole ttl.
+		ctx = repository.WithPrimaryReads(ctx)
 	}
 
-	if options.Depth < 0 || options.Depth > 5 {
-		return DepartmentTree{}, apperror.New(apperror.CodeValidation, "depth must be between 0 and 5")
+	department, err := loadDepartment(ctx, s.store, departmentID)
+	if err != nil {
+		return DepartmentTree{}, err
+	}
+	if err := authorize(ctx, s.store, auth.RoleViewer, &departmentID); err != nil {
+		return DepartmentTree{}, err
+	}
+	if err := s.checkWholeSubtree(ctx, departmentID, options.Depth); err != nil {
+		return DepartmentTree{}, err
 	}
 
-	tree, err := s.buildTree(ctx, department, options.Depth, options.IncludeEmployees)
+	descendants, err := s.store.Departments().ListDescendants(ctx, departmentID, depth)
 	if err != nil {
 		return DepartmentTree{}, err
 	}
 
+	var employees []models.Employee
+	if options.IncludeEmployees {
+		departmentIDs := make([]uint, 0, len(descendants)+1)
+		departmentIDs = append(departmentIDs, department.ID)
+		for _, descendant := range descendants {
+			departmentIDs = append(departmentIDs, descendant.ID)
+		}
+		employees, err = s.store.Employees().ListByDepartments(ctx, departmentIDs)
+		if err != nil {
+			return DepartmentTree{}, err
+		}
+	}
+
+	if cacheKey == "" {
+		return buildTree(department, descendants, employees, options.IncludeEmployees, s.employeeView(ctx)), nil
+	}
+	tree := buildTree(department, descendants, employees, options.IncludeEmployees, employeeToDTO)
+	s.storeTree(ctx, cacheKey, tree)
+	if mask := s.employeeMask(ctx); mask != nil {
+		maskTree(&tree, mask)
+	}
 	return tree, nil
 }
 
-func (s *DepartmentService) UpdateDepartment(ctx context.Context, departmentID uint, input UpdateDepartmentInput) (DepartmentDTO, error) {
-	var department models.Department
-	if err := s.db.WithContext(ctx).First(&department, departmentID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return DepartmentDTO{}, apperror.New(apperror.CodeNotFound, "department not found")
+// StreamDepartment walks the subtree in pages instead of building it in memory.
+// Pages are read independently, so the stream is not a snapshot: concurrent
+// moves may be reflected partially.
+func (s *DepartmentService) StreamDepartment(ctx context.Context, departmentID uint, options GetDepartmentOptions, emit func(DepartmentNode) error) error {
+	department, err := loadDepartment(ctx, s.store, departmentID)
+	if err != nil {
+		return err
+	}
+	if err := authorize(ctx, s.store, auth.RoleViewer, &departmentID); err != nil {
+		return err
+	}
+
+	depth, err := s.resolveDepth(options.Depth)
+	if err != nil {
+		return err
+	}
+	if err := s.checkWholeSubtree(ctx, departmentID, options.Depth); err != nil {
+		return err
+	}
+
+	return s.walkSubtree(ctx, department, depth, options.IncludeEmployees, emit)
+}
+
+// walkSubtree emits the department and its descendants down to depth, a page
+// at a time, parents first. Access must already be checked.
+func (s *DepartmentService) walkSubtree(ctx context.Context, department models.Department, depth int, includeEmployees bool, emit func(DepartmentNode) error) error {
+	nodes := []repository.DescendantNode{{Department: department}}
+	if err := s.emitNodes(ctx, nodes, includeEmployees, emit); err != nil {
+		return err
+	}
+
+	var cursor repository.DescendantCursor
+	for {
+		var err error
+		nodes, err = s.store.Departments().ListDescendantsPage(ctx, department.ID, depth, cursor, s.batchSize)
+		if err != nil {
+			return err
 		}
-		return DepartmentDTO{}, fmt.Errorf("load department: %w", err)
+		if len(nodes) == 0 {
+			return nil
+		}
+		if err := s.emitNodes(ctx, nodes, includeEmployees, emit); err != nil {
+			return err
+		}
+		if len(nodes) < s.batchSize {
+			return nil
+		}
+		last := nodes[len(nodes)-1]
+		cursor = repository.DescendantCursor{Depth: last.Depth, ID: last.Department.ID}
 	}
+}
 
-	if input.Name == nil && !input.ParentIDSet {
-		return departmentToDTO(department), nil
+func (s *DepartmentService) emitNodes(ctx context.Context, nodes []repository.DescendantNode, includeEmployees bool, emit func(DepartmentNode) error) error {
+	employeesByDepartment := make(map[uint][]EmployeeDTO)
+	if includeEmployees {
+		toDTO := s.employeeView(ctx)
+		departmentIDs := make([]uint, 0, len(nodes))
+		for _, node := range nodes {
+			departmentIDs = append(departmentIDs, node.Department.ID)
+		}
+		employees, err := s.store.Employees().ListByDepartments(ctx, departmentIDs)
+		if err != nil {
+			return err
+		}
+		for _, employee := range employees {
+			employeesByDepartment[employee.DepartmentID] = append(employeesByDepartment[employee.DepartmentID], toDTO(employee))
+		}
+	}
+
+	for _, node := range nodes {
+		result := DepartmentNode{
+			Department: departmentToDTO(node.Department),
+			Depth:      node.Depth,
+		}
+		if includeEmployees {
+			employeesDTO := employeesByDepartment[node.Department.ID]
+			if employeesDTO == nil {
+				employeesDTO = []EmployeeDTO{}
+			}
+			result.Employees = &employeesDTO
+		}
+		if err := emit(result); err != nil {
+			return err
+		}
 	}
+	return nil
+}
 
-	newName := department.Name
+// resolveDepth validates the requested depth against the server limit and
+// returns the value to pass to the repository (negative means unlimited).
+// depth=all additionally needs checkWholeSubtree.
+func (s *DepartmentService) resolveDepth(depth int) (int, error) {
+	if depth == DepthAll {
+		return s.maxDepth, nil
+	}
+	if depth < 0 {
+		return 0, apperror.New(apperror.CodeValidation, "depth must be a non-negative integer or all")
+	}
+	if s.maxDepth >= 0 && depth > s.maxDepth {
+		return 0, apperror.New(apperror.CodeValidation, fmt.Sprintf("depth must be between 0 and %d", s.maxDepth))
+	}
+	return depth, nil
+}
+
+// checkWholeSubtree refuses depth=all when the subtree goes below the server
+// limit, rather than returning a cut tree that looks complete.
+func (s *DepartmentService) checkWholeSubtree(ctx context.Context, departmentID uint, depth int) error {
+	if depth != DepthAll || s.maxDepth < 0 {
+		return nil
+	}
+	below := repository.DescendantCursor{Depth: s.maxDepth + 1}
+	nodes, err := s.store.Departments().ListDescendantsPage(ctx, departmentID, -1, below, 1)
+	if err != nil {
+		return err
+	}
+	if len(nodes) > 0 {
+		return apperror.New(apperror.CodeValidation, fmt.Sprintf("subtree is deeper than the maximum depth %d; request depth=%d or a department further down", s.maxDepth, s.maxDepth))
+	}
+	return nil
+}
+
+func (s *DepartmentService) UpdateDepartment(ctx context.Context, departmentID uint, input UpdateDepartmentInput) (DepartmentDTO, error) {
+	var newName string
 	if input.Name != nil {
-		normalized, err := normalizeRequiredString(*input.Name, "name")
+		normalized, err := s.normalizeRequiredString(*input.Name, "name")
 		if err != nil {
 			return DepartmentDTO{}, err
 		}
 		newName = normalized
 	}
+	if input.ParentIDSet && input.ParentID != nil && *input.ParentID == departmentID {
+		return DepartmentDTO{}, apperror.New(apperror.CodeValidation, "department cannot be parent of itself")
+	}
+
+	var department models.Department
+	var stale []string
+	err := s.store.WithinTransaction(ctx, func(tx repository.Repositories) error {
+		var err error
+		stale = nil
+		department, err = loadDepartment(ctx, tx, departmentID)
+		if err != nil {
+			return err
+		}
+
+		// Moving needs rights on both the old and the new parent, so a scoped
+		// editor can neither pull departments in nor push them out.
+		targets := []*uint{&departmentID}
+		if input.ParentIDSet {
+			targets = append(targets, department.ParentID, input.ParentID)
+		}
+		if err := authorize(ctx, tx, auth.RoleEditor, targets...); err != nil {
+			return err
+		}
+
+		if input.ExpectedVersion != nil && *input.ExpectedVersion != department.Version {
+			return errVersionMismatch
+		}
+
+		changed, changedStale, err := s.changeDepartment(ctx, tx, department, newName, input)
+		if err != nil {
+			return err
+		}
+		department = changed
+		stale = append(stale, changedStale...)
+		if len(input.EmployeeIDs) == 0 {
+			return nil
+		}
+
+		moved, err := s.moveEmployees(ctx, tx, departmentID, input.EmployeeIDs)
+		if err != nil {
+			return err
+		}
+		stale = append(stale, moved...)
+		department, err = loadDepartment(ctx, tx, departmentID)
+		return err
+	})
+	if err != nil {
+		return DepartmentDTO{}, err
+	}
+	s.invalidateTrees(ctx, stale)
+
+	return departmentToDTO(department), nil
+}
+
+// changeDepartment renames and moves the loaded department as requested. It
+// returns the department as stored afterwards and the stale cached trees.
+func (s *DepartmentService) changeDepartment(ctx context.Context, tx repository.Repositories, department models.Department, newName string, input UpdateDepartmentInput) (models.Department, []string, error) {
+	departmentID := department.ID
+	var stale []string
+	if input.Name == nil && !input.ParentIDSet {
+		return department, nil, nil
+	}
+
+	name := department.Name
+	if input.Name != nil {
+		name = newName
+	}
 
 	newParentID := department.ParentID
 	if input.ParentIDSet {
 		newParentID = input.ParentID
-		if newParentID != nil && *newParentID == departmentID {
-			return DepartmentDTO{}, apperror.New(apperror.CodeValidation, "department cannot be parent of itself")
-		}
 	}
 
 	if input.ParentIDSet && newParentID != nil {
-		if err := s.ensureDepartmentExists(ctx, *newParentID); err != nil {
-			return DepartmentDTO{}, err
+		if err := ensureDepartmentExists(ctx, tx, *newParentID); err != nil {
+			return models.Department{}, nil, err
 		}
-		willCycle, err := s.wouldCreateCycle(ctx, departmentID, *newParentID)
+		willCycle, err := wouldCreateCycle(ctx, tx, departmentID, *newParentID)
 		if err != nil {
-			return DepartmentDTO{}, err
+			return models.Department{}, nil, err
 		}
 		if willCycle {
-			return DepartmentDTO{}, apperror.New(apperror.CodeConflict, "department cycle detected")
+			return models.Department{}, nil, apperror.New(apperror.CodeConflict, "department cycle detected")
 		}
 	}
 
-	exists, err := s.siblingNameExists(ctx, newParentID, newName, &departmentID)
+	exists, err := tx.Departments().SiblingNameExists(ctx, newParentID, name, &departmentID)
 	if err != nil {
-		return DepartmentDTO{}, err
+		return models.Department{}, nil, err
 	}
 	if exists {
-		return DepartmentDTO{}, apperror.New(apperror.CodeConflict, "department name must be unique under the same parent")
+		return models.Department{}, nil, errSiblingNameTaken
 	}
 
-	updates := map[string]interface{}{}
-	if input.Name != nil && newName != department.Name {
-		updates["name"] = newName
+	var changes repository.DepartmentChanges
+	if name != department.Name {
+		changes.Name = &name
 	}
 	if input.ParentIDSet && !equalUintPtr(department.ParentID, newParentID) {
-		updates["parent_id"] = newParentID
+		changes.ParentIDSet = true
+		changes.ParentID = newParentID
+	}
+	if changes.Name == nil && !changes.ParentIDSet {
+		return department, nil, nil
 	}
 
-	if len(updates) > 0 {
-		if err := s.db.WithContext(ctx).Model(&department).Updates(updates).Error; err != nil {
-			return DepartmentDTO{}, mapDatabaseError(err)
-		}
-		if err := s.db.WithContext(ctx).First(&department, departmentID).Error; err != nil {
-			return DepartmentDTO{}, fmt.Errorf("reload department: %w", err)
-		}
+	// The old position is only known before the move.
+	if stale, err = s.staleTrees(ctx, tx, departmentID); err != nil {
+		return models.Department{}, nil, err
+	}
+	previousScope, err := eventScope(ctx, tx, departmentID)
+	if err != nil {
+		return models.Department{}, nil, err
+	}
+	updated, err := tx.Departments().Update(ctx, departmentID, department.Version, changes)
+	if err != nil {
+		return models.Department{}, nil, err
+	}
+	if !updated {
+		return models.Department{}, nil, errVersionMismatch
+	}
+	if err := recordAudit(ctx, tx, "department.update", "department", departmentID); err != nil {
+		return models.Department{}, nil, err
 	}
 
-	return departmentToDTO(department), nil
-}
+	var touched []uint
+	if department.ParentID != nil {
+		touched = append(touched, *department.ParentID)
+	}
+	if newParentID != nil {
+		touched = append(touched, *newParentID)
+	}
+	if err := tx.Departments().BumpVersions(ctx, touched...); err != nil {
+		return models.Department{}, nil, err
+	}
+	if changes.ParentIDSet && newParentID != nil {
+		moved, err := s.staleTrees(ctx, tx, *newParentID)
+		if err != nil {
+			return models.Department{}, nil, err
+		}
+		stale = append(stale, moved...)
+	}
 
-func (s *DepartmentService) DeleteDepartment(ctx context.Context, departmentID uint, mode DeleteMode, reassignToDepartmentID *uint) error {
-	var department models.Department
-	if err := s.db.WithContext(ctx).First(&department, departmentID).Error; err != nil {
-		if errors.Is(err, gorm.ErrRecordNotFound) {
-			return apperror.New(apperror.CodeNotFound, "department not found")
+	previous := department
+	department, err = loadDepartment(ctx, tx, departmentID)
+	if err != nil {
+		return models.Department{}, nil, err
+	}
+	scope, err := eventScope(ctx, tx, departmentID)
+	if err != nil {
+		return models.Department{}, nil, err
+	}
+	scope = append(scope, previousScope...)
+	if changes.Name != nil {
+		event := DepartmentEvent{Department: departmentToDTO(department), PreviousName: previous.Name}
+		if err := recordDepartmentEvent(ctx, tx, EventDepartmentRenamed, event, scope); err != nil {
+			return models.Department{}, nil, err
+		}
+	}
+	if changes.ParentIDSet {
+		event := DepartmentEvent{Department: departmentToDTO(department), PreviousParentID: previous.ParentID}
+		if err := recordDepartmentEvent(ctx, tx, EventDepartmentMoved, event, scope); err != nil {
+			return models.Department{}, nil, err
 		}
-		return fmt.Errorf("load department: %w", err)
 	}
+	return department, stale, nil
+}
 
+func (s *DepartmentService) DeleteDepartment(ctx context.Context, departmentID uint, mode DeleteMode, reassignToDepartmentID *uint) error {
 	switch mode {
 	case DeleteModeCascade:
-		if err := s.db.WithContext(ctx).Delete(&models.Department{}, departmentID).Error; err != nil {
-			return mapDatabaseError(err)
-		}
-		return nil
-
 	case DeleteModeReassign:
 		if reassignToDepartmentID == nil {
 			return apperror.New(apperror.CodeValidation, "reassign_to_department_id is required when mode=reassign")
@@ -198,129 +566,501 @@ func (s *DepartmentService) DeleteDepartment(ctx context.Context, departmentID u
 		if *reassignToDepartmentID == departmentID {
 			return apperror.New(apperror.CodeValidation, "reassign_to_department_id cannot be the same department")
 		}
-		if err := s.ensureDepartmentExists(ctx, *reassignToDepartmentID); err != nil {
+	default:
+		return apperror.New(apperror.CodeValidation, "mode must be one of: cascade, reassign")
+	}
+
+	var stale []string
+	err := s.store.WithinTransaction(ctx, func(tx repository.Repositories) error {
+		department, err := loadDepartment(ctx, tx, departmentID)
+		if err != nil {
+			return err
+		}
+		// Children and employees end up under the parent or the reassign
+		// target, so both must be within the caller's scope too.
+		targets := []*uint{&departmentID, department.ParentID}
+		if mode == DeleteModeReassign {
+			targets = append(targets, reassignToDepartmentID)
+		}
+		if err := authorize(ctx, tx, auth.RoleAdmin, targets...); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, "department.delete."+string(mode), "department", departmentID); err != nil {
 			return err
 		}
 
-		return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
-			if err := tx.Model(&models.Employee{}).
-				Where("department_id = ?", departmentID).
-				Update("department_id", *reassignToDepartmentID).Error; err != nil {
-				return mapDatabaseError(err)
+		// A cascade removes the whole subtree with its employees; a
+		// reassignment moves the employees and re-parents the direct children.
+		removedDepth := 1
+		employeeDepartments := []uint{departmentID}
+		if mode == DeleteModeCascade {
+			removedDepth = DepthAll
+		}
+		descendants, err := tx.Departments().ListDescendants(ctx, departmentID, removedDepth)
+		if err != nil {
+			return err
+		}
+		if mode == DeleteModeCascade {
+			for _, descendant := range descendants {
+				employeeDepartments = append(employeeDepartments, descendant.ID)
 			}
+		}
+		employees, err := tx.Employees().ListByDepartments(ctx, employeeDepartments)
+		if err != nil {
+			return err
+		}
 
-			if err := tx.Model(&models.Department{}).
-				Where("parent_id = ?", departmentID).
-				Update("parent_id", department.ParentID).Error; err != nil {
-				return mapDatabaseError(err)
-			}
+		// The department disappears from its ancestors' subtrees, and neither
+		// its own entries nor those of removed or re-parented descendants may
+		// outlive the change.
+		if stale, err = s.staleTrees(ctx, tx, departmentID); err != nil {
+			return err
+		}
+		for _, descendant := range descendants {
+			stale = append(stale, s.treeKeys(descendant.ID)...)
+		}
+		scope, err := eventScope(ctx, tx, departmentID)
+		if err != nil {
+			return err
+		}
 
-			if err := tx.Delete(&models.Department{}, departmentID).Error; err != nil {
-				return mapDatabaseError(err)
+		if mode == DeleteModeCascade {
+			if err := tx.Departments().Delete(ctx, departmentID); err != nil {
+				return err
+			}
+			if err := recordCascadeEvents(ctx, tx, department, descendants, employees, scope); err != nil {
+				return err
+			}
+			if department.ParentID != nil {
+				return tx.Departments().BumpVersions(ctx, *department.ParentID)
 			}
-
 			return nil
-		})
+		}
 
-	default:
-		return apperror.New(apperror.CodeValidation, "mode must be one of: cascade, reassign")
+		if err := ensureDepartmentExists(ctx, tx, *reassignToDepartmentID); err != nil {
+			return err
+		}
+		if err := tx.Employees().Reassign(ctx, departmentID, *reassignToDepartmentID); err != nil {
+			return err
+		}
+		if err := tx.Departments().ReparentChildren(ctx, departmentID, department.ParentID); err != nil {
+			return err
+		}
+		if err := tx.Departments().Delete(ctx, departmentID); err != nil {
+			return err
+		}
+		if err := recordReassignEvents(ctx, tx, department, descendants, employees, *reassignToDepartmentID, scope); err != nil {
+			return err
+		}
+
+		touched := []uint{*reassignToDepartmentID}
+		if department.ParentID != nil {
+			touched = append(touched, *department.ParentID)
+		}
+		reassigned, err := s.staleTrees(ctx, tx, *reassignToDepartmentID)
+		if err != nil {
+			return err
+		}
+		stale = append(stale, reassigned...)
+		return tx.Departments().BumpVersions(ctx, touched...)
+	})
+	if err != nil {
+		return err
 	}
+	s.invalidateTrees(ctx, stale)
+	return nil
 }
 
-func (s *DepartmentService) buildTree(ctx context.Context, department models.Department, depth int, includeEmployees bool) (DepartmentTree, error) {
-	result := DepartmentTree{
-		Department: departmentToDTO(department),
-		Children:   []DepartmentTree{},
+// recordCascadeEvents reports every removed employee and department, children
+// before their parents, so that consumers can mirror the deletion bottom-up.
+// The removed rows no longer have an ancestry, so each scope is derived from
+// the root's one.
+func recordCascadeEvents(ctx context.Context, tx repository.Repositories, root models.Department, descendants []models.Department, employees []models.Employee, rootScope []uint) error {
+	childrenByParent := make(map[uint][]models.Department)
+	for _, descendant := range descendants {
+		if descendant.ParentID != nil {
+			childrenByParent[*descendant.ParentID] = append(childrenByParent[*descendant.ParentID], descendant)
+		}
 	}
-
-	if includeEmployees {
-		var employees []models.Employee
-		if err := s.db.WithContext(ctx).
-			Where("department_id = ?", department.ID).
-			Order("full_name ASC").
-			Find(&employees).Error; err != nil {
-			return DepartmentTree{}, fmt.Errorf("load employees: %w", err)
+	scopes := map[uint][]uint{root.ID: rootScope}
+	queue := []uint{root.ID}
+	for len(queue) > 0 {
+		parentID := queue[0]
+		queue = queue[1:]
+		for _, child := range childrenByParent[parentID] {
+			scopes[child.ID] = append([]uint{child.ID}, scopes[parentID]...)
+			queue = append(queue, child.ID)
 		}
+	}
 
-		employeesDTO := make([]EmployeeDTO, 0, len(employees))
-		for _, employee := range employees {
-			employeesDTO = append(employeesDTO, employeeToDTO(employee))
+	for _, employee := range employees {
+		if err := recordEmployeeEvent(ctx, tx, EventEmployeeDeleted, EmployeeEvent{Employee: employeeToDTO(employee)}, scopes[employee.DepartmentID]); err != nil {
+			return err
 		}
-		result.Employees = &employeesDTO
 	}
 
-	if depth == 0 {
-		return result, nil
+	var visit func(department models.Department) error
+	visit = func(department models.Department) error {
+		for _, child := range childrenByParent[department.ID] {
+			if err := visit(child); err != nil {
+				return err
+			}
+		}
+		return recordDepartmentEvent(ctx, tx, EventDepartmentDeleted, DepartmentEvent{Department: departmentToDTO(department)}, scopes[department.ID])
 	}
+	return visit(root)
+}
 
-	var children []models.Department
-	if err := s.db.WithContext(ctx).
-		Where("parent_id = ?", department.ID).
-		Order("name ASC").
-		Find(&children).Error; err != nil {
-		return DepartmentTree{}, fmt.Errorf("load child departments: %w", err)
+// recordReassignEvents reports the moved employees and children with their
+// new state, then the deletion itself. deletedScope is the ancestry of the
+// deleted department taken before the deletion.
+func recordReassignEvents(ctx context.Context, tx repository.Repositories, deleted models.Department, children []models.Department, employees []models.Employee, targetID uint, deletedScope []uint) error {
+	if len(employees) > 0 {
+		targetScope, err := eventScope(ctx, tx, targetID)
+		if err != nil {
+			return err
+		}
+		scope := append(targetScope, deletedScope...)
+		moved := make(map[uint]bool, len(employees))
+		for _, employee := range employees {
+			moved[employee.ID] = true
+		}
+		current, err := tx.Employees().ListByDepartments(ctx, []uint{targetID})
+		if err != nil {
+			return err
+		}
+		for _, employee := range current {
+			if !moved[employee.ID] {
+				continue
+			}
+			event := EmployeeEvent{Employee: employeeToDTO(employee), PreviousDepartmentID: deleted.ID}
+			if err := recordEmployeeEvent(ctx, tx, EventEmployeeMoved, event, scope); err != nil {
+				return err
+			}
+		}
 	}
 
 	for _, child := range children {
-		childTree, err := s.buildTree(ctx, child, depth-1, includeEmployees)
+		current, err := loadDepartment(ctx, tx, child.ID)
 		if err != nil {
-			return DepartmentTree{}, err
+			return err
+		}
+		scope, err := eventScope(ctx, tx, child.ID)
+		if err != nil {
+			return err
+		}
+		scope = append(scope, child.ID)
+		scope = append(scope, deletedScope...)
+		event := DepartmentEvent{Department: departmentToDTO(current), PreviousParentID: &deleted.ID}
+		if err := recordDepartmentEvent(ctx, tx, EventDepartmentMoved, event, scope); err != nil {
+			return err
+		}
+	}
+	return recordDepartmentEvent(ctx, tx, EventDepartmentDeleted, DepartmentEvent{Department: departmentToDTO(deleted)}, deletedScope)
+}
+
+// EraseEmployee anonymizes an employee: the name and hire date are removed,
+// while the record, its position and department stay so headcounts remain
+// correct. Erasing an already erased employee is a no-op.
+func (s *DepartmentService) EraseEmployee(ctx context.Context, departmentID uint, employeeID uint) (EmployeeDTO, error) {
+	var employee models.Employee
+	var stale []string
+	err := s.store.WithinTransaction(ctx, func(tx repository.Repositories) error {
+		var err error
+		stale = nil
+		employee, err = tx.Employees().Get(ctx, employeeID)
+		if errors.Is(err, repository.ErrNotFound) || (err == nil && employee.DepartmentID != departmentID) {
+			return errEmployeeNotFound
+		}
+		if err != nil {
+			return err
+		}
+		if err := authorize(ctx, tx, auth.RoleAdmin, &employee.DepartmentID); err != nil {
+			return err
+		}
+		if employee.ErasedAt != nil {
+			return nil
+		}
+
+		if err := tx.Employees().Anonymize(ctx, employeeID, erasedEmployeeName, time.Now().UTC()); err != nil {
+			return err
+		}
+		if err := recordAudit(ctx, tx, "employee.erase", "employee", employeeID); err != nil {
+			return err
+		}
+		if stale, err = s.staleTrees(ctx, tx, employee.DepartmentID); err != nil {
+			return err
+		}
+		if err := tx.Departments().BumpVersions(ctx, employee.DepartmentID); err != nil {
+			return err
 		}
-		result.Children = append(result.Children, childTree)
+		employee, err = tx.Employees().Get(ctx, employeeID)
+		if err != nil {
+			return err
+		}
+
+		// Earlier events still carry the personal data; only the anonymized
+		// record may remain, delivered or not.
+		redacted, err := json.Marshal(EmployeeEvent{Employee: employeeToDTO(employee), Redacted: true})
+		if err != nil {
+			return err
+		}
+		if err := tx.Outbox().Redact(ctx, entityEmployee, employeeID, string(redacted)); err != nil {
+			return err
+		}
+		scope, err := eventScope(ctx, tx, employee.DepartmentID)
+		if err != nil {
+			return err
+		}
+		return recordEmployeeEvent(ctx, tx, EventEmployeeErased, EmployeeEvent{Employee: employeeToDTO(employee)}, scope)
+	})
+	if err != nil {
+		return EmployeeDTO{}, err
 	}
+	s.invalidateTrees(ctx, stale)
 
-	return result, nil
+	return s.employeeView(ctx)(employee), nil
 }
 
-func (s *DepartmentService) ensureDepartmentExists(ctx context.Context, departmentID uint) error {
-	var count int64
-	if err := s.db.WithContext(ctx).Model(&models.Department{}).Where("id = ?", departmentID).Count(&count).Error; err != nil {
-		return fmt.Errorf("check department existence: %w", err)
+func (s *DepartmentService) UpdateEmployee(ctx context.Context, employeeID uint, input UpdateEmployeeInput) (EmployeeDTO, error) {
+	var changes repository.EmployeeChanges
+	if input.FullName != nil {
+		fullName, err := s.normalizeRequiredString(*input.FullName, "full_name")
+		if err != nil {
+			return EmployeeDTO{}, err
+		}
+		changes.FullName = &fullName
+	}
+	if input.Position != nil {
+		position, err := s.normalizeRequiredString(*input.Position, "position")
+		if err != nil {
+			return EmployeeDTO{}, err
+		}
+		changes.Position = &position
+	}
+	changes.DepartmentID = input.DepartmentID
+
+	var employee models.Employee
+	var stale []string
+	err := s.store.WithinTransaction(ctx, func(tx repository.Repositories) error {
+		var err error
+		employee, err = loadEmployee(ctx, tx, employeeID)
+		if err != nil {
+			return err
+		}
+		// As with departments, a move needs rights on both ends.
+		targets := []*uint{&employee.DepartmentID}
+		if input.DepartmentID != nil {
+			if err := ensureDepartmentExists(ctx, tx, *input.DepartmentID); err != nil {
+				return err
+			}
+			targets = append(targets, input.DepartmentID)
+		}
+		if err := authorize(ctx, tx, auth.RoleEditor, targets...); err != nil {
+			return err
+		}
+		if input.ExpectedVersion != nil && *input.ExpectedVersion != employee.Version {
+			return errEmployeeModified
+		}
+		if changes.FullName != nil && employee.ErasedAt != nil {
+			return errEmployeeErased
+		}
+		employee, stale, err = s.changeEmployee(ctx, tx, employee, changes)
+		return err
+	})
+	if err != nil {
+		return EmployeeDTO{}, err
 	}
-	if count == 0 {
-		return apperror.New(apperror.CodeNotFound, "department not found")
+	s.invalidateTrees(ctx, stale)
+
+	return s.employeeView(ctx)(employee), nil
+}
+
+// MoveEmployees moves the employees into the department, all or none.
+func (s *DepartmentService) MoveEmployees(ctx context.Context, departmentID uint, employeeIDs []uint) error {
+	var stale []string
+	err := s.store.WithinTransaction(ctx, func(tx repository.Repositories) error {
+		if err := ensureDepartmentExists(ctx, tx, departmentID); err != nil {
+			return err
+		}
+		if err := authorize(ctx, tx, auth.RoleEditor, &departmentID); err != nil {
+			return err
+		}
+		var err error
+		stale, err = s.moveEmployees(ctx, tx, departmentID, employeeIDs)
+		return err
+	})
+	if err != nil {
+		return err
 	}
+	s.invalidateTrees(ctx, stale)
 	return nil
 }
 
-func (s *DepartmentService) siblingNameExists(ctx context.Context, parentID *uint, name string, excludeID *uint) (bool, error) {
-	query := s.db.WithContext(ctx).Model(&models.Department{}).Where("LOWER(name) = LOWER(?)", name)
-	if parentID == nil {
-		query = query.Where("parent_id IS NULL")
-	} else {
-		query = query.Where("parent_id = ?", *parentID)
+// moveEmployees moves the employees into the department, which the caller
+// must already be allowed to edit. It returns the stale cached trees.
+func (s *DepartmentService) moveEmployees(ctx context.Context, tx repository.Repositories, departmentID uint, employeeIDs []uint) ([]string, error) {
+	var stale []string
+	for _, employeeID := range employeeIDs {
+		employee, err := loadEmployee(ctx, tx, employeeID)
+		if err != nil {
+			return nil, err
+		}
+		if err := authorize(ctx, tx, auth.RoleEditor, &employee.DepartmentID); err != nil {
+			return nil, err
+		}
+		_, moved, err := s.changeEmployee(ctx, tx, employee, repository.EmployeeChanges{DepartmentID: &departmentID})
+		if err != nil {
+			return nil, err
+		}
+		stale = append(stale, moved...)
+	}
+	return stale, nil
+}
+
+// changeEmployee applies the changes that differ from the loaded employee and
+// records them. It returns the updated employee and the stale cached trees.
+func (s *DepartmentService) changeEmployee(ctx context.Context, tx repository.Repositories, employee models.Employee, changes repository.EmployeeChanges) (models.Employee, []string, error) {
+	if changes.FullName != nil && *changes.FullName == employee.FullName {
+		changes.FullName = nil
+	}
+	if changes.Position != nil && *changes.Position == employee.Position {
+		changes.Position = nil
+	}
+	if changes.DepartmentID != nil && *changes.DepartmentID == employee.DepartmentID {
+		changes.DepartmentID = nil
 	}
-	if excludeID != nil {
-		query = query.Where("id <> ?", *excludeID)
+	if changes.FullName == nil && changes.Position == nil && changes.DepartmentID == nil {
+		return employee, nil, nil
 	}
 
-	var count int64
-	if err := query.Count(&count).Error; err != nil {
-		return false, fmt.Errorf("check sibling uniqueness: %w", err)
+	touched := []uint{employee.DepartmentID}
+	if changes.DepartmentID != nil {
+		touched = append(touched, *changes.DepartmentID)
+	}
+	stale, err := s.staleTrees(ctx, tx, touched...)
+	if err != nil {
+		return models.Employee{}, nil, err
+	}
+	updated, err := tx.Employees().Update(ctx, employee.ID, employee.Version, changes)
+	if err != nil {
+		return models.Employee{}, nil, err
+	}
+	if !updated {
+		return models.Employee{}, nil, errEmployeeModified
+	}
+	if err := recordAudit(ctx, tx, "employee.update", "employee", employee.ID); err != nil {
+		return models.Employee{}, nil, err
 	}
-	return count > 0, nil
+	if err := tx.Departments().BumpVersions(ctx, touched...); err != nil {
+		return models.Employee{}, nil, err
+	}
+
+	previous := employee
+	employee, err = tx.Employees().Get(ctx, employee.ID)
+	if err != nil {
+		return models.Employee{}, nil, err
+	}
+	scope, err := eventScope(ctx, tx, touched...)
+	if err != nil {
+		return models.Employee{}, nil, err
+	}
+	eventType := EventEmployeeUpdated
+	event := EmployeeEvent{Employee: employeeToDTO(employee)}
+	if changes.DepartmentID != nil {
+		eventType = EventEmployeeMoved
+		event.PreviousDepartmentID = previous.DepartmentID
+	}
+	if err := recordEmployeeEvent(ctx, tx, eventType, event, scope); err != nil {
+		return models.Employee{}, nil, err
+	}
+	return employee, stale, nil
 }
 
-func (s *DepartmentService) wouldCreateCycle(ctx context.Context, departmentID uint, newParentID uint) (bool, error) {
-	currentID := &newParentID
-	for currentID != nil {
-		if *currentID == departmentID {
-			return true, nil
+// buildTree assembles the nested response from a flat subtree. Descendants and
+// employees are expected to be sorted by name already.
+func buildTree(root models.Department, descendants []models.Department, employees []models.Employee, includeEmployees bool, toDTO func(models.Employee) EmployeeDTO) DepartmentTree {
+	childrenByParent := make(map[uint][]models.Department)
+	for _, descendant := range descendants {
+		if descendant.ParentID != nil {
+			childrenByParent[*descendant.ParentID] = append(childrenByParent[*descendant.ParentID], descendant)
 		}
+	}
 
-		var department models.Department
-		if err := s.db.WithContext(ctx).
-			Select("id", "parent_id").
-			First(&department, *currentID).Error; err != nil {
-			if errors.Is(err, gorm.ErrRecordNotFound) {
-				return false, nil
+	employeesByDepartment := make(map[uint][]EmployeeDTO)
+	for _, employee := range employees {
+		employeesByDepartment[employee.DepartmentID] = append(employeesByDepartment[employee.DepartmentID], toDTO(employee))
+	}
+
+	var build func(department models.Department) DepartmentTree
+	build = func(department models.Department) DepartmentTree {
+		result := DepartmentTree{
+			Department: departmentToDTO(department),
+			Children:   []DepartmentTree{},
+		}
+		if includeEmployees {
+			employeesDTO := employeesByDepartment[department.ID]
+			if employeesDTO == nil {
+				employeesDTO = []EmployeeDTO{}
 			}
-			return false, fmt.Errorf("load parent chain: %w", err)
+			result.Employees = &employeesDTO
 		}
-		currentID = department.ParentID
+		for _, child := range childrenByParent[department.ID] {
+			result.Children = append(result.Children, build(child))
+		}
+		return result
 	}
 
-	return false, nil
+	return build(root)
+}
+
+// recordAudit stores who performed a mutation in the same transaction as the
+// mutation itself.
+func recordAudit(ctx context.Context, tx repository.Repositories, action string, entityType string, entityID uint) error {
+	return tx.Audit().Record(ctx, &models.AuditEntry{
+		Actor:      actorFromContext(ctx),
+		Action:     action,
+		EntityType: entityType,
+		EntityID:   entityID,
+	})
+}
+
+func actorFromContext(ctx context.Context) string {
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		return principal.String()
+	}
+	return "system"
+}
+
+func loadDepartment(ctx context.Context, repos repository.Repositories, departmentID uint) (models.Department, error) {
+	department, err := repos.Departments().Get(ctx, departmentID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Department{}, errDepartmentNotFound
+	}
+	return department, err
+}
+
+func loadEmployee(ctx context.Context, repos repository.Repositories, employeeID uint) (models.Employee, error) {
+	employee, err := repos.Employees().Get(ctx, employeeID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.Employee{}, errEmployeeNotFound
+	}
+	return employee, err
+}
+
+func ensureDepartmentExists(ctx context.Context, repos repository.Repositories, departmentID uint) error {
+	exists, err := repos.Departments().Exists(ctx, departmentID)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return errDepartmentNotFound
+	}
+	return nil
+}
+
+func wouldCreateCycle(ctx context.Context, repos repository.Repositories, departmentID uint, newParentID uint) (bool, error) {
+	return repos.Departments().IsAncestor(ctx, departmentID, newParentID)
 }
 
 func departmentToDTO(department models.Department) DepartmentDTO {
@@ -328,6 +1068,7 @@ func departmentToDTO(department models.Department) DepartmentDTO {
 		ID:        department.ID,
 		Name:      department.Name,
 		ParentID:  department.ParentID,
+		Version:   department.Version,
 		CreatedAt: department.CreatedAt,
 	}
 }
@@ -345,15 +1086,17 @@ func employeeToDTO(employee models.Employee) EmployeeDTO {
 		FullName:     employee.FullName,
 		Position:     employee.Position,
 		HiredAt:      hiredAt,
+		Version:      employee.Version,
+		ErasedAt:     employee.ErasedAt,
 		CreatedAt:    employee.CreatedAt,
 	}
 }
 
-func normalizeRequiredString(raw string, field string) (string, error) {
+func (s *DepartmentService) normalizeRequiredString(raw string, field string) (string, error) {
 	value := strings.TrimSpace(raw)
 	length := utf8.RuneCountInString(value)
-	if length < 1 || length > 200 {
-		return "", apperror.New(apperror.CodeValidation, fmt.Sprintf("%s length must be in range 1..200", field))
+	if length < 1 || length > s.maxFieldLength {
+		return "", apperror.New(apperror.CodeValidation, fmt.Sprintf("%s length must be in range 1..%d", field, s.maxFieldLength))
 	}
 	return value, nil
 }
@@ -364,16 +1107,3 @@ func equalUintPtr(a *uint, b *uint) bool {
 	}
 	return *a == *b
 }
-
-func mapDatabaseError(err error) error {
-	var pgErr *pgconn.PgError
-	if errors.As(err, &pgErr) {
-		if pgErr.Code == "23505" {
-			return apperror.New(apperror.CodeConflict, "resource with the same unique attributes already exists")
-		}
-		if pgErr.Code == "23503" {
-			return apperror.New(apperror.CodeValidation, "invalid foreign key reference")
-		}
-	}
-	return err
-}
@@ -0,0 +1,667 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"hitalent-go-task/internal/apperror"
+	"hitalent-go-task/internal/auth"
+	"hitalent-go-task/internal/cache"
+	"hitalent-go-task/internal/repository/gormrepo"
+	"hitalent-go-task/internal/repository/memory"
+)
+
+// forEachStore runs the scenario against every store that works without external services.
+func forEachStore(t *testing.T, scenario func(t *testing.T, svc *DepartmentService)) {
+	t.Run("memory", func(t *testing.T) {
+		scenario(t, NewDepartmentService(memory.NewStore()))
+	})
+	t.Run("sqlite", func(t *testing.T) {
+		scenario(t, NewDepartmentService(gormrepo.New(openSQLiteTestDatabase(t))))
+	})
+}
+
+func mustCreateDepartment(t *testing.T, svc *DepartmentService, name string, parentID *uint) DepartmentDTO {
+	t.Helper()
+
+	department, err := svc.CreateDepartment(context.Background(), CreateDepartmentInput{Name: name, ParentID: parentID})
+	if err != nil {
+		t.Fatalf("create department %q: %v", name, err)
+	}
+	return department
+}
+
+func assertCode(t *testing.T, err error, code apperror.Code) {
+	t.Helper()
+
+	if got := apperror.GetCode(err); got != code {
+		t.Fatalf("expected error code %q, got %q (%v)", code, got, err)
+	}
+}
+
+func TestCreateDepartmentRejectsDuplicateSiblingName(t *testing.T) {
+	forEachStore(t, func(t *testing.T, svc *DepartmentService) {
+		root := mustCreateDepartment(t, svc, "Engineering", nil)
+		mustCreateDepartment(t, svc, "Backend", &root.ID)
+
+		_, err := svc.CreateDepartment(context.Background(), CreateDepartmentInput{Name: "  backend ", ParentID: &root.ID})
+		assertCode(t, err, apperror.CodeConflict)
+
+		other := mustCreateDepartment(t, svc, "Sales", nil)
+		mustCreateDepartment(t, svc, "Backend", &other.ID)
+
+		mustCreateDepartment(t, svc, "Разработка", &root.ID)
+		_, err = svc.CreateDepartment(context.Background(), CreateDepartmentInput{Name: "РАЗРАБОТКА", ParentID: &root.ID})
+		assertCode(t, err, apperror.CodeConflict)
+	})
+}
+
+func TestCreateDepartmentValidatesInput(t *testing.T) {
+	forEachStore(t, func(t *testing.T, svc *DepartmentService) {
+		_, err := svc.CreateDepartment(context.Background(), CreateDepartmentInput{Name: "   "})
+		assertCode(t, err, apperror.CodeValidation)
+
+		missingParent := uint(42)
+		_, err = svc.CreateDepartment(context.Background(), CreateDepartmentInput{Name: "Backend", ParentID: &missingParent})
+		assertCode(t, err, apperror.CodeNotFound)
+	})
+}
+
+func TestUpdateDepartmentDetectsCycles(t *testing.T) {
+	forEachStore(t, func(t *testing.T, svc *DepartmentService) {
+		root := mustCreateDepartment(t, svc, "Engineering", nil)
+		child := mustCreateDepartment(t, svc, "Backend", &root.ID)
+		grandchild := mustCreateDepartment(t, svc, "Payments", &child.ID)
+		ctx := context.Background()
+
+		_, err := svc.UpdateDepartment(ctx, root.ID, UpdateDepartmentInput{ParentIDSet: true, ParentID: &grandchild.ID})
+		assertCode(t, err, apperror.CodeConflict)
+
+		_, err = svc.UpdateDepartment(ctx, root.ID, UpdateDepartmentInput{ParentIDSet: true, ParentID: &root.ID})
+		assertCode(t, err, apperror.CodeValidation)
+
+		moved, err := svc.UpdateDepartment(ctx, grandchild.ID, UpdateDepartmentInput{ParentIDSet: true, ParentID: &root.ID})
+		if err != nil {
+			t.Fatalf("move department: %v", err)
+		}
+		if moved.ParentID == nil || *moved.ParentID != root.ID {
+			t.Fatalf("expected parent %d, got %v", root.ID, moved.ParentID)
+		}
+	})
+}
+
+func TestUpdateDepartmentChecksSiblingNameOnMove(t *testing.T) {
+	forEachStore(t, func(t *testing.T, svc *DepartmentService) {
+		root := mustCreateDepartment(t, svc, "Engineering", nil)
+		mustCreateDepartment(t, svc, "QA", &root.ID)
+		other := mustCreateDepartment(t, svc, "Product", nil)
+		qa := mustCreateDepartment(t, svc, "qa", &other.ID)
+
+		_, err := svc.UpdateDepartment(context.Background(), qa.ID, UpdateDepartmentInput{ParentIDSet: true, ParentID: &root.ID})
+		assertCode(t, err, apperror.CodeConflict)
+	})
+}
+
+func TestUpdateDepartmentVersions(t *testing.T) {
+	forEachStore(t, func(t *testing.T, svc *DepartmentService) {
+		ctx := context.Background()
+		root := mustCreateDepartment(t, svc, "Engineering", nil)
+		child := mustCreateDepartment(t, svc, "Backend", &root.ID)
+
+		tree, err := svc.GetDepartment(ctx, root.ID, GetDepartmentOptions{Depth: 1})
+		if err != nil {
+			t.Fatalf("get department: %v", err)
+		}
+		rootVersion := tree.Department.Version
+
+		name := "Platform"
+		stale := child.Version
+		updated, err := svc.UpdateDepartment(ctx, child.ID, UpdateDepartmentInput{Name: &name, ExpectedVersion: &stale})
+		if err != nil {
+			t.Fatalf("update department: %v", err)
+		}
+		if updated.Version != stale+1 {
+			t.Fatalf("expected version %d, got %d", stale+1, updated.Version)
+		}
+
+		_, err = svc.UpdateDepartment(ctx, child.ID, UpdateDepartmentInput{Name: &name, ExpectedVersion: &stale})
+		assertCode(t, err, apperror.CodePreconditionFailed)
+
+		tree, err = svc.GetDepartment(ctx, root.ID, GetDepartmentOptions{Depth: 1})
+		if err != nil {
+			t.Fatalf("get department: %v", err)
+		}
+		if tree.Department.Version <= rootVersion {
+			t.Fatalf("expected parent version to grow after a child change, got %d (was %d)", tree.Department.Version, rootVersion)
+		}
+
+		// Deeper changes leave the root alone.
+		rootVersion = tree.Department.Version
+		grandchild := mustCreateDepartment(t, svc, "Go", &child.ID)
+		if _, err := svc.CreateEmployee(ctx, grandchild.ID, CreateEmployeeInput{FullName: "Ivan Petrov", Position: "Engineer"}); err != nil {
+			t.Fatalf("create employee: %v", err)
+		}
+		tree, err = svc.GetDepartment(ctx, root.ID, GetDepartmentOptions{Depth: DepthAll})
+		if err != nil {
+			t.Fatalf("get department: %v", err)
+		}
+		if tree.Department.Version != rootVersion {
+			t.Fatalf("expected root version %d to stay, got %d", rootVersion, tree.Department.Version)
+		}
+		if len(tree.Children) != 1 || tree.Children[0].Department.Version != updated.Version+1 {
+			t.Fatalf("expected the parent of the new department to be bumped, got %+v", tree.Children)
+		}
+	})
+}
+
+func TestDeleteDepartmentReassign(t *testing.T) {
+	forEachStore(t, func(t *testing.T, svc *DepartmentService) {
+		ctx := context.Background()
+		root := mustCreateDepartment(t, svc, "Engineering", nil)
+		backend := mustCreateDepartment(t, svc, "Backend", &root.ID)
+		payments := mustCreateDepartment(t, svc, "Payments", &backend.ID)
+		target := mustCreateDepartment(t, svc, "Frontend", &root.ID)
+
+		if _, err := svc.CreateEmployee(ctx, backend.ID, CreateEmployeeInput{FullName: "Ivan Petrov", Position: "Developer"}); err != nil {
+			t.Fatalf("create employee: %v", err)
+		}
+
+		if err := svc.DeleteDepartment(ctx, backend.ID, DeleteModeReassign, &target.ID); err != nil {
+			t.Fatalf("delete department: %v", err)
+		}
+
+		_, err := svc.GetDepartment(ctx, backend.ID, GetDepartmentOptions{})
+		assertCode(t, err, apperror.CodeNotFound)
+
+		tree, err := svc.GetDepartment(ctx, target.ID, GetDepartmentOptions{IncludeEmployees: true})
+		if err != nil {
+			t.Fatalf("get target department: %v", err)
+		}
+		if tree.Employees == nil || len(*tree.Employees) != 1 || (*tree.Employees)[0].FullName != "Ivan Petrov" {
+			t.Fatalf("expected employee to be reassigned, got %+v", tree.Employees)
+		}
+
+		rootTree, err := svc.GetDepartment(ctx, root.ID, GetDepartmentOptions{Depth: 1})
+		if err != nil {
+			t.Fatalf("get root department: %v", err)
+		}
+		var found bool
+		for _, child := range rootTree.Children {
+			if child.Department.ID == payments.ID {
+				found = true
+			}
+		}
+		if !found {
+			t.Fatalf("expected child department to move to the deleted department's parent")
+		}
+	})
+}
+
+func TestDeleteDepartmentCascade(t *testing.T) {
+	forEachStore(t, func(t *testing.T, svc *DepartmentService) {
+		ctx := context.Background()
+		root := mustCreateDepartment(t, svc, "Engineering", nil)
+		backend := mustCreateDepartment(t, svc, "Backend", &root.ID)
+		payments := mustCreateDepartment(t, svc, "Payments", &backend.ID)
+
+		if err := svc.DeleteDepartment(ctx, backend.ID, DeleteModeCascade, nil); err != nil {
+			t.Fatalf("delete department: %v", err)
+		}
+
+		_, err := svc.GetDepartment(ctx, payments.ID, GetDepartmentOptions{})
+		assertCode(t, err, apperror.CodeNotFound)
+
+		err = svc.DeleteDepartment(ctx, root.ID, DeleteModeReassign, &root.ID)
+		assertCode(t, err, apperror.CodeValidation)
+	})
+}
+
+func TestClosureTableStaysConsistent(t *testing.T) {
+	store := gormrepo.New(openSQLiteTestDatabase(t))
+	svc := NewDepartmentService(store)
+	ctx := context.Background()
+
+	root := mustCreateDepartment(t, svc, "Engineering", nil)
+	backend := mustCreateDepartment(t, svc, "Backend", &root.ID)
+	payments := mustCreateDepartment(t, svc, "Payments", &backend.ID)
+	mustCreateDepartment(t, svc, "Billing", &payments.ID)
+	frontend := mustCreateDepartment(t, svc, "Frontend", &root.ID)
+
+	if _, err := svc.UpdateDepartment(ctx, payments.ID, UpdateDepartmentInput{ParentIDSet: true, ParentID: &frontend.ID}); err != nil {
+		t.Fatalf("move department: %v", err)
+	}
+	if _, err := svc.UpdateDepartment(ctx, frontend.ID, UpdateDepartmentInput{ParentIDSet: true}); err != nil {
+		t.Fatalf("detach department: %v", err)
+	}
+	if err := svc.DeleteDepartment(ctx, frontend.ID, DeleteModeReassign, &backend.ID); err != nil {
+		t.Fatalf("delete department: %v", err)
+	}
+
+	report, err := store.CheckHierarchy(ctx)
+	if err != nil {
+		t.Fatalf("check hierarchy: %v", err)
+	}
+	if !report.Consistent() {
+		t.Fatalf("expected consistent closure table, got %+v", report)
+	}
+
+	// Frontend was a root, so its children become roots after deletion.
+	tree, err := svc.GetDepartment(ctx, payments.ID, GetDepartmentOptions{Depth: 5})
+	if err != nil {
+		t.Fatalf("get department: %v", err)
+	}
+	if tree.Department.ParentID != nil || len(tree.Children) != 1 || tree.Children[0].Department.Name != "Billing" {
+		t.Fatalf("expected root Payments > Billing, got %+v", tree)
+	}
+}
+
+func TestGetDepartmentDepthLimit(t *testing.T) {
+	ctx := context.Background()
+	svc := NewDepartmentService(memory.NewStore(), WithMaxDepth(2))
+	root := mustCreateDepartment(t, svc, "Engineering", nil)
+	parentID := root.ID
+	var chain []uint
+	for _, name := range []string{"Backend", "Payments", "Billing"} {
+		parentID = mustCreateDepartment(t, svc, name, &parentID).ID
+		chain = append(chain, parentID)
+	}
+
+	_, err := svc.GetDepartment(ctx, root.ID, GetDepartmentOptions{Depth: 3})
+	assertCode(t, err, apperror.CodeValidation)
+
+	// depth=all is refused rather than cut at the maximum.
+	_, err = svc.GetDepartment(ctx, root.ID, GetDepartmentOptions{Depth: DepthAll})
+	assertCode(t, err, apperror.CodeValidation)
+	err = svc.StreamDepartment(ctx, root.ID, GetDepartmentOptions{Depth: DepthAll}, func(DepartmentNode) error {
+		t.Fatalf("expected no nodes before the error")
+		return nil
+	})
+	assertCode(t, err, apperror.CodeValidation)
+
+	tree, err := svc.GetDepartment(ctx, chain[0], GetDepartmentOptions{Depth: DepthAll})
+	if err != nil {
+		t.Fatalf("get department: %v", err)
+	}
+	if len(tree.Children) != 1 || len(tree.Children[0].Children) != 1 || len(tree.Children[0].Children[0].Children) != 0 {
+		t.Fatalf("expected the whole subtree within the maximum, got %+v", tree)
+	}
+}
+
+func TestStreamDepartment(t *testing.T) {
+	forEachStore(t, func(t *testing.T, svc *DepartmentService) {
+		ctx := context.Background()
+		svc.maxDepth = -1
+		svc.batchSize = 2
+
+		root := mustCreateDepartment(t, svc, "Engineering", nil)
+		backend := mustCreateDepartment(t, svc, "Backend", &root.ID)
+		frontend := mustCreateDepartment(t, svc, "Frontend", &root.ID)
+		parentID := backend.ID
+		for _, name := range []string{"Payments", "Billing", "Invoices", "Taxes", "Reports", "Exports"} {
+			parentID = mustCreateDepartment(t, svc, name, &parentID).ID
+		}
+		if _, err := svc.CreateEmployee(ctx, frontend.ID, CreateEmployeeInput{FullName: "Anna Smirnova", Position: "Designer"}); err != nil {
+			t.Fatalf("create employee: %v", err)
+		}
+
+		seen := make(map[uint]bool)
+		var nodes []DepartmentNode
+		err := svc.StreamDepartment(ctx, root.ID, GetDepartmentOptions{Depth: DepthAll, IncludeEmployees: true}, func(node DepartmentNode) error {
+			if node.Depth > 0 && (node.Department.ParentID == nil || !seen[*node.Department.ParentID]) {
+				t.Fatalf("node %q arrived before its parent", node.Department.Name)
+			}
+			seen[node.Department.ID] = true
+			nodes = append(nodes, node)
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("stream department: %v", err)
+		}
+
+		if len(nodes) != 9 {
+			t.Fatalf("expected 9 nodes, got %d", len(nodes))
+		}
+		if nodes[0].Department.ID != root.ID || nodes[len(nodes)-1].Depth != 7 {
+			t.Fatalf("unexpected order: first %+v, last %+v", nodes[0], nodes[len(nodes)-1])
+		}
+		for _, node := range nodes {
+			if node.Department.ID == frontend.ID && (node.Employees == nil || len(*node.Employees) != 1) {
+				t.Fatalf("expected frontend employee in the stream, got %+v", node.Employees)
+			}
+		}
+	})
+}
+
+func TestMutationsAreAudited(t *testing.T) {
+	store := memory.NewStore()
+	svc := NewDepartmentService(store)
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{Subject: "alice", Method: auth.MethodJWT, Role: auth.RoleEditor})
+
+	department, err := svc.CreateDepartment(ctx, CreateDepartmentInput{Name: "Engineering"})
+	if err != nil {
+		t.Fatalf("create department: %v", err)
+	}
+	if err := svc.DeleteDepartment(context.Background(), department.ID, DeleteModeCascade, nil); err != nil {
+		t.Fatalf("delete department: %v", err)
+	}
+
+	entries := store.AuditEntries()
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 audit entries, got %d", len(entries))
+	}
+	if entries[0].Actor != "jwt:alice" || entries[0].Action != "department.create" || entries[0].EntityID != department.ID {
+		t.Fatalf("unexpected create entry %+v", entries[0])
+	}
+	if entries[1].Actor != "system" || entries[1].Action != "department.delete.cascade" {
+		t.Fatalf("unexpected delete entry %+v", entries[1])
+	}
+}
+
+func TestMutationsWriteOutboxEvents(t *testing.T) {
+	store := memory.NewStore()
+	svc := NewDepartmentService(store)
+	ctx := context.Background()
+
+	root := mustCreateDepartment(t, svc, "Engineering", nil)
+	backend := mustCreateDepartment(t, svc, "Backend", &root.ID)
+	payments := mustCreateDepartment(t, svc, "Payments", &backend.ID)
+	employee, err := svc.CreateEmployee(ctx, payments.ID, CreateEmployeeInput{FullName: "Ivan Petrov", Position: "Developer"})
+	if err != nil {
+		t.Fatalf("create employee: %v", err)
+	}
+	renamed := "Platform"
+	if _, err := svc.UpdateDepartment(ctx, backend.ID, UpdateDepartmentInput{Name: &renamed, ParentIDSet: true}); err != nil {
+		t.Fatalf("update department: %v", err)
+	}
+	if _, err := svc.EraseEmployee(ctx, payments.ID, employee.ID); err != nil {
+		t.Fatalf("erase employee: %v", err)
+	}
+	if err := svc.DeleteDepartment(ctx, backend.ID, DeleteModeCascade, nil); err != nil {
+		t.Fatalf("delete department: %v", err)
+	}
+
+	var types []string
+	for _, event := range store.OutboxEvents() {
+		types = append(types, fmt.Sprintf("%s %d", event.EventType, event.EntityID))
+		if event.EntityType == entityEmployee && strings.Contains(event.Payload, "Ivan Petrov") {
+			t.Fatalf("erased employee data left in %s event: %s", event.EventType, event.Payload)
+		}
+	}
+	want := []string{
+		fmt.Sprintf("%s %d", EventDepartmentCreated, root.ID),
+		fmt.Sprintf("%s %d", EventDepartmentCreated, backend.ID),
+		fmt.Sprintf("%s %d", EventDepartmentCreated, payments.ID),
+		fmt.Sprintf("%s %d", EventEmployeeCreated, employee.ID),
+		fmt.Sprintf("%s %d", EventDepartmentRenamed, backend.ID),
+		fmt.Sprintf("%s %d", EventDepartmentMoved, backend.ID),
+		fmt.Sprintf("%s %d", EventEmployeeErased, employee.ID),
+		fmt.Sprintf("%s %d", EventEmployeeDeleted, employee.ID),
+		fmt.Sprintf("%s %d", EventDepartmentDeleted, payments.ID),
+		fmt.Sprintf("%s %d", EventDepartmentDeleted, backend.ID),
+	}
+	if strings.Join(types, ", ") != strings.Join(want, ", ") {
+		t.Fatalf("unexpected events:\n got %v\nwant %v", types, want)
+	}
+
+	// Scopes hold the ancestry at the time of the event, both positions of a
+	// move and the former position of removed rows.
+	scopes := make(map[string]string)
+	for _, event := range store.OutboxEvents() {
+		scopes[fmt.Sprintf("%s %d", event.EventType, event.EntityID)] = event.Scope
+	}
+	wantScopes := map[string]string{
+		fmt.Sprintf("%s %d", EventDepartmentCreated, payments.ID): fmt.Sprintf(",%d,%d,%d,", payments.ID, backend.ID, root.ID),
+		fmt.Sprintf("%s %d", EventDepartmentMoved, backend.ID):    fmt.Sprintf(",%d,%d,", backend.ID, root.ID),
+		fmt.Sprintf("%s %d", EventEmployeeDeleted, employee.ID):   fmt.Sprintf(",%d,%d,", payments.ID, backend.ID),
+		fmt.Sprintf("%s %d", EventDepartmentDeleted, payments.ID): fmt.Sprintf(",%d,%d,", payments.ID, backend.ID),
+	}
+	for event, want := range wantScopes {
+		if scopes[event] != want {
+			t.Fatalf("expected scope %s for %s, got %q", want, event, scopes[event])
+		}
+	}
+}
+
+func TestAuthorization(t *testing.T) {
+	forEachStore(t, func(t *testing.T, svc *DepartmentService) {
+		ctx := context.Background()
+		root := mustCreateDepartment(t, svc, "Company", nil)
+		engineering := mustCreateDepartment(t, svc, "Engineering", &root.ID)
+		backend := mustCreateDepartment(t, svc, "Backend", &engineering.ID)
+		payments := mustCreateDepartment(t, svc, "Payments", &backend.ID)
+		sales := mustCreateDepartment(t, svc, "Sales", &root.ID)
+
+		as := func(role auth.Role, scope *uint) context.Context {
+			return auth.WithPrincipal(ctx, auth.Principal{Subject: "user", Method: auth.MethodJWT, Role: role, ScopeDepartmentID: scope})
+		}
+		viewer := as(auth.RoleViewer, &engineering.ID)
+		manager := as(auth.RoleEditor, &engineering.ID)
+		scopedAdmin := as(auth.RoleAdmin, &engineering.ID)
+
+		if _, err := svc.GetDepartment(viewer, backend.ID, GetDepartmentOptions{}); err != nil {
+			t.Fatalf("viewer reads own subtree: %v", err)
+		}
+		_, err := svc.GetDepartment(viewer, sales.ID, GetDepartmentOptions{})
+		assertCode(t, err, apperror.CodeForbidden)
+		_, err = svc.CreateDepartment(viewer, CreateDepartmentInput{Name: "QA", ParentID: &engineering.ID})
+		assertCode(t, err, apperror.CodeForbidden)
+
+		if _, err := svc.CreateDepartment(manager, CreateDepartmentInput{Name: "QA", ParentID: &engineering.ID}); err != nil {
+			t.Fatalf("manager creates inside scope: %v", err)
+		}
+		_, err = svc.CreateDepartment(manager, CreateDepartmentInput{Name: "Marketing", ParentID: &root.ID})
+		assertCode(t, err, apperror.CodeForbidden)
+		_, err = svc.CreateEmployee(manager, sales.ID, CreateEmployeeInput{FullName: "Ivan Petrov", Position: "Manager"})
+		assertCode(t, err, apperror.CodeForbidden)
+
+		if _, err := svc.UpdateDepartment(manager, payments.ID, UpdateDepartmentInput{ParentIDSet: true, ParentID: &engineering.ID}); err != nil {
+			t.Fatalf("manager moves within scope: %v", err)
+		}
+		_, err = svc.UpdateDepartment(manager, payments.ID, UpdateDepartmentInput{ParentIDSet: true, ParentID: &sales.ID})
+		assertCode(t, err, apperror.CodeForbidden)
+		_, err = svc.UpdateDepartment(manager, sales.ID, UpdateDepartmentInput{ParentIDSet: true, ParentID: &engineering.ID})
+		assertCode(t, err, apperror.CodeForbidden)
+		_, err = svc.UpdateDepartment(manager, engineering.ID, UpdateDepartmentInput{ParentIDSet: true, ParentID: nil})
+		assertCode(t, err, apperror.CodeForbidden)
+
+		err = svc.DeleteDepartment(manager, payments.ID, DeleteModeCascade, nil)
+		assertCode(t, err, apperror.CodeForbidden)
+		err = svc.DeleteDepartment(scopedAdmin, engineering.ID, DeleteModeCascade, nil)
+		assertCode(t, err, apperror.CodeForbidden)
+		err = svc.DeleteDepartment(scopedAdmin, backend.ID, DeleteModeReassign, &sales.ID)
+		assertCode(t, err, apperror.CodeForbidden)
+		if err := svc.DeleteDepartment(scopedAdmin, payments.ID, DeleteModeCascade, nil); err != nil {
+			t.Fatalf("scoped admin deletes inside scope: %v", err)
+		}
+	})
+}
+
+func TestEmployeeFieldPolicy(t *testing.T) {
+	ctx := context.Background()
+	svc := NewDepartmentService(memory.NewStore())
+	root := mustCreateDepartment(t, svc, "Engineering", nil)
+	hiredAt := time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)
+	if _, err := svc.CreateEmployee(ctx, root.ID, CreateEmployeeInput{FullName: "Ivan Petrov", Position: "Developer", HiredAt: &hiredAt}); err != nil {
+		t.Fatalf("create employee: %v", err)
+	}
+
+	employeeAs := func(role auth.Role) EmployeeDTO {
+		t.Helper()
+		tree, err := svc.GetDepartment(auth.WithPrincipal(ctx, auth.Principal{Subject: "user", Role: role}), root.ID, GetDepartmentOptions{IncludeEmployees: true})
+		if err != nil {
+			t.Fatalf("get department: %v", err)
+		}
+		return (*tree.Employees)[0]
+	}
+
+	if viewer := employeeAs(auth.RoleViewer); viewer.HiredAt != nil || viewer.FullName != "Ivan Petrov" {
+		t.Fatalf("expected viewer to see name without hire date, got %+v", viewer)
+	}
+	if editor := employeeAs(auth.RoleEditor); editor.HiredAt == nil {
+		t.Fatalf("expected editor to see hire date, got %+v", editor)
+	}
+
+	policy, err := ParseEmployeeFieldPolicy("viewer=position; editor=full_name,position")
+	if err != nil {
+		t.Fatalf("parse policy: %v", err)
+	}
+	WithEmployeeFieldPolicy(policy)(svc)
+	if viewer := employeeAs(auth.RoleViewer); viewer.FullName != "" || viewer.Position != "Developer" {
+		t.Fatalf("expected viewer to see only position, got %+v", viewer)
+	}
+	if editor := employeeAs(auth.RoleEditor); editor.HiredAt != nil {
+		t.Fatalf("expected editor to lose hire date, got %+v", editor)
+	}
+
+	if _, err := ParseEmployeeFieldPolicy("viewer=salary"); err == nil {
+		t.Fatalf("expected unknown field to be rejected")
+	}
+}
+
+func TestEraseEmployee(t *testing.T) {
+	forEachStore(t, func(t *testing.T, svc *DepartmentService) {
+		ctx := context.Background()
+		root := mustCreateDepartment(t, svc, "Engineering", nil)
+		other := mustCreateDepartment(t, svc, "Sales", nil)
+		hiredAt := time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)
+		employee, err := svc.CreateEmployee(ctx, root.ID, CreateEmployeeInput{FullName: "Ivan Petrov", Position: "Developer", HiredAt: &hiredAt})
+		if err != nil {
+			t.Fatalf("create employee: %v", err)
+		}
+
+		_, err = svc.EraseEmployee(ctx, other.ID, employee.ID)
+		assertCode(t, err, apperror.CodeNotFound)
+		editor := auth.WithPrincipal(ctx, auth.Principal{Subject: "user", Role: auth.RoleEditor})
+		_, err = svc.EraseEmployee(editor, root.ID, employee.ID)
+		assertCode(t, err, apperror.CodeForbidden)
+
+		erased, err := svc.EraseEmployee(ctx, root.ID, employee.ID)
+		if err != nil {
+			t.Fatalf("erase employee: %v", err)
+		}
+		if erased.FullName == "Ivan Petrov" || erased.HiredAt != nil || erased.ErasedAt == nil || erased.Position != "Developer" {
+			t.Fatalf("expected anonymized employee, got %+v", erased)
+		}
+		if _, err := svc.EraseEmployee(ctx, root.ID, employee.ID); err != nil {
+			t.Fatalf("erase employee twice: %v", err)
+		}
+
+		tree, err := svc.GetDepartment(ctx, root.ID, GetDepartmentOptions{IncludeEmployees: true})
+		if err != nil {
+			t.Fatalf("get department: %v", err)
+		}
+		if len(*tree.Employees) != 1 || (*tree.Employees)[0].FullName != erased.FullName {
+			t.Fatalf("expected the erased record to stay in the department, got %+v", tree.Employees)
+		}
+	})
+}
+
+func TestGetDepartmentCache(t *testing.T) {
+	forEachStore(t, func(t *testing.T, svc *DepartmentService) {
+		ctx := context.Background()
+		lru := cache.NewLRU(100)
+		trees := cache.Instrument(lru)
+		WithTreeCache(trees, time.Minute)(svc)
+
+		root := mustCreateDepartment(t, svc, "Engineering", nil)
+		backend := mustCreateDepartment(t, svc, "Backend", &root.ID)
+		sales := mustCreateDepartment(t, svc, "Sales", nil)
+		options := GetDepartmentOptions{Depth: DepthAll, IncludeEmployees: true}
+		get := func(ctx context.Context, id uint) DepartmentTree {
+			t.Helper()
+			tree, err := svc.GetDepartment(ctx, id, options)
+			if err != nil {
+				t.Fatalf("get department %d: %v", id, err)
+			}
+			return tree
+		}
+
+		get(ctx, root.ID)
+		get(ctx, root.ID)
+		get(ctx, sales.ID)
+		get(ctx, backend.ID)
+		if hits, misses := trees.Stats(); hits != 1 || misses != 3 {
+			t.Fatalf("expected 1 hit and 3 misses, got %d and %d", hits, misses)
+		}
+
+		hiredAt := time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)
+		if _, err := svc.CreateEmployee(ctx, backend.ID, CreateEmployeeInput{FullName: "Ivan Petrov", Position: "Developer", HiredAt: &hiredAt}); err != nil {
+			t.Fatalf("create employee: %v", err)
+		}
+		if _, found, _ := lru.Get(ctx, treeCacheKey(sales.ID, defaultMaxDepth, true)); !found {
+			t.Fatalf("unrelated subtree must stay cached")
+		}
+		editor := get(auth.WithPrincipal(ctx, auth.Principal{Subject: "user", Role: auth.RoleEditor}), root.ID)
+		viewer := get(auth.WithPrincipal(ctx, auth.Principal{Subject: "user", Role: auth.RoleViewer}), root.ID)
+		if employees := *editor.Children[0].Employees; len(employees) != 1 || employees[0].HiredAt == nil {
+			t.Fatalf("expected the new employee with hire date, got %+v", employees)
+		}
+		if employees := *viewer.Children[0].Employees; len(employees) != 1 || employees[0].HiredAt != nil {
+			t.Fatalf("a cached tree must still be masked for viewers, got %+v", employees)
+		}
+
+		renamed := "Platform"
+		if _, err := svc.UpdateDepartment(ctx, backend.ID, UpdateDepartmentInput{Name: &renamed}); err != nil {
+			t.Fatalf("rename: %v", err)
+		}
+		if tree := get(ctx, root.ID); tree.Children[0].Department.Name != renamed {
+			t.Fatalf("expected renamed child, got %+v", tree.Children[0].Department)
+		}
+
+		get(ctx, backend.ID)
+		if err := svc.DeleteDepartment(ctx, backend.ID, DeleteModeCascade, nil); err != nil {
+			t.Fatalf("delete: %v", err)
+		}
+		_, err := svc.GetDepartment(ctx, backend.ID, options)
+		assertCode(t, err, apperror.CodeNotFound)
+		if tree := get(ctx, root.ID); len(tree.Children) != 0 {
+			t.Fatalf("expected no children after delete, got %+v", tree.Children)
+		}
+	})
+}
+
+func TestUpdateEmployee(t *testing.T) {
+	forEachStore(t, func(t *testing.T, svc *DepartmentService) {
+		ctx := context.Background()
+		root := mustCreateDepartment(t, svc, "Company", nil)
+		engineering := mustCreateDepartment(t, svc, "Engineering", &root.ID)
+		sales := mustCreateDepartment(t, svc, "Sales", &root.ID)
+		employee, err := svc.CreateEmployee(ctx, engineering.ID, CreateEmployeeInput{FullName: "Ivan Petrov", Position: "Developer"})
+		if err != nil {
+			t.Fatalf("create employee: %v", err)
+		}
+
+		title := " Lead "
+		updated, err := svc.UpdateEmployee(ctx, employee.ID, UpdateEmployeeInput{Position: &title, ExpectedVersion: &employee.Version})
+		if err != nil || updated.Position != "Lead" || updated.Version != employee.Version+1 {
+			t.Fatalf("expected the new position and version, got %+v, %v", updated, err)
+		}
+		_, err = svc.UpdateEmployee(ctx, employee.ID, UpdateEmployeeInput{Position: &title, ExpectedVersion: &employee.Version})
+		assertCode(t, err, apperror.CodePreconditionFailed)
+
+		editor := auth.WithPrincipal(ctx, auth.Principal{Subject: "user", Method: auth.MethodJWT, Role: auth.RoleEditor, ScopeDepartmentID: &engineering.ID})
+		_, err = svc.UpdateEmployee(editor, employee.ID, UpdateEmployeeInput{DepartmentID: &sales.ID})
+		assertCode(t, err, apperror.CodeForbidden)
+
+		// Moves are all or nothing.
+		err = svc.MoveEmployees(ctx, sales.ID, []uint{employee.ID, employee.ID + 100})
+		assertCode(t, err, apperror.CodeNotFound)
+		if err := svc.MoveEmployees(ctx, sales.ID, []uint{employee.ID}); err != nil {
+			t.Fatalf("move employees: %v", err)
+		}
+		tree, err := svc.GetDepartment(ctx, sales.ID, GetDepartmentOptions{IncludeEmployees: true})
+		if err != nil || len(*tree.Employees) != 1 || tree.Department.Version != sales.Version+1 {
+			t.Fatalf("expected the employee in sales with a new version, got %+v, %v", tree, err)
+		}
+
+		if _, err := svc.EraseEmployee(ctx, sales.ID, employee.ID); err != nil {
+			t.Fatalf("erase employee: %v", err)
+		}
+		name := "Ivan Petrov"
+		_, err = svc.UpdateEmployee(ctx, employee.ID, UpdateEmployeeInput{FullName: &name})
+		assertCode(t, err, apperror.CodeConflict)
+	})
+}
@@ -0,0 +1,294 @@
+package service
+
+import (
+	"context"
+	"fmt"
+
+	"hitalent-go-task/internal/apperror"
+	"hitalent-go-task/internal/auth"
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+)
+
+// Text matches are passed to the repository as is.
+type (
+	TextMatch = repository.TextMatch
+	MatchOp   = repository.MatchOp
+)
+
+const (
+	MatchEquals     = repository.MatchEquals
+	MatchContains   = repository.MatchContains
+	MatchStartsWith = repository.MatchStartsWith
+)
+
+// ListEmployeesOptions selects a page of employees ordered by id. All
+// conditions must hold; a nil slice does not filter, an empty one matches
+// nothing.
+type ListEmployeesOptions struct {
+	IDs           []uint
+	DepartmentIDs []uint
+	FullName      []TextMatch
+	Position      []TextMatch
+	// Active selects employees that are not erased, or only erased ones.
+	Active *bool
+	Offset int
+	// Limit 0 only counts the matches.
+	Limit int
+}
+
+type ListDepartmentsOptions struct {
+	IDs    []uint
+	Name   []TextMatch
+	Offset int
+	Limit  int
+}
+
+// Directory is the flat view of the org structure used by provisioning
+// protocols, on top of the tree operations of DepartmentService.
+type Directory interface {
+	ListEmployees(ctx context.Context, options ListEmployeesOptions) ([]EmployeeDTO, int64, error)
+	GetEmployee(ctx context.Context, employeeID uint) (EmployeeDTO, error)
+	UpdateEmployee(ctx context.Context, employeeID uint, input UpdateEmployeeInput) (EmployeeDTO, error)
+	ListDepartments(ctx context.Context, options ListDepartmentsOptions) ([]DepartmentDTO, int64, error)
+	GetDepartment(ctx context.Context, departmentID uint, options GetDepartmentOptions) (DepartmentTree, error)
+	DepartmentMembers(ctx context.Context, departmentIDs []uint) (map[uint][]EmployeeDTO, error)
+	UpdateDepartment(ctx context.Context, departmentID uint, input UpdateDepartmentInput) (DepartmentDTO, error)
+}
+
+// DirectoryService lists departments and employees across the tree. A scoped
+// caller only sees its subtree.
+type DirectoryService struct {
+	*DepartmentService
+}
+
+func NewDirectoryService(departments *DepartmentService) *DirectoryService {
+	return &DirectoryService{DepartmentService: departments}
+}
+
+func (s *DirectoryService) ListEmployees(ctx context.Context, options ListEmployeesOptions) ([]EmployeeDTO, int64, error) {
+	subtree, err := s.directoryScope(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	// Filtering by a hidden field would reveal it.
+	if options.FullName != nil && !s.employeeFieldVisible(ctx, EmployeeFieldFullName) {
+		return nil, 0, hiddenFieldFilter(EmployeeFieldFullName)
+	}
+	if options.Position != nil && !s.employeeFieldVisible(ctx, EmployeeFieldPosition) {
+		return nil, 0, hiddenFieldFilter(EmployeeFieldPosition)
+	}
+
+	filter := repository.EmployeeFilter{
+		SubtreeOf:     subtree,
+		IDs:           options.IDs,
+		DepartmentIDs: options.DepartmentIDs,
+		FullName:      options.FullName,
+		Position:      options.Position,
+		Offset:        options.Offset,
+		Limit:         options.Limit,
+	}
+	if options.Active != nil {
+		erased := !*options.Active
+		filter.Erased = &erased
+	}
+	employees, total, err := s.store.Employees().List(ctx, filter)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	view := s.employeeView(ctx)
+	result := make([]EmployeeDTO, 0, len(employees))
+	for _, employee := range employees {
+		result = append(result, view(employee))
+	}
+	return result, total, nil
+}
+
+func (s *DirectoryService) GetEmployee(ctx context.Context, employeeID uint) (EmployeeDTO, error) {
+	employee, err := loadEmployee(ctx, s.store, employeeID)
+	if err != nil {
+		return EmployeeDTO{}, err
+	}
+	if err := authorize(ctx, s.store, auth.RoleViewer, &employee.DepartmentID); err != nil {
+		return EmployeeDTO{}, err
+	}
+	return s.employeeView(ctx)(employee), nil
+}
+
+func (s *DirectoryService) ListDepartments(ctx context.Context, options ListDepartmentsOptions) ([]DepartmentDTO, int64, error) {
+	subtree, err := s.directoryScope(ctx)
+	if err != nil {
+		return nil, 0, err
+	}
+	departments, total, err := s.store.Departments().List(ctx, repository.DepartmentFilter{
+		SubtreeOf: subtree,
+		IDs:       options.IDs,
+		Name:      options.Name,
+		Offset:    options.Offset,
+		Limit:     options.Limit,
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+
+	result := make([]DepartmentDTO, 0, len(departments))
+	for _, department := range departments {
+		result = append(result, departmentToDTO(department))
+	}
+	return result, total, nil
+}
+
+// DepartmentMembers returns the direct employees of each department, for a
+// whole page of departments at once. Departments outside the caller's scope
+// are left out.
+func (s *DirectoryService) DepartmentMembers(ctx context.Context, departmentIDs []uint) (map[uint][]EmployeeDTO, error) {
+	subtree, err := s.directoryScope(ctx)
+	if err != nil {
+		return nil, err
+	}
+	members := make(map[uint][]EmployeeDTO, len(departmentIDs))
+	if len(departmentIDs) == 0 {
+		return members, nil
+	}
+	if subtree != nil {
+		visible, _, err := s.store.Departments().List(ctx, repository.DepartmentFilter{SubtreeOf: subtree, IDs: departmentIDs, Limit: len(departmentIDs)})
+		if err != nil {
+			return nil, err
+		}
+		departmentIDs = departmentIDs[:0:0]
+		for _, department := range visible {
+			departmentIDs = append(departmentIDs, department.ID)
+		}
+	}
+
+	employees, err := s.store.Employees().ListByDepartments(ctx, departmentIDs)
+	if err != nil {
+		return nil, err
+	}
+	view := s.employeeView(ctx)
+	for _, employee := range employees {
+		members[employee.DepartmentID] = append(members[employee.DepartmentID], view(employee))
+	}
+	return members, nil
+}
+
+// DirectoryNode is a department of a directory export with its position in
+// the tree.
+type DirectoryNode struct {
+	Department DepartmentDTO
+	// Path holds the department names from the top of the tree down to the
+	// department itself. For a scoped caller it starts at the scope
+	// department instead: the departments above it are not theirs to see.
+	Path      []string
+	Employees []EmployeeDTO
+}
+
+type DirectoryExporter interface {
+	// ExportDirectory walks the subtree of departmentID, or every tree the
+	// caller may see when it is nil, parents first.
+	ExportDirectory(ctx context.Context, departmentID *uint, emit func(DirectoryNode) error) error
+}
+
+// ExportDirectory is not limited by the maximum depth of subtree requests:
+// an export always covers the whole subtree. Like StreamDepartment it is not
+// a snapshot.
+func (s *DirectoryService) ExportDirectory(ctx context.Context, departmentID *uint, emit func(DirectoryNode) error) error {
+	if departmentID == nil {
+		scope, err := s.directoryScope(ctx)
+		if err != nil {
+			return err
+		}
+		departmentID = scope
+	} else {
+		if err := ensureDepartmentExists(ctx, s.store, *departmentID); err != nil {
+			return err
+		}
+		if err := authorize(ctx, s.store, auth.RoleViewer, departmentID); err != nil {
+			return err
+		}
+	}
+
+	var top *uint
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		top = principal.ScopeDepartmentID
+	}
+	if departmentID != nil {
+		root, err := loadDepartment(ctx, s.store, *departmentID)
+		if err != nil {
+			return err
+		}
+		return s.exportSubtree(ctx, root, top, emit)
+	}
+	for offset := 0; ; offset += s.batchSize {
+		roots, _, err := s.store.Departments().List(ctx, repository.DepartmentFilter{RootsOnly: true, Offset: offset, Limit: s.batchSize})
+		if err != nil {
+			return err
+		}
+		for _, root := range roots {
+			if err := s.exportSubtree(ctx, root, nil, emit); err != nil {
+				return err
+			}
+		}
+		if len(roots) < s.batchSize {
+			return nil
+		}
+	}
+}
+
+// exportSubtree emits the subtree of root with paths starting at top, an
+// ancestor of root, or at the top of the tree when it is nil.
+func (s *DirectoryService) exportSubtree(ctx context.Context, root models.Department, top *uint, emit func(DirectoryNode) error) error {
+	// The path of the root starts above the subtree.
+	ancestorIDs, err := s.store.Departments().AncestorIDs(ctx, root.ID)
+	if err != nil {
+		return err
+	}
+	if top != nil {
+		for i, ancestorID := range ancestorIDs {
+			if ancestorID == *top {
+				ancestorIDs = ancestorIDs[:i+1]
+				break
+			}
+		}
+	}
+	ancestors, _, err := s.store.Departments().List(ctx, repository.DepartmentFilter{IDs: ancestorIDs, Limit: len(ancestorIDs)})
+	if err != nil {
+		return err
+	}
+	names := make(map[uint]string, len(ancestors))
+	for _, ancestor := range ancestors {
+		names[ancestor.ID] = ancestor.Name
+	}
+	rootPath := make([]string, 0, len(ancestorIDs))
+	for i := len(ancestorIDs) - 1; i >= 0; i-- {
+		rootPath = append(rootPath, names[ancestorIDs[i]])
+	}
+
+	paths := map[uint][]string{root.ID: rootPath}
+	return s.walkSubtree(ctx, root, DepthAll, true, func(node DepartmentNode) error {
+		path, ok := paths[node.Department.ID]
+		if !ok {
+			parentPath := paths[*node.Department.ParentID]
+			path = append(parentPath[:len(parentPath):len(parentPath)], node.Department.Name)
+			paths[node.Department.ID] = path
+		}
+		return emit(DirectoryNode{Department: node.Department, Path: path, Employees: *node.Employees})
+	})
+}
+
+// directoryScope checks read access and returns the subtree the caller may
+// list, nil for the whole tree.
+func (s *DirectoryService) directoryScope(ctx context.Context) (*uint, error) {
+	if err := authorize(ctx, s.store, auth.RoleViewer); err != nil {
+		return nil, err
+	}
+	if principal, ok := auth.PrincipalFromContext(ctx); ok {
+		return principal.ScopeDepartmentID, nil
+	}
+	return nil, nil
+}
+
+func hiddenFieldFilter(field EmployeeField) error {
+	return apperror.New(apperror.CodeForbidden, fmt.Sprintf("%s is hidden from your role and cannot be filtered on", field))
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"hitalent-go-task/internal/apperror"
+	"hitalent-go-task/internal/auth"
+)
+
+func TestDirectoryListsWithinScope(t *testing.T) {
+	forEachStore(t, func(t *testing.T, svc *DepartmentService) {
+		ctx := context.Background()
+		directory := NewDirectoryService(svc)
+		root := mustCreateDepartment(t, svc, "Company", nil)
+		engineering := mustCreateDepartment(t, svc, "Engineering", &root.ID)
+		backend := mustCreateDepartment(t, svc, "Backend", &engineering.ID)
+		sales := mustCreateDepartment(t, svc, "Sales", &root.ID)
+
+		var ids []uint
+		for _, input := range []struct {
+			departmentID uint
+			name         string
+			position     string
+		}{
+			{engineering.ID, "Anna Smirnova", "Lead"},
+			{backend.ID, "Ivan Petrov", "Developer"},
+			{backend.ID, "Пётр Иванов", "Senior Developer"},
+			{sales.ID, "Oleg 100%_Sidorov", "Seller"},
+		} {
+			employee, err := svc.CreateEmployee(ctx, input.departmentID, CreateEmployeeInput{FullName: input.name, Position: input.position})
+			if err != nil {
+				t.Fatalf("create employee: %v", err)
+			}
+			ids = append(ids, employee.ID)
+		}
+		if _, err := svc.EraseEmployee(ctx, backend.ID, ids[1]); err != nil {
+			t.Fatalf("erase employee: %v", err)
+		}
+
+		employees, total, err := directory.ListEmployees(ctx, ListEmployeesOptions{Offset: 1, Limit: 2})
+		if err != nil || total != 4 || len(employees) != 2 || employees[0].ID != ids[1] {
+			t.Fatalf("expected the second page of 4 employees by id, got %d %+v, %v", total, employees, err)
+		}
+		employees, _, err = directory.ListEmployees(ctx, ListEmployeesOptions{FullName: []TextMatch{{Op: MatchStartsWith, Value: "пётр"}}, Limit: 10})
+		if err != nil || len(employees) != 1 || employees[0].ID != ids[2] {
+			t.Fatalf("expected a case-insensitive match, got %+v, %v", employees, err)
+		}
+		employees, _, err = directory.ListEmployees(ctx, ListEmployeesOptions{FullName: []TextMatch{{Op: MatchContains, Value: "100%_"}}, Limit: 10})
+		if err != nil || len(employees) != 1 || employees[0].ID != ids[3] {
+			t.Fatalf("expected wildcards to match literally, got %+v, %v", employees, err)
+		}
+		active := true
+		_, total, err = directory.ListEmployees(ctx, ListEmployeesOptions{DepartmentIDs: []uint{backend.ID}, Active: &active})
+		if err != nil || total != 1 {
+			t.Fatalf("expected one active backend employee, got %d, %v", total, err)
+		}
+
+		// A scoped caller only sees its subtree.
+		viewer := auth.WithPrincipal(ctx, auth.Principal{Subject: "idp", Method: auth.MethodAPIKey, Role: auth.RoleViewer, ScopeDepartmentID: &engineering.ID})
+		_, total, err = directory.ListEmployees(viewer, ListEmployeesOptions{})
+		if err != nil || total != 3 {
+			t.Fatalf("expected 3 employees in engineering, got %d, %v", total, err)
+		}
+		departments, total, err := directory.ListDepartments(viewer, ListDepartmentsOptions{Limit: 10})
+		if err != nil || total != 2 || departments[0].ID != engineering.ID || departments[1].ID != backend.ID {
+			t.Fatalf("expected engineering and backend, got %d %+v, %v", total, departments, err)
+		}
+		_, err = directory.GetEmployee(viewer, ids[3])
+		assertCode(t, err, apperror.CodeForbidden)
+
+		// Filtering by a field the role may not see would reveal it.
+		svc.employeeFields = EmployeeFieldPolicy{auth.RoleViewer: {EmployeeFieldPosition}}
+		_, _, err = directory.ListEmployees(viewer, ListEmployeesOptions{FullName: []TextMatch{{Op: MatchEquals, Value: "Anna Smirnova"}}})
+		assertCode(t, err, apperror.CodeForbidden)
+	})
+}
+
+func TestExportDirectory(t *testing.T) {
+	forEachStore(t, func(t *testing.T, svc *DepartmentService) {
+		ctx := context.Background()
+		directory := NewDirectoryService(svc)
+		root := mustCreateDepartment(t, svc, "Company", nil)
+		engineering := mustCreateDepartment(t, svc, "Engineering", &root.ID)
+		backend := mustCreateDepartment(t, svc, "Backend", &engineering.ID)
+		mustCreateDepartment(t, svc, "Sales", &root.ID)
+		other := mustCreateDepartment(t, svc, "Holding", nil)
+		if _, err := svc.CreateEmployee(ctx, backend.ID, CreateEmployeeInput{FullName: "Ivan Petrov", Position: "Developer"}); err != nil {
+			t.Fatalf("create employee: %v", err)
+		}
+		// The export is not limited by the maximum depth of subtree requests.
+		svc.maxDepth = 1
+
+		export := func(ctx context.Context, departmentID *uint) ([]string, []DirectoryNode) {
+			t.Helper()
+			var paths []string
+			var nodes []DirectoryNode
+			err := directory.ExportDirectory(ctx, departmentID, func(node DirectoryNode) error {
+				paths = append(paths, strings.Join(node.Path, "/"))
+				nodes = append(nodes, node)
+				return nil
+			})
+			if err != nil {
+				t.Fatalf("export directory: %v", err)
+			}
+			return paths, nodes
+		}
+
+		paths, nodes := export(ctx, nil)
+		want := []string{"Company", "Company/Engineering", "Company/Sales", "Company/Engineering/Backend", "Holding"}
+		if strings.Join(paths, ",") != strings.Join(want, ",") {
+			t.Fatalf("expected every tree parents first, got %v", paths)
+		}
+		if nodes[3].Department.ID != backend.ID || len(nodes[3].Employees) != 1 {
+			t.Fatalf("expected the backend employee, got %+v", nodes[3])
+		}
+
+		// A subtree keeps the path from the top of the tree.
+		paths, _ = export(ctx, &backend.ID)
+		if len(paths) != 1 || paths[0] != "Company/Engineering/Backend" {
+			t.Fatalf("expected the full path of backend, got %v", paths)
+		}
+
+		viewer := auth.WithPrincipal(ctx, auth.Principal{Subject: "ldap-sync", Method: auth.MethodAPIKey, Role: auth.RoleViewer, ScopeDepartmentID: &engineering.ID})
+		// Departments above the scope are not part of a scoped caller's paths.
+		paths, _ = export(viewer, nil)
+		if strings.Join(paths, ",") != "Engineering,Engineering/Backend" {
+			t.Fatalf("expected the scoped subtree, got %v", paths)
+		}
+		paths, _ = export(viewer, &backend.ID)
+		if len(paths) != 1 || paths[0] != "Engineering/Backend" {
+			t.Fatalf("expected the path of backend from the scope, got %v", paths)
+		}
+		err := directory.ExportDirectory(viewer, &other.ID, func(DirectoryNode) error { return nil })
+		assertCode(t, err, apperror.CodeForbidden)
+	})
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"hitalent-go-task/internal/auth"
+	"hitalent-go-task/internal/outbox"
+)
+
+// EventReset is streamed instead of the missed events when a client resumes
+// after events it has not seen were purged. The client should reload its
+// data; the reset carries the id to resume from afterwards.
+const EventReset = "stream.reset"
+
+const eventBacklogPage = 500
+
+type StreamEventsOptions struct {
+	// DepartmentID limits the stream to the subtree of the department.
+	DepartmentID *uint
+	// LastEventID resumes the stream after the given event. Without it only
+	// new events are streamed.
+	LastEventID *uint
+}
+
+type EventStreamer interface {
+	// OpenEventStream checks access and subscribes; the caller must Close
+	// the stream.
+	OpenEventStream(ctx context.Context, options StreamEventsOptions) (*EventStream, error)
+}
+
+// EventService serves the outbox events to API clients, as a live stream and
+// as a change feed for incremental sync. Unlike the outbox sinks it serves
+// untrusted callers, so it checks access to the subtree and masks employee
+// fields the same way the read endpoints do.
+type EventService struct {
+	departments *DepartmentService
+	feed        *outbox.Feed
+	now         func() time.Time
+}
+
+func NewEventService(departments *DepartmentService, feed *outbox.Feed) *EventService {
+	return &EventService{departments: departments, feed: feed, now: time.Now}
+}
+
+func (s *EventService) OpenEventStream(ctx context.Context, options StreamEventsOptions) (*EventStream, error) {
+	departmentID, err := s.streamScope(ctx, options.DepartmentID)
+	if err != nil {
+		return nil, err
+	}
+
+	subscription, err := s.feed.Subscribe(ctx)
+	if err != nil {
+		return nil, err
+	}
+	stream := &EventStream{
+		feed:         s.feed,
+		subscription: subscription,
+		departmentID: departmentID,
+		mask:         s.departments.employeeMask(ctx),
+	}
+
+	if options.LastEventID != nil && *options.LastEventID < subscription.Head() {
+		retained, err := s.feed.Retained(ctx, *options.LastEventID)
+		if err != nil {
+			stream.Close()
+			return nil, err
+		}
+		if retained {
+			stream.replayAfter = *options.LastEventID
+			stream.replayHead = subscription.Head()
+		} else {
+			stream.reset = &outbox.Event{
+				Position:   subscription.Head(),
+				Type:       EventReset,
+				Actor:      "system",
+				OccurredAt: s.now().UTC(),
+				Data:       json.RawMessage(`{}`),
+			}
+		}
+	}
+	return stream, nil
+}
+
+// streamScope returns the subtree to serve. A scoped caller gets its own
+// subtree unless it asks for a department within it.
+func (s *EventService) streamScope(ctx context.Context, departmentID *uint) (*uint, error) {
+	if departmentID == nil {
+		if principal, ok := auth.PrincipalFromContext(ctx); ok {
+			departmentID = principal.ScopeDepartmentID
+		}
+	} else if err := ensureDepartmentExists(ctx, s.departments.store, *departmentID); err != nil {
+		return nil, err
+	}
+	if departmentID == nil {
+		// The whole tree.
+		if err := authorize(ctx, s.departments.store, auth.RoleViewer); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+	if err := authorize(ctx, s.departments.store, auth.RoleViewer, departmentID); err != nil {
+		return nil, err
+	}
+	return departmentID, nil
+}
+
+// EventStream yields the stored events after the resume point and then the
+// live ones, without gaps or duplicates between the two.
+type EventStream struct {
+	feed         *outbox.Feed
+	subscription *outbox.Subscription
+	departmentID *uint
+	mask         func(EmployeeDTO) EmployeeDTO
+
+	reset       *outbox.Event
+	replayAfter uint
+	replayHead  uint
+	backlog     []outbox.Event
+}
+
+// Next blocks until the next event. It fails when ctx is done or the
+// subscription ended, e.g. with outbox.ErrSubscriberTooSlow; the client
+// then resumes from the last event it got.
+func (s *EventStream) Next(ctx context.Context) (outbox.Event, error) {
+	if s.reset != nil {
+		event := *s.reset
+		s.reset = nil
+		return event, nil
+	}
+	for {
+		event, err := s.next(ctx)
+		if err != nil {
+			return outbox.Event{}, err
+		}
+		if s.departmentID != nil && !event.InScope(*s.departmentID) {
+			continue
+		}
+		return maskEvent(event, s.mask)
+	}
+}
+
+func (s *EventStream) next(ctx context.Context) (outbox.Event, error) {
+	for len(s.backlog) == 0 && s.replayAfter < s.replayHead {
+		events, err := s.feed.ReadEvents(ctx, s.replayAfter, eventBacklogPage)
+		if err != nil {
+			return outbox.Event{}, err
+		}
+		if len(events) == 0 {
+			s.replayAfter = s.replayHead
+		}
+		for _, event := range events {
+			if event.Position > s.replayHead {
+				// Already in the subscription buffer.
+				s.replayAfter = s.replayHead
+				break
+			}
+			s.replayAfter = event.Position
+			s.backlog = append(s.backlog, event)
+		}
+	}
+	if len(s.backlog) > 0 {
+		event := s.backlog[0]
+		s.backlog = s.backlog[1:]
+		return event, nil
+	}
+
+	select {
+	case <-ctx.Done():
+		return outbox.Event{}, ctx.Err()
+	case event, ok := <-s.subscription.Events():
+		if !ok {
+			return outbox.Event{}, s.subscription.Err()
+		}
+		return event, nil
+	}
+}
+
+func (s *EventStream) Close() {
+	s.feed.Unsubscribe(s.subscription)
+}
+
+func maskEvent(event outbox.Event, mask func(EmployeeDTO) EmployeeDTO) (outbox.Event, error) {
+	if mask == nil || event.EntityType != entityEmployee {
+		return event, nil
+	}
+	var payload EmployeeEvent
+	if err := json.Unmarshal(event.Data, &payload); err != nil {
+		return outbox.Event{}, fmt.Errorf("decode %s event: %w", event.Type, err)
+	}
+	payload.Employee = mask(payload.Employee)
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return outbox.Event{}, fmt.Errorf("encode %s event: %w", event.Type, err)
+	}
+	event.Data = data
+	return event, nil
+}
@@ -0,0 +1,87 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"testing"
+	"time"
+
+	"hitalent-go-task/internal/apperror"
+	"hitalent-go-task/internal/auth"
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/outbox"
+	"hitalent-go-task/internal/repository/gormrepo"
+)
+
+func nextEvent(t *testing.T, stream *EventStream) outbox.Event {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	event, err := stream.Next(ctx)
+	if err != nil {
+		t.Fatalf("next event: %v", err)
+	}
+	return event
+}
+
+func TestEventStream(t *testing.T) {
+	database := openSQLiteTestDatabase(t)
+	departments := NewDepartmentService(gormrepo.New(database))
+	feed := outbox.NewFeed(database, slog.New(slog.DiscardHandler), outbox.WithFeedInterval(10*time.Millisecond))
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go feed.Run(ctx)
+	events := NewEventService(departments, feed)
+
+	root := mustCreateDepartment(t, departments, "Company", nil)
+	engineering := mustCreateDepartment(t, departments, "Engineering", &root.ID)
+	sales := mustCreateDepartment(t, departments, "Sales", &root.ID)
+
+	viewer := auth.WithPrincipal(ctx, auth.Principal{Subject: "ui", Method: auth.MethodJWT, Role: auth.RoleViewer, ScopeDepartmentID: &engineering.ID})
+	_, err := events.OpenEventStream(viewer, StreamEventsOptions{DepartmentID: &sales.ID})
+	assertCode(t, err, apperror.CodeForbidden)
+
+	// Resuming from the start replays the stored events of the caller's
+	// subtree and then continues with new ones.
+	first := uint(0)
+	stream, err := events.OpenEventStream(viewer, StreamEventsOptions{LastEventID: &first})
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer stream.Close()
+	if event := nextEvent(t, stream); event.Type != EventDepartmentCreated || event.EntityID != engineering.ID {
+		t.Fatalf("expected the creation of engineering, got %+v", event)
+	}
+
+	hiredAt := time.Date(2024, 1, 15, 0, 0, 0, 0, time.UTC)
+	if _, err := departments.CreateEmployee(ctx, sales.ID, CreateEmployeeInput{FullName: "Outside", Position: "Seller"}); err != nil {
+		t.Fatalf("create employee: %v", err)
+	}
+	employee, err := departments.CreateEmployee(ctx, engineering.ID, CreateEmployeeInput{FullName: "Ivan Petrov", Position: "Developer", HiredAt: &hiredAt})
+	if err != nil {
+		t.Fatalf("create employee: %v", err)
+	}
+	event := nextEvent(t, stream)
+	var payload EmployeeEvent
+	if err := json.Unmarshal(event.Data, &payload); err != nil || event.EntityID != employee.ID {
+		t.Fatalf("expected the engineering employee, got %+v, %v", event, err)
+	}
+	if payload.Employee.FullName != "Ivan Petrov" || payload.Employee.HiredAt != nil {
+		t.Fatalf("expected the hire date to be hidden from a viewer, got %+v", payload.Employee)
+	}
+
+	// Events that were purged since the client's last one are replaced by a
+	// reset.
+	if err := database.Where("id <= ?", 2).Delete(&models.OutboxEvent{}).Error; err != nil {
+		t.Fatalf("purge events: %v", err)
+	}
+	stale, err := events.OpenEventStream(viewer, StreamEventsOptions{LastEventID: &first})
+	if err != nil {
+		t.Fatalf("open stream: %v", err)
+	}
+	defer stale.Close()
+	if event := nextEvent(t, stale); event.Type != EventReset {
+		t.Fatalf("expected a reset, got %+v", event)
+	}
+}
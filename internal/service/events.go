@@ -0,0 +1,108 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+)
+
+// Change event types written to the outbox.
+const (
+	EventDepartmentCreated = "department.created"
+	EventDepartmentRenamed = "department.renamed"
+	EventDepartmentMoved   = "department.moved"
+	EventDepartmentDeleted = "department.deleted"
+	EventEmployeeCreated   = "employee.created"
+	EventEmployeeUpdated   = "employee.updated"
+	EventEmployeeMoved     = "employee.moved"
+	EventEmployeeErased    = "employee.erased"
+	EventEmployeeDeleted   = "employee.deleted"
+)
+
+const (
+	entityDepartment = "department"
+	entityEmployee   = "employee"
+)
+
+// DepartmentEvent is the payload of department.* events. The previous values
+// are set by renames and moves.
+type DepartmentEvent struct {
+	Department       DepartmentDTO `json:"department"`
+	PreviousName     string        `json:"previous_name,omitempty"`
+	PreviousParentID *uint         `json:"previous_parent_id,omitempty"`
+}
+
+// EmployeeEvent is the payload of employee.* events. Erasing an employee
+// replaces the payloads of all its earlier events with the anonymized record
+// and sets Redacted.
+type EmployeeEvent struct {
+	Employee             EmployeeDTO `json:"employee"`
+	PreviousDepartmentID uint        `json:"previous_department_id,omitempty"`
+	Redacted             bool        `json:"redacted,omitempty"`
+}
+
+// recordEvent appends a change event in the same transaction as the change.
+// Events carry unmasked employee data; consumers are trusted integrations.
+// scope holds the departments whose subtrees the event belongs to, so that
+// subscribers of a subtree still get it after the entity is gone.
+func recordEvent(ctx context.Context, tx repository.Repositories, eventType string, entityType string, entityID uint, payload any, scope []uint) error {
+	encoded, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("encode %s event: %w", eventType, err)
+	}
+	return tx.Outbox().Append(ctx, &models.OutboxEvent{
+		EventType:  eventType,
+		EntityType: entityType,
+		EntityID:   entityID,
+		Actor:      actorFromContext(ctx),
+		Payload:    string(encoded),
+		Scope:      formatScope(scope),
+	})
+}
+
+func recordDepartmentEvent(ctx context.Context, tx repository.Repositories, eventType string, event DepartmentEvent, scope []uint) error {
+	return recordEvent(ctx, tx, eventType, entityDepartment, event.Department.ID, event, scope)
+}
+
+func recordEmployeeEvent(ctx context.Context, tx repository.Repositories, eventType string, event EmployeeEvent, scope []uint) error {
+	return recordEvent(ctx, tx, eventType, entityEmployee, event.Employee.ID, event, scope)
+}
+
+// eventScope returns the current ancestry of the given departments, each
+// including the department itself.
+func eventScope(ctx context.Context, tx repository.Repositories, departmentIDs ...uint) ([]uint, error) {
+	var scope []uint
+	for _, departmentID := range departmentIDs {
+		ancestors, err := tx.Departments().AncestorIDs(ctx, departmentID)
+		if err != nil {
+			return nil, err
+		}
+		scope = append(scope, ancestors...)
+	}
+	return scope, nil
+}
+
+// formatScope encodes department ids as ",1,4,7," so that a subtree can be
+// matched with a substring search for ",id,".
+func formatScope(departmentIDs []uint) string {
+	if len(departmentIDs) == 0 {
+		return ""
+	}
+	seen := make(map[uint]bool, len(departmentIDs))
+	var b strings.Builder
+	b.WriteByte(',')
+	for _, id := range departmentIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		b.WriteString(strconv.FormatUint(uint64(id), 10))
+		b.WriteByte(',')
+	}
+	return b.String()
+}
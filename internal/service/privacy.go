@@ -0,0 +1,130 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"hitalent-go-task/internal/auth"
+	"hitalent-go-task/internal/models"
+)
+
+type EmployeeField string
+
+const (
+	EmployeeFieldFullName EmployeeField = "full_name"
+	EmployeeFieldPosition EmployeeField = "position"
+	EmployeeFieldHiredAt  EmployeeField = "hired_at"
+)
+
+var maskableEmployeeFields = []EmployeeField{EmployeeFieldFullName, EmployeeFieldPosition, EmployeeFieldHiredAt}
+
+// EmployeeFieldPolicy lists the personal employee fields each role may see.
+// Roles that are not listed see every field. Identifiers, versions and
+// timestamps are never masked.
+type EmployeeFieldPolicy map[auth.Role][]EmployeeField
+
+// DefaultEmployeeFieldPolicy hides hire dates from viewers.
+func DefaultEmployeeFieldPolicy() EmployeeFieldPolicy {
+	return EmployeeFieldPolicy{
+		auth.RoleViewer: {EmployeeFieldFullName, EmployeeFieldPosition},
+	}
+}
+
+// ParseEmployeeFieldPolicy reads a policy such as
+// "viewer=full_name,position;editor=full_name,position,hired_at".
+// An empty field list hides every maskable field from that role.
+func ParseEmployeeFieldPolicy(raw string) (EmployeeFieldPolicy, error) {
+	policy := make(EmployeeFieldPolicy)
+	for _, rule := range strings.Split(raw, ";") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		rawRole, rawFields, ok := strings.Cut(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("rule %q must look like role=field,field", rule)
+		}
+		role, err := auth.ParseRole(strings.TrimSpace(rawRole))
+		if err != nil {
+			return nil, err
+		}
+
+		fields := make([]EmployeeField, 0)
+		for _, rawField := range strings.Split(rawFields, ",") {
+			field := EmployeeField(strings.TrimSpace(rawField))
+			if field == "" {
+				continue
+			}
+			if !isMaskableEmployeeField(field) {
+				return nil, fmt.Errorf("unknown employee field %q", field)
+			}
+			fields = append(fields, field)
+		}
+		policy[role] = fields
+	}
+	return policy, nil
+}
+
+func isMaskableEmployeeField(field EmployeeField) bool {
+	for _, maskable := range maskableEmployeeFields {
+		if field == maskable {
+			return true
+		}
+	}
+	return false
+}
+
+// employeeView returns the conversion to use for the caller in ctx. Every
+// place that renders employees must go through it.
+func (s *DepartmentService) employeeView(ctx context.Context) func(models.Employee) EmployeeDTO {
+	mask := s.employeeMask(ctx)
+	if mask == nil {
+		return employeeToDTO
+	}
+	return func(employee models.Employee) EmployeeDTO {
+		return mask(employeeToDTO(employee))
+	}
+}
+
+// employeeFieldVisible reports whether the caller in ctx may see field.
+func (s *DepartmentService) employeeFieldVisible(ctx context.Context, field EmployeeField) bool {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return true
+	}
+	fields, restricted := s.employeeFields[principal.Role]
+	return !restricted || slices.Contains(fields, field)
+}
+
+// employeeMask hides the fields the caller in ctx may not see. It returns nil
+// when the caller sees every field.
+func (s *DepartmentService) employeeMask(ctx context.Context) func(EmployeeDTO) EmployeeDTO {
+	principal, ok := auth.PrincipalFromContext(ctx)
+	if !ok {
+		return nil
+	}
+	fields, restricted := s.employeeFields[principal.Role]
+	if !restricted {
+		return nil
+	}
+
+	visible := make(map[EmployeeField]bool, len(fields))
+	for _, field := range fields {
+		visible[field] = true
+	}
+	return func(dto EmployeeDTO) EmployeeDTO {
+		if !visible[EmployeeFieldFullName] {
+			dto.FullName = ""
+		}
+		if !visible[EmployeeFieldPosition] {
+			dto.Position = ""
+		}
+		if !visible[EmployeeFieldHiredAt] {
+			dto.HiredAt = nil
+		}
+		return dto
+	}
+}
@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"hitalent-go-task/internal/apperror"
+)
+
+// spans names the spans of one service after its methods.
+type spans struct {
+	tracer  trace.Tracer
+	service string
+}
+
+func newSpans(service string) spans {
+	return spans{tracer: otel.Tracer("hitalent-go-task/internal/service"), service: service}
+}
+
+func (s spans) start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, s.service+"."+name, trace.WithAttributes(attrs...))
+}
+
+// tracedManager wraps a Manager with one span per call.
+type tracedManager struct {
+	spans
+	next Manager
+}
+
+func NewTracedManager(next Manager) Manager {
+	return tracedManager{spans: newSpans("DepartmentService"), next: next}
+}
+
+// endSpan marks only unexpected errors as span failures: a 404 or 409 is a
+// normal outcome for the service.
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		if apperror.GetCode(err) == apperror.CodeInternal {
+			span.SetStatus(codes.Error, err.Error())
+		}
+	}
+	span.End()
+}
+
+func (m tracedManager) CreateDepartment(ctx context.Context, input CreateDepartmentInput) (DepartmentDTO, error) {
+	ctx, span := m.start(ctx, "CreateDepartment")
+	department, err := m.next.CreateDepartment(ctx, input)
+	endSpan(span, err)
+	return department, err
+}
+
+func (m tracedManager) CreateEmployee(ctx context.Context, departmentID uint, input CreateEmployeeInput) (EmployeeDTO, error) {
+	ctx, span := m.start(ctx, "CreateEmployee", attribute.Int64("department.id", int64(departmentID)))
+	employee, err := m.next.CreateEmployee(ctx, departmentID, input)
+	endSpan(span, err)
+	return employee, err
+}
+
+func (m tracedManager) GetDepartment(ctx context.Context, departmentID uint, options GetDepartmentOptions) (DepartmentTree, error) {
+	ctx, span := m.start(ctx, "GetDepartment",
+		attribute.Int64("department.id", int64(departmentID)),
+		attribute.Int("tree.depth", options.Depth),
+		attribute.Bool("tree.include_employees", options.IncludeEmployees),
+	)
+	tree, err := m.next.GetDepartment(ctx, departmentID, options)
+	endSpan(span, err)
+	return tree, err
+}
+
+func (m tracedManager) StreamDepartment(ctx context.Context, departmentID uint, options GetDepartmentOptions, emit func(DepartmentNode) error) error {
+	ctx, span := m.start(ctx, "StreamDepartment",
+		attribute.Int64("department.id", int64(departmentID)),
+		attribute.Int("tree.depth", options.Depth),
+		attribute.Bool("tree.include_employees", options.IncludeEmployees),
+	)
+	nodes := 0
+	err := m.next.StreamDepartment(ctx, departmentID, options, func(node DepartmentNode) error {
+		nodes++
+		return emit(node)
+	})
+	span.SetAttributes(attribute.Int("tree.nodes", nodes))
+	endSpan(span, err)
+	return err
+}
+
+func (m tracedManager) UpdateDepartment(ctx context.Context, departmentID uint, input UpdateDepartmentInput) (DepartmentDTO, error) {
+	ctx, span := m.start(ctx, "UpdateDepartment", attribute.Int64("department.id", int64(departmentID)))
+	department, err := m.next.UpdateDepartment(ctx, departmentID, input)
+	endSpan(span, err)
+	return department, err
+}
+
+func (m tracedManager) DeleteDepartment(ctx context.Context, departmentID uint, mode DeleteMode, reassignToDepartmentID *uint) error {
+	ctx, span := m.start(ctx, "DeleteDepartment",
+		attribute.Int64("department.id", int64(departmentID)),
+		attribute.String("delete.mode", string(mode)),
+	)
+	err := m.next.DeleteDepartment(ctx, departmentID, mode, reassignToDepartmentID)
+	endSpan(span, err)
+	return err
+}
+
+func (m tracedManager) EraseEmployee(ctx context.Context, departmentID uint, employeeID uint) (EmployeeDTO, error) {
+	ctx, span := m.start(ctx, "EraseEmployee",
+		attribute.Int64("department.id", int64(departmentID)),
+		attribute.Int64("employee.id", int64(employeeID)),
+	)
+	employee, err := m.next.EraseEmployee(ctx, departmentID, employeeID)
+	endSpan(span, err)
+	return employee, err
+}
+
+// tracedDirectory wraps a DirectoryService with one span per call.
+type tracedDirectory struct {
+	spans
+	next *DirectoryService
+}
+
+// NewTracedDirectory returns the traced SCIM view and LDIF export of next.
+func NewTracedDirectory(next *DirectoryService) (Directory, DirectoryExporter) {
+	directory := tracedDirectory{spans: newSpans("DirectoryService"), next: next}
+	return directory, directory
+}
+
+func (d tracedDirectory) ListEmployees(ctx context.Context, options ListEmployeesOptions) ([]EmployeeDTO, int64, error) {
+	ctx, span := d.start(ctx, "ListEmployees", attribute.Int("page.offset", options.Offset), attribute.Int("page.limit", options.Limit))
+	employees, total, err := d.next.ListEmployees(ctx, options)
+	endSpan(span, err)
+	return employees, total, err
+}
+
+func (d tracedDirectory) GetEmployee(ctx context.Context, employeeID uint) (EmployeeDTO, error) {
+	ctx, span := d.start(ctx, "GetEmployee", attribute.Int64("employee.id", int64(employeeID)))
+	employee, err := d.next.GetEmployee(ctx, employeeID)
+	endSpan(span, err)
+	return employee, err
+}
+
+func (d tracedDirectory) UpdateEmployee(ctx context.Context, employeeID uint, input UpdateEmployeeInput) (EmployeeDTO, error) {
+	ctx, span := d.start(ctx, "UpdateEmployee", attribute.Int64("employee.id", int64(employeeID)))
+	employee, err := d.next.UpdateEmployee(ctx, employeeID, input)
+	endSpan(span, err)
+	return employee, err
+}
+
+func (d tracedDirectory) ListDepartments(ctx context.Context, options ListDepartmentsOptions) ([]DepartmentDTO, int64, error) {
+	ctx, span := d.start(ctx, "ListDepartments", attribute.Int("page.offset", options.Offset), attribute.Int("page.limit", options.Limit))
+	departments, total, err := d.next.ListDepartments(ctx, options)
+	endSpan(span, err)
+	return departments, total, err
+}
+
+func (d tracedDirectory) DepartmentMembers(ctx context.Context, departmentIDs []uint) (map[uint][]EmployeeDTO, error) {
+	ctx, span := d.start(ctx, "DepartmentMembers", attribute.Int("departments", len(departmentIDs)))
+	members, err := d.next.DepartmentMembers(ctx, departmentIDs)
+	endSpan(span, err)
+	return members, err
+}
+
+func (d tracedDirectory) GetDepartment(ctx context.Context, departmentID uint, options GetDepartmentOptions) (DepartmentTree, error) {
+	ctx, span := d.start(ctx, "GetDepartment",
+		attribute.Int64("department.id", int64(departmentID)),
+		attribute.Int("tree.depth", options.Depth),
+		attribute.Bool("tree.include_employees", options.IncludeEmployees),
+	)
+	tree, err := d.next.GetDepartment(ctx, departmentID, options)
+	endSpan(span, err)
+	return tree, err
+}
+
+func (d tracedDirectory) UpdateDepartment(ctx context.Context, departmentID uint, input UpdateDepartmentInput) (DepartmentDTO, error) {
+	ctx, span := d.start(ctx, "UpdateDepartment",
+		attribute.Int64("department.id", int64(departmentID)),
+		attribute.Int("employees.moved", len(input.EmployeeIDs)),
+	)
+	department, err := d.next.UpdateDepartment(ctx, departmentID, input)
+	endSpan(span, err)
+	return department, err
+}
+
+func (d tracedDirectory) ExportDirectory(ctx context.Context, departmentID *uint, emit func(DirectoryNode) error) error {
+	var attrs []attribute.KeyValue
+	if departmentID != nil {
+		attrs = append(attrs, attribute.Int64("department.id", int64(*departmentID)))
+	}
+	ctx, span := d.start(ctx, "ExportDirectory", attrs...)
+	nodes := 0
+	err := d.next.ExportDirectory(ctx, departmentID, func(node DirectoryNode) error {
+		nodes++
+		return emit(node)
+	})
+	span.SetAttributes(attribute.Int("tree.nodes", nodes))
+	endSpan(span, err)
+	return err
+}
+
+// tracedEvents wraps an EventService with one span per call. A stream's
+// span ends once it is open, not when the client leaves.
+type tracedEvents struct {
+	spans
+	next *EventService
+}
+
+// NewTracedEventService returns the traced event stream and change feed of next.
+func NewTracedEventService(next *EventService) (EventStreamer, ChangeLister) {
+	events := tracedEvents{spans: newSpans("EventService"), next: next}
+	return events, events
+}
+
+func (e tracedEvents) OpenEventStream(ctx context.Context, options StreamEventsOptions) (*EventStream, error) {
+	ctx, span := e.start(ctx, "OpenEventStream")
+	stream, err := e.next.OpenEventStream(ctx, options)
+	endSpan(span, err)
+	return stream, err
+}
+
+func (e tracedEvents) ListChanges(ctx context.Context, options ListChangesOptions) (ChangePage, error) {
+	ctx, span := e.start(ctx, "ListChanges", attribute.Int("page.limit", options.Limit))
+	page, err := e.next.ListChanges(ctx, options)
+	endSpan(span, err)
+	return page, err
+}
+
+// tracedWebhooks wraps a WebhookManager with one span per call.
+type tracedWebhooks struct {
+	spans
+	next WebhookManager
+}
+
+func NewTracedWebhookManager(next WebhookManager) WebhookManager {
+	return tracedWebhooks{spans: newSpans("WebhookService"), next: next}
+}
+
+func (w tracedWebhooks) CreateWebhook(ctx context.Context, input CreateWebhookInput) (WebhookDTO, error) {
+	ctx, span := w.start(ctx, "CreateWebhook")
+	webhook, err := w.next.CreateWebhook(ctx, input)
+	endSpan(span, err)
+	return webhook, err
+}
+
+func (w tracedWebhooks) ListWebhooks(ctx context.Context) ([]WebhookDTO, error) {
+	ctx, span := w.start(ctx, "ListWebhooks")
+	webhooks, err := w.next.ListWebhooks(ctx)
+	endSpan(span, err)
+	return webhooks, err
+}
+
+func (w tracedWebhooks) GetWebhook(ctx context.Context, id uint) (WebhookDTO, error) {
+	ctx, span := w.start(ctx, "GetWebhook", attribute.Int64("webhook.id", int64(id)))
+	webhook, err := w.next.GetWebhook(ctx, id)
+	endSpan(span, err)
+	return webhook, err
+}
+
+func (w tracedWebhooks) UpdateWebhook(ctx context.Context, id uint, input UpdateWebhookInput) (WebhookDTO, error) {
+	ctx, span := w.start(ctx, "UpdateWebhook", attribute.Int64("webhook.id", int64(id)))
+	webhook, err := w.next.UpdateWebhook(ctx, id, input)
+	endSpan(span, err)
+	return webhook, err
+}
+
+func (w tracedWebhooks) DeleteWebhook(ctx context.Context, id uint) error {
+	ctx, span := w.start(ctx, "DeleteWebhook", attribute.Int64("webhook.id", int64(id)))
+	err := w.next.DeleteWebhook(ctx, id)
+	endSpan(span, err)
+	return err
+}
+
+func (w tracedWebhooks) ListWebhookDeliveries(ctx context.Context, id uint, options ListDeliveriesOptions) ([]WebhookDeliveryDTO, error) {
+	ctx, span := w.start(ctx, "ListWebhookDeliveries", attribute.Int64("webhook.id", int64(id)))
+	deliveries, err := w.next.ListWebhookDeliveries(ctx, id, options)
+	endSpan(span, err)
+	return deliveries, err
+}
+
+func (w tracedWebhooks) RedeliverWebhook(ctx context.Context, id uint, deliveryID uint) error {
+	ctx, span := w.start(ctx, "RedeliverWebhook",
+		attribute.Int64("webhook.id", int64(id)),
+		attribute.Int64("delivery.id", int64(deliveryID)),
+	)
+	err := w.next.RedeliverWebhook(ctx, id, deliveryID)
+	endSpan(span, err)
+	return err
+}
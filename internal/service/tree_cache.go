@@ -0,0 +1,131 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"time"
+
+	"hitalent-go-task/internal/cache"
+	"hitalent-go-task/internal/repository"
+)
+
+// maxCachedDepth bounds the cached depths when the server allows unlimited
+// subtrees, so that invalidation can enumerate every key of a department.
+const maxCachedDepth = 32
+
+type treeCache struct {
+	cache cache.Cache
+	ttl   time.Duration
+}
+
+// WithTreeCache caches GetDepartment results for ttl. Entries are stored
+// before employee fields are masked, so one entry serves every role; the
+// caller's scope is still checked on every hit. Mutations drop the entries
+// they affect, the ttl only bounds races with concurrent readers.
+func WithTreeCache(c cache.Cache, ttl time.Duration) Option {
+	return func(s *DepartmentService) {
+		s.trees = &treeCache{cache: c, ttl: ttl}
+	}
+}
+
+func treeCacheKey(departmentID uint, depth int, includeEmployees bool) string {
+	levels := "all"
+	if depth >= 0 {
+		levels = strconv.Itoa(depth)
+	}
+	return fmt.Sprintf("department_tree:%d:%s:%t", departmentID, levels, includeEmployees)
+}
+
+// treeCacheKeyFor returns the key of a resolved depth, or "" when the result
+// must not be cached.
+func (s *DepartmentService) treeCacheKeyFor(departmentID uint, depth int, includeEmployees bool) string {
+	if s.trees == nil || depth > s.maxCachedDepth() {
+		return ""
+	}
+	return treeCacheKey(departmentID, depth, includeEmployees)
+}
+
+func (s *DepartmentService) maxCachedDepth() int {
+	if s.maxDepth >= 0 {
+		return s.maxDepth
+	}
+	return maxCachedDepth
+}
+
+func (s *DepartmentService) cachedTree(ctx context.Context, key string) (DepartmentTree, bool) {
+	value, found, err := s.trees.cache.Get(ctx, key)
+	if err != nil || !found {
+		return DepartmentTree{}, false
+	}
+	var tree DepartmentTree
+	if err := json.Unmarshal(value, &tree); err != nil {
+		return DepartmentTree{}, false
+	}
+	return tree, true
+}
+
+// storeTree is best effort: a failing cache only costs the next reader a query.
+func (s *DepartmentService) storeTree(ctx context.Context, key string, tree DepartmentTree) {
+	value, err := json.Marshal(tree)
+	if err != nil {
+		return
+	}
+	_ = s.trees.cache.Set(ctx, key, value, s.trees.ttl)
+}
+
+// treeKeys lists every cache key of the subtree rooted at departmentID.
+func (s *DepartmentService) treeKeys(departmentID uint) []string {
+	if s.trees == nil {
+		return nil
+	}
+	keys := make([]string, 0, 2*(s.maxCachedDepth()+2))
+	for depth := DepthAll; depth <= s.maxCachedDepth(); depth++ {
+		keys = append(keys, treeCacheKey(departmentID, depth, false), treeCacheKey(departmentID, depth, true))
+	}
+	return keys
+}
+
+// staleTrees returns the keys of every cached subtree that contains one of
+// the departments: the ones rooted at the departments themselves and at their
+// ancestors. Ancestors are dropped at every depth, which also covers depths
+// that do not reach the department: cheaper than computing which ones do.
+func (s *DepartmentService) staleTrees(ctx context.Context, repos repository.Repositories, departmentIDs ...uint) ([]string, error) {
+	if s.trees == nil {
+		return nil, nil
+	}
+	var keys []string
+	for _, departmentID := range departmentIDs {
+		ancestorIDs, err := repos.Departments().AncestorIDs(ctx, departmentID)
+		if err != nil {
+			return nil, err
+		}
+		for _, ancestorID := range ancestorIDs {
+			keys = append(keys, s.treeKeys(ancestorID)...)
+		}
+	}
+	return keys, nil
+}
+
+// invalidateTrees drops the keys once the mutation has committed. It
+// outlives a cancelled request, and a failure leaves the entries to expire.
+func (s *DepartmentService) invalidateTrees(ctx context.Context, keys []string) {
+	if s.trees == nil || len(keys) == 0 {
+		return
+	}
+	_ = s.trees.cache.Delete(context.WithoutCancel(ctx), keys...)
+}
+
+// maskTree applies the caller's employee field policy to an unmasked tree in
+// place.
+func maskTree(tree *DepartmentTree, mask func(EmployeeDTO) EmployeeDTO) {
+	if tree.Employees != nil {
+		for i := range *tree.Employees {
+			(*tree.Employees)[i] = mask((*tree.Employees)[i])
+		}
+	}
+	for i := range tree.Children {
+		maskTree(&tree.Children[i], mask)
+	}
+}
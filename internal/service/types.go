@@ -21,6 +21,11 @@ type UpdateDepartmentInput struct {
 	Name        *string
 	ParentIDSet bool
 	ParentID    *uint
+	// EmployeeIDs are moved into the department in the same transaction.
+	EmployeeIDs []uint
+	// ExpectedVersion enables optimistic concurrency: the update fails with
+	// apperror.CodePreconditionFailed when the stored version differs.
+	ExpectedVersion *int64
 }
 
 type CreateEmployeeInput struct {
@@ -29,6 +34,18 @@ type CreateEmployeeInput struct {
 	HiredAt  *time.Time
 }
 
+type UpdateEmployeeInput struct {
+	FullName *string
+	Position *string
+	// DepartmentID moves the employee to another department.
+	DepartmentID *uint
+	// ExpectedVersion works as in UpdateDepartmentInput.
+	ExpectedVersion *int64
+}
+
+// DepthAll requests the whole subtree, limited only by the server maximum.
+const DepthAll = -1
+
 type GetDepartmentOptions struct {
 	Depth            int
 	IncludeEmployees bool
@@ -38,16 +55,19 @@ type DepartmentDTO struct {
 	ID        uint      `json:"id"`
 	Name      string    `json:"name"`
 	ParentID  *uint     `json:"parent_id"`
+	Version   int64     `json:"version"`
 	CreatedAt time.Time `json:"created_at"`
 }
 
 type EmployeeDTO struct {
-	ID           uint      `json:"id"`
-	DepartmentID uint      `json:"department_id"`
-	FullName     string    `json:"full_name"`
-	Position     string    `json:"position"`
-	HiredAt      *string   `json:"hired_at,omitempty"`
-	CreatedAt    time.Time `json:"created_at"`
+	ID           uint       `json:"id"`
+	DepartmentID uint       `json:"department_id"`
+	FullName     string     `json:"full_name,omitempty"`
+	Position     string     `json:"position,omitempty"`
+	HiredAt      *string    `json:"hired_at,omitempty"`
+	Version      int64      `json:"version"`
+	ErasedAt     *time.Time `json:"erased_at,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
 }
 
 type DepartmentTree struct {
@@ -56,10 +76,20 @@ type DepartmentTree struct {
 	Children   []DepartmentTree `json:"children"`
 }
 
+// DepartmentNode is a single line of a streamed subtree. Nodes arrive parents
+// first; Department.ParentID links them into a tree.
+type DepartmentNode struct {
+	Department DepartmentDTO  `json:"department"`
+	Depth      int            `json:"depth"`
+	Employees  *[]EmployeeDTO `json:"employees,omitempty"`
+}
+
 type Manager interface {
 	CreateDepartment(ctx context.Context, input CreateDepartmentInput) (DepartmentDTO, error)
 	CreateEmployee(ctx context.Context, departmentID uint, input CreateEmployeeInput) (EmployeeDTO, error)
 	GetDepartment(ctx context.Context, departmentID uint, options GetDepartmentOptions) (DepartmentTree, error)
+	StreamDepartment(ctx context.Context, departmentID uint, options GetDepartmentOptions, emit func(DepartmentNode) error) error
 	UpdateDepartment(ctx context.Context, departmentID uint, input UpdateDepartmentInput) (DepartmentDTO, error)
 	DeleteDepartment(ctx context.Context, departmentID uint, mode DeleteMode, reassignToDepartmentID *uint) error
+	EraseEmployee(ctx context.Context, departmentID uint, employeeID uint) (EmployeeDTO, error)
 }
@@ -0,0 +1,454 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+	"time"
+
+	"hitalent-go-task/internal/apperror"
+	"hitalent-go-task/internal/auth"
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/repository"
+	"hitalent-go-task/internal/webhook"
+)
+
+const (
+	webhookSecretPrefix  = "whsec_"
+	maxWebhookURLLength  = 2048
+	defaultDeliveryLimit = 50
+	maxDeliveryListLimit = 200
+)
+
+var (
+	errWebhookNotFound         = apperror.New(apperror.CodeNotFound, "webhook not found")
+	errWebhookDeliveryNotFound = apperror.New(apperror.CodeNotFound, "webhook delivery not found")
+)
+
+// EventTypes lists the change events that can be subscribed to.
+var EventTypes = []string{
+	EventDepartmentCreated,
+	EventDepartmentRenamed,
+	EventDepartmentMoved,
+	EventDepartmentDeleted,
+	EventEmployeeCreated,
+	EventEmployeeUpdated,
+	EventEmployeeMoved,
+	EventEmployeeErased,
+	EventEmployeeDeleted,
+}
+
+type CreateWebhookInput struct {
+	URL string
+	// EventTypes filters the delivered events; empty means all.
+	EventTypes []string
+	// DepartmentID limits deliveries to events within this subtree.
+	DepartmentID *uint
+}
+
+type UpdateWebhookInput struct {
+	URL             *string
+	EventTypes      *[]string
+	DepartmentIDSet bool
+	DepartmentID    *uint
+	Active          *bool
+	// RotateSecret replaces the signing secret; the new one is returned once.
+	RotateSecret bool
+}
+
+type WebhookDTO struct {
+	ID           uint     `json:"id"`
+	URL          string   `json:"url"`
+	EventTypes   []string `json:"event_types"`
+	DepartmentID *uint    `json:"department_id"`
+	Active       bool     `json:"active"`
+	// Secret is only returned when it is created or rotated.
+	Secret    string    `json:"secret,omitempty"`
+	CreatedBy string    `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+type WebhookDeliveryDTO struct {
+	ID             uint       `json:"id"`
+	EventID        uint       `json:"event_id"`
+	EventType      string     `json:"event_type"`
+	EntityType     string     `json:"entity_type"`
+	EntityID       uint       `json:"entity_id"`
+	Status         string     `json:"status"`
+	Attempts       int        `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastStatusCode int        `json:"last_status_code,omitempty"`
+	LastError      string     `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+type ListDeliveriesOptions struct {
+	// Status filters by delivery status; "dead" lists the dead letters.
+	Status   string
+	BeforeID uint
+	Limit    int
+}
+
+type WebhookManager interface {
+	CreateWebhook(ctx context.Context, input CreateWebhookInput) (WebhookDTO, error)
+	ListWebhooks(ctx context.Context) ([]WebhookDTO, error)
+	GetWebhook(ctx context.Context, id uint) (WebhookDTO, error)
+	UpdateWebhook(ctx context.Context, id uint, input UpdateWebhookInput) (WebhookDTO, error)
+	DeleteWebhook(ctx context.Context, id uint) error
+	ListWebhookDeliveries(ctx context.Context, id uint, options ListDeliveriesOptions) ([]WebhookDeliveryDTO, error)
+	RedeliverWebhook(ctx context.Context, id uint, deliveryID uint) error
+}
+
+// WebhookService manages webhook subscriptions. Managing them requires the
+// admin role; a scoped admin only sees and creates subscriptions within its
+// subtree.
+type WebhookService struct {
+	store        repository.Store
+	resolver     webhook.Resolver
+	allowPrivate bool
+	now          func() time.Time
+}
+
+type WebhookOption func(*WebhookService)
+
+// WithWebhookResolver replaces the DNS resolver used to check target hosts.
+func WithWebhookResolver(resolver webhook.Resolver) WebhookOption {
+	return func(s *WebhookService) {
+		s.resolver = resolver
+	}
+}
+
+// WithPrivateWebhookTargets allows subscriptions to loopback and private
+// addresses, for receivers in the same network as the service.
+func WithPrivateWebhookTargets(allow bool) WebhookOption {
+	return func(s *WebhookService) {
+		s.allowPrivate = allow
+	}
+}
+
+func NewWebhookService(store repository.Store, opts ...WebhookOption) *WebhookService {
+	s := &WebhookService{store: store, resolver: net.DefaultResolver, now: time.Now}
+	for _, opt := range opts {
+		opt(s)
+	}
+	return s
+}
+
+func (s *WebhookService) CreateWebhook(ctx context.Context, input CreateWebhookInput) (WebhookDTO, error) {
+	rawURL, err := s.normalizeWebhookURL(ctx, input.URL)
+	if err != nil {
+		return WebhookDTO{}, err
+	}
+	eventTypes, err := normalizeEventTypes(input.EventTypes)
+	if err != nil {
+		return WebhookDTO{}, err
+	}
+	secret, err := generateWebhookSecret()
+	if err != nil {
+		return WebhookDTO{}, err
+	}
+
+	var subscription models.WebhookSubscription
+	err = s.store.WithinTransaction(ctx, func(tx repository.Repositories) error {
+		if input.DepartmentID != nil {
+			if err := ensureDepartmentExists(ctx, tx, *input.DepartmentID); err != nil {
+				return err
+			}
+		}
+		if err := authorize(ctx, tx, auth.RoleAdmin, input.DepartmentID); err != nil {
+			return err
+		}
+		now := s.now().UTC()
+		subscription = models.WebhookSubscription{
+			URL:          rawURL,
+			Secret:       secret,
+			EventTypes:   strings.Join(eventTypes, ","),
+			DepartmentID: input.DepartmentID,
+			Active:       true,
+			CreatedBy:    actorFromContext(ctx),
+			CreatedAt:    now,
+			UpdatedAt:    now,
+		}
+		if err := tx.Webhooks().Create(ctx, &subscription); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, "webhook.create", "webhook", subscription.ID)
+	})
+	if err != nil {
+		return WebhookDTO{}, err
+	}
+
+	dto := webhookToDTO(subscription)
+	dto.Secret = secret
+	return dto, nil
+}
+
+func (s *WebhookService) ListWebhooks(ctx context.Context) ([]WebhookDTO, error) {
+	if err := authorize(ctx, s.store, auth.RoleAdmin); err != nil {
+		return nil, err
+	}
+	subscriptions, err := s.store.Webhooks().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]WebhookDTO, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		err := authorize(ctx, s.store, auth.RoleAdmin, subscription.DepartmentID)
+		if errors.Is(err, errOutOfScope) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhookToDTO(subscription))
+	}
+	return webhooks, nil
+}
+
+func (s *WebhookService) GetWebhook(ctx context.Context, id uint) (WebhookDTO, error) {
+	subscription, err := loadWebhook(ctx, s.store, id)
+	if err != nil {
+		return WebhookDTO{}, err
+	}
+	return webhookToDTO(subscription), nil
+}
+
+func (s *WebhookService) UpdateWebhook(ctx context.Context, id uint, input UpdateWebhookInput) (WebhookDTO, error) {
+	var rawURL string
+	if input.URL != nil {
+		normalized, err := s.normalizeWebhookURL(ctx, *input.URL)
+		if err != nil {
+			return WebhookDTO{}, err
+		}
+		rawURL = normalized
+	}
+	var eventTypes []string
+	if input.EventTypes != nil {
+		normalized, err := normalizeEventTypes(*input.EventTypes)
+		if err != nil {
+			return WebhookDTO{}, err
+		}
+		eventTypes = normalized
+	}
+	var secret string
+	if input.RotateSecret {
+		generated, err := generateWebhookSecret()
+		if err != nil {
+			return WebhookDTO{}, err
+		}
+		secret = generated
+	}
+
+	var subscription models.WebhookSubscription
+	err := s.store.WithinTransaction(ctx, func(tx repository.Repositories) error {
+		var err error
+		subscription, err = loadWebhook(ctx, tx, id)
+		if err != nil {
+			return err
+		}
+
+		if input.URL != nil {
+			subscription.URL = rawURL
+		}
+		if input.EventTypes != nil {
+			subscription.EventTypes = strings.Join(eventTypes, ",")
+		}
+		if input.DepartmentIDSet {
+			if input.DepartmentID != nil {
+				if err := ensureDepartmentExists(ctx, tx, *input.DepartmentID); err != nil {
+					return err
+				}
+			}
+			if err := authorize(ctx, tx, auth.RoleAdmin, input.DepartmentID); err != nil {
+				return err
+			}
+			subscription.DepartmentID = input.DepartmentID
+		}
+		if input.Active != nil {
+			subscription.Active = *input.Active
+		}
+		if input.RotateSecret {
+			subscription.Secret = secret
+		}
+		subscription.UpdatedAt = s.now().UTC()
+
+		if err := tx.Webhooks().Update(ctx, &subscription); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, "webhook.update", "webhook", id)
+	})
+	if err != nil {
+		return WebhookDTO{}, err
+	}
+
+	dto := webhookToDTO(subscription)
+	dto.Secret = secret
+	return dto, nil
+}
+
+func (s *WebhookService) DeleteWebhook(ctx context.Context, id uint) error {
+	return s.store.WithinTransaction(ctx, func(tx repository.Repositories) error {
+		if _, err := loadWebhook(ctx, tx, id); err != nil {
+			return err
+		}
+		if err := tx.Webhooks().Delete(ctx, id); err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, "webhook.delete", "webhook", id)
+	})
+}
+
+func (s *WebhookService) ListWebhookDeliveries(ctx context.Context, id uint, options ListDeliveriesOptions) ([]WebhookDeliveryDTO, error) {
+	switch options.Status {
+	case "", models.WebhookDeliveryPending, models.WebhookDeliveryDelivered, models.WebhookDeliveryDead:
+	default:
+		return nil, apperror.New(apperror.CodeValidation, "status must be one of: pending, delivered, dead")
+	}
+	limit := options.Limit
+	if limit <= 0 {
+		limit = defaultDeliveryLimit
+	}
+	if limit > maxDeliveryListLimit {
+		return nil, apperror.New(apperror.CodeValidation, fmt.Sprintf("limit must not exceed %d", maxDeliveryListLimit))
+	}
+
+	if _, err := loadWebhook(ctx, s.store, id); err != nil {
+		return nil, err
+	}
+	deliveries, err := s.store.Webhooks().ListDeliveries(ctx, id, repository.DeliveryFilter{
+		Status:   options.Status,
+		BeforeID: options.BeforeID,
+		Limit:    limit,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]WebhookDeliveryDTO, 0, len(deliveries))
+	for _, delivery := range deliveries {
+		result = append(result, deliveryToDTO(delivery))
+	}
+	return result, nil
+}
+
+// RedeliverWebhook queues a delivery again, typically a dead letter once the
+// receiver is fixed.
+func (s *WebhookService) RedeliverWebhook(ctx context.Context, id uint, deliveryID uint) error {
+	return s.store.WithinTransaction(ctx, func(tx repository.Repositories) error {
+		if _, err := loadWebhook(ctx, tx, id); err != nil {
+			return err
+		}
+		err := tx.Webhooks().Redeliver(ctx, id, deliveryID)
+		if errors.Is(err, repository.ErrNotFound) {
+			return errWebhookDeliveryNotFound
+		}
+		if err != nil {
+			return err
+		}
+		return recordAudit(ctx, tx, "webhook.redeliver", "webhook", id)
+	})
+}
+
+// loadWebhook returns the subscription if the caller may manage it.
+func loadWebhook(ctx context.Context, repos repository.Repositories, id uint) (models.WebhookSubscription, error) {
+	subscription, err := repos.Webhooks().Get(ctx, id)
+	if errors.Is(err, repository.ErrNotFound) {
+		return models.WebhookSubscription{}, errWebhookNotFound
+	}
+	if err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	if err := authorize(ctx, repos, auth.RoleAdmin, subscription.DepartmentID); err != nil {
+		return models.WebhookSubscription{}, err
+	}
+	return subscription, nil
+}
+
+// normalizeWebhookURL also refuses hosts in the service's own network; the
+// dispatcher checks the address again when it connects.
+func (s *WebhookService) normalizeWebhookURL(ctx context.Context, raw string) (string, error) {
+	value := strings.TrimSpace(raw)
+	parsed, err := url.Parse(value)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Hostname() == "" {
+		return "", apperror.New(apperror.CodeValidation, "url must be an absolute http or https URL")
+	}
+	if len(value) > maxWebhookURLLength {
+		return "", apperror.New(apperror.CodeValidation, fmt.Sprintf("url must not exceed %d characters", maxWebhookURLLength))
+	}
+	if s.allowPrivate {
+		return value, nil
+	}
+	err = webhook.CheckHost(ctx, s.resolver, parsed.Hostname())
+	if errors.Is(err, webhook.ErrForbiddenAddress) {
+		return "", apperror.New(apperror.CodeValidation, "url must not point to a loopback, private or link-local address")
+	}
+	if err != nil {
+		return "", apperror.New(apperror.CodeValidation, fmt.Sprintf("url host cannot be resolved: %s", parsed.Hostname()))
+	}
+	return value, nil
+}
+
+func normalizeEventTypes(raw []string) ([]string, error) {
+	eventTypes := make([]string, 0, len(raw))
+	for _, eventType := range raw {
+		eventType = strings.TrimSpace(eventType)
+		if !slices.Contains(EventTypes, eventType) {
+			return nil, apperror.New(apperror.CodeValidation, fmt.Sprintf("unknown event type %q", eventType))
+		}
+		if !slices.Contains(eventTypes, eventType) {
+			eventTypes = append(eventTypes, eventType)
+		}
+	}
+	return eventTypes, nil
+}
+
+func generateWebhookSecret() (string, error) {
+	secret := make([]byte, 32)
+	if _, err := rand.Read(secret); err != nil {
+		return "", fmt.Errorf("generate webhook secret: %w", err)
+	}
+	return webhookSecretPrefix + base64.RawURLEncoding.EncodeToString(secret), nil
+}
+
+func webhookToDTO(subscription models.WebhookSubscription) WebhookDTO {
+	eventTypes := []string{}
+	if subscription.EventTypes != "" {
+		eventTypes = strings.Split(subscription.EventTypes, ",")
+	}
+	return WebhookDTO{
+		ID:           subscription.ID,
+		URL:          subscription.URL,
+		EventTypes:   eventTypes,
+		DepartmentID: subscription.DepartmentID,
+		Active:       subscription.Active,
+		CreatedBy:    subscription.CreatedBy,
+		CreatedAt:    subscription.CreatedAt.UTC(),
+		UpdatedAt:    subscription.UpdatedAt.UTC(),
+	}
+}
+
+func deliveryToDTO(delivery models.WebhookDelivery) WebhookDeliveryDTO {
+	return WebhookDeliveryDTO{
+		ID:             delivery.ID,
+		EventID:        delivery.EventID,
+		EventType:      delivery.EventType,
+		EntityType:     delivery.EntityType,
+		EntityID:       delivery.EntityID,
+		Status:         delivery.Status,
+		Attempts:       delivery.Attempts,
+		NextAttemptAt:  delivery.NextAttemptAt,
+		LastStatusCode: delivery.LastStatusCode,
+		LastError:      delivery.LastError,
+		CreatedAt:      delivery.CreatedAt.UTC(),
+		DeliveredAt:    delivery.DeliveredAt,
+	}
+}
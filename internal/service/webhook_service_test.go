@@ -0,0 +1,136 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"net"
+	"strings"
+	"testing"
+
+	"hitalent-go-task/internal/apperror"
+	"hitalent-go-task/internal/auth"
+	"hitalent-go-task/internal/repository/memory"
+)
+
+// staticResolver resolves the hosts of the tests without DNS.
+type staticResolver map[string]string
+
+func (r staticResolver) LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error) {
+	ip, ok := r[host]
+	if !ok {
+		return nil, errors.New("no such host")
+	}
+	return []net.IPAddr{{IP: net.ParseIP(ip)}}, nil
+}
+
+func TestWebhookManagement(t *testing.T) {
+	store := memory.NewStore()
+	departments := NewDepartmentService(store)
+	webhooks := NewWebhookService(store, WithWebhookResolver(staticResolver{"example.test": "203.0.113.10"}))
+	ctx := context.Background()
+
+	root := mustCreateDepartment(t, departments, "Company", nil)
+	engineering := mustCreateDepartment(t, departments, "Engineering", &root.ID)
+	sales := mustCreateDepartment(t, departments, "Sales", &root.ID)
+
+	as := func(role auth.Role, scope *uint) context.Context {
+		return auth.WithPrincipal(ctx, auth.Principal{Subject: "user", Method: auth.MethodJWT, Role: role, ScopeDepartmentID: scope})
+	}
+	admin := as(auth.RoleAdmin, nil)
+	scopedAdmin := as(auth.RoleAdmin, &engineering.ID)
+
+	_, err := webhooks.CreateWebhook(admin, CreateWebhookInput{URL: "ftp://example.test"})
+	assertCode(t, err, apperror.CodeValidation)
+	_, err = webhooks.CreateWebhook(admin, CreateWebhookInput{URL: "https://unknown.test"})
+	assertCode(t, err, apperror.CodeValidation)
+	_, err = webhooks.CreateWebhook(admin, CreateWebhookInput{URL: "https://example.test", EventTypes: []string{"department.exploded"}})
+	assertCode(t, err, apperror.CodeValidation)
+	_, err = webhooks.CreateWebhook(as(auth.RoleEditor, nil), CreateWebhookInput{URL: "https://example.test"})
+	assertCode(t, err, apperror.CodeForbidden)
+	_, err = webhooks.CreateWebhook(scopedAdmin, CreateWebhookInput{URL: "https://example.test"})
+	assertCode(t, err, apperror.CodeForbidden)
+
+	global, err := webhooks.CreateWebhook(admin, CreateWebhookInput{
+		URL:        "https://example.test/all",
+		EventTypes: []string{EventDepartmentDeleted, EventDepartmentDeleted},
+	})
+	if err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	if !strings.HasPrefix(global.Secret, webhookSecretPrefix) || len(global.EventTypes) != 1 {
+		t.Fatalf("expected a secret and deduplicated event types, got %+v", global)
+	}
+	scoped, err := webhooks.CreateWebhook(scopedAdmin, CreateWebhookInput{URL: "https://example.test/eng", DepartmentID: &engineering.ID})
+	if err != nil {
+		t.Fatalf("create scoped webhook: %v", err)
+	}
+
+	fetched, err := webhooks.GetWebhook(admin, global.ID)
+	if err != nil || fetched.Secret != "" {
+		t.Fatalf("the secret must not be returned again, got %+v, %v", fetched, err)
+	}
+	_, err = webhooks.GetWebhook(scopedAdmin, global.ID)
+	assertCode(t, err, apperror.CodeForbidden)
+
+	visible, err := webhooks.ListWebhooks(scopedAdmin)
+	if err != nil || len(visible) != 1 || visible[0].ID != scoped.ID {
+		t.Fatalf("expected only the scoped webhook, got %+v, %v", visible, err)
+	}
+	all, err := webhooks.ListWebhooks(admin)
+	if err != nil || len(all) != 2 {
+		t.Fatalf("expected both webhooks, got %+v, %v", all, err)
+	}
+
+	_, err = webhooks.UpdateWebhook(scopedAdmin, scoped.ID, UpdateWebhookInput{DepartmentIDSet: true, DepartmentID: &sales.ID})
+	assertCode(t, err, apperror.CodeForbidden)
+	inactive := false
+	updated, err := webhooks.UpdateWebhook(scopedAdmin, scoped.ID, UpdateWebhookInput{Active: &inactive, RotateSecret: true})
+	if err != nil || updated.Active || updated.Secret == "" || updated.Secret == scoped.Secret {
+		t.Fatalf("expected a paused webhook with a new secret, got %+v, %v", updated, err)
+	}
+
+	_, err = webhooks.ListWebhookDeliveries(admin, global.ID, ListDeliveriesOptions{Status: "lost"})
+	assertCode(t, err, apperror.CodeValidation)
+	assertCode(t, webhooks.RedeliverWebhook(admin, global.ID, 1), apperror.CodeNotFound)
+
+	if err := webhooks.DeleteWebhook(admin, global.ID); err != nil {
+		t.Fatalf("delete webhook: %v", err)
+	}
+	_, err = webhooks.GetWebhook(admin, global.ID)
+	assertCode(t, err, apperror.CodeNotFound)
+}
+
+func TestWebhookURLMustNotPointInside(t *testing.T) {
+	store := memory.NewStore()
+	ctx := auth.WithPrincipal(context.Background(), auth.Principal{Subject: "user", Method: auth.MethodJWT, Role: auth.RoleAdmin})
+	resolver := staticResolver{"metadata.test": "169.254.169.254", "intranet.test": "10.0.0.5", "cgnat.test": "100.100.100.200", "public.test": "203.0.113.10"}
+	webhooks := NewWebhookService(store, WithWebhookResolver(resolver))
+
+	for _, rawURL := range []string{
+		"http://127.0.0.1:8080/hook",
+		"http://[::1]/hook",
+		"http://[::ffff:192.168.1.1]/hook",
+		"http://0.0.0.0/hook",
+		"http://0.1.2.3/hook",
+		"http://100.64.0.1/hook",
+		"http://[::ffff:100.127.255.254]/hook",
+		"https://cgnat.test/hook",
+		"http://metadata.test/latest/meta-data",
+		"https://intranet.test/hook",
+	} {
+		_, err := webhooks.CreateWebhook(ctx, CreateWebhookInput{URL: rawURL})
+		assertCode(t, err, apperror.CodeValidation)
+	}
+	created, err := webhooks.CreateWebhook(ctx, CreateWebhookInput{URL: "https://public.test/hook"})
+	if err != nil {
+		t.Fatalf("create webhook: %v", err)
+	}
+	internal := "http://metadata.test/"
+	_, err = webhooks.UpdateWebhook(ctx, created.ID, UpdateWebhookInput{URL: &internal})
+	assertCode(t, err, apperror.CodeValidation)
+
+	private := NewWebhookService(store, WithWebhookResolver(resolver), WithPrivateWebhookTargets(true))
+	if _, err := private.CreateWebhook(ctx, CreateWebhookInput{URL: "https://intranet.test/hook"}); err != nil {
+		t.Fatalf("expected private targets to be allowed, got %v", err)
+	}
+}
@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	gormsqlite "github.com/glebarez/sqlite"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func TestMiddlewareContinuesIncomingTrace(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder)))
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	database, err := gorm.Open(gormsqlite.Open(":memory:"), &gorm.Config{Logger: logger.Discard})
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	if err := database.Use(GormPlugin{System: "sqlite"}); err != nil {
+		t.Fatalf("register plugin: %v", err)
+	}
+	// Outside a request no span is started.
+	if err := database.Exec("SELECT 1").Error; err != nil {
+		t.Fatalf("exec: %v", err)
+	}
+
+	handler := Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetRoute(r.Context(), r.Method, "/departments/{id}")
+		var value int
+		if err := database.WithContext(r.Context()).Raw("SELECT 1").Scan(&value).Error; err != nil {
+			t.Errorf("query: %v", err)
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+
+	request := httptest.NewRequest(http.MethodGet, "/departments/7", nil)
+	request.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	handler.ServeHTTP(httptest.NewRecorder(), request)
+
+	spans := recorder.Ended()
+	if len(spans) != 2 {
+		t.Fatalf("expected query and server spans, got %d", len(spans))
+	}
+	query, server := spans[0], spans[1]
+	if server.Name() != "GET /departments/{id}" {
+		t.Fatalf("unexpected server span name %q", server.Name())
+	}
+	if got := server.SpanContext().TraceID().String(); got != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Fatalf("incoming trace was not continued, got trace %s", got)
+	}
+	if server.Parent().SpanID().String() != "00f067aa0ba902b7" {
+		t.Fatalf("unexpected server span parent %s", server.Parent().SpanID())
+	}
+	if query.Name() != "gorm.row" || query.Parent().SpanID() != server.SpanContext().SpanID() {
+		t.Fatalf("query span %q is not a child of the server span", query.Name())
+	}
+}
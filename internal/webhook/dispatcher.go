@@ -0,0 +1,363 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/outbox"
+)
+
+const (
+	defaultBatchSize   = 100
+	defaultInterval    = time.Second
+	defaultMaxAttempts = 10
+	defaultMaxBackoff  = time.Hour
+	initialBackoff     = time.Second
+	// defaultClaimTimeout is how long a delivery stays claimed by an instance
+	// that stopped before recording the result.
+	defaultClaimTimeout = 5 * time.Minute
+	// maxDrainedBody limits how much of a response is read to reuse the
+	// connection.
+	maxDrainedBody = 64 << 10
+)
+
+// Dispatcher POSTs queued deliveries to the subscribers. Deliveries of one
+// entity to one subscription are sent in order: after a failure the later
+// ones wait for the retry. A delivery that failed maxAttempts times becomes
+// a dead letter and no longer holds the others back. Deliveries of an
+// inactive subscription stay queued until it is activated again.
+//
+// Several instances may dispatch at once. A delivery is claimed before it is
+// sent, and a claimed delivery holds back the entity's later ones everywhere,
+// so the order holds without keeping a transaction open during the requests.
+type Dispatcher struct {
+	db           *gorm.DB
+	client       *http.Client
+	logger       *slog.Logger
+	batchSize    int
+	interval     time.Duration
+	maxAttempts  int
+	maxBackoff   time.Duration
+	claimTimeout time.Duration
+	now          func() time.Time
+}
+
+type Option func(*Dispatcher)
+
+// WithBatchSize limits the deliveries attempted per pass.
+func WithBatchSize(size int) Option {
+	return func(d *Dispatcher) {
+		d.batchSize = size
+	}
+}
+
+// WithInterval sets the pause between passes when nothing is due.
+func WithInterval(interval time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.interval = interval
+	}
+}
+
+// WithMaxAttempts sets after how many failures a delivery is dead-lettered.
+func WithMaxAttempts(attempts int) Option {
+	return func(d *Dispatcher) {
+		d.maxAttempts = attempts
+	}
+}
+
+// WithMaxBackoff caps the exponential delay between retries of a delivery.
+func WithMaxBackoff(backoff time.Duration) Option {
+	return func(d *Dispatcher) {
+		d.maxBackoff = backoff
+	}
+}
+
+func NewDispatcher(db *gorm.DB, client *http.Client, logger *slog.Logger, opts ...Option) *Dispatcher {
+	d := &Dispatcher{
+		db:          db,
+		client:      client,
+		logger:      logger,
+		batchSize:   defaultBatchSize,
+		interval:    defaultInterval,
+		maxAttempts: defaultMaxAttempts,
+		maxBackoff:  defaultMaxBackoff,
+		// A claim must outlive the request it covers.
+		claimTimeout: max(defaultClaimTimeout, 2*client.Timeout),
+		now:          time.Now,
+	}
+	for _, opt := range opts {
+		opt(d)
+	}
+	return d
+}
+
+// Run delivers queued deliveries until ctx is cancelled.
+func (d *Dispatcher) Run(ctx context.Context) {
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-timer.C:
+		}
+
+		_, more, err := d.flush(ctx)
+		if err != nil && ctx.Err() == nil {
+			d.logger.Error("webhook dispatch failed", "error", err)
+		}
+		if more && err == nil {
+			timer.Reset(0)
+		} else {
+			timer.Reset(d.interval)
+		}
+	}
+}
+
+// Flush makes one pass over the due deliveries and returns how many were
+// delivered.
+func (d *Dispatcher) Flush(ctx context.Context) (int, error) {
+	delivered, _, err := d.flush(ctx)
+	return delivered, err
+}
+
+// flush attempts up to batchSize deliveries. Every result is committed on its
+// own, so a later error does not cause the earlier ones to be sent again.
+func (d *Dispatcher) flush(ctx context.Context) (delivered int, more bool, err error) {
+	var subscriptions []models.WebhookSubscription
+	if err := d.db.WithContext(ctx).Find(&subscriptions).Error; err != nil {
+		return 0, false, fmt.Errorf("load webhook subscriptions: %w", err)
+	}
+	byID := make(map[uint]models.WebhookSubscription, len(subscriptions))
+	for _, subscription := range subscriptions {
+		byID[subscription.ID] = subscription
+	}
+
+	now := d.now().UTC()
+	blocked := make(map[string]bool)
+	attempted := 0
+	var cursor uint
+
+	for attempted < d.batchSize {
+		var rows []models.WebhookDelivery
+		if err := d.db.WithContext(ctx).
+			Where("status = ? AND id > ?", models.WebhookDeliveryPending, cursor).
+			Order("id").
+			Limit(d.batchSize).
+			Find(&rows).Error; err != nil {
+			return delivered, false, fmt.Errorf("load webhook deliveries: %w", err)
+		}
+		if len(rows) == 0 {
+			return delivered, false, nil
+		}
+
+		for _, row := range rows {
+			cursor = row.ID
+			key := strconv.FormatUint(uint64(row.SubscriptionID), 10) + "/" + row.EntityType + ":" + strconv.FormatUint(uint64(row.EntityID), 10)
+			if blocked[key] {
+				continue
+			}
+			subscription, ok := byID[row.SubscriptionID]
+			if !ok || !subscription.Active || (row.NextAttemptAt != nil && row.NextAttemptAt.After(now)) {
+				blocked[key] = true
+				continue
+			}
+
+			claimed, err := d.claim(ctx, row, now)
+			if err != nil {
+				return delivered, false, err
+			}
+			if !claimed {
+				// Another instance is sending it, or has just retried it.
+				blocked[key] = true
+				continue
+			}
+
+			attempted++
+			statusCode, sendErr := d.send(ctx, subscription, row)
+			if sendErr != nil && ctx.Err() != nil {
+				d.release(context.WithoutCancel(ctx), row)
+				return delivered, false, ctx.Err()
+			}
+			// The result is recorded even if the pass is being cancelled.
+			recordCtx := context.WithoutCancel(ctx)
+			if sendErr != nil {
+				dead, err := d.recordFailure(recordCtx, row, statusCode, sendErr)
+				if err != nil {
+					return delivered, false, err
+				}
+				if !dead {
+					blocked[key] = true
+				}
+			} else {
+				if err := d.recordSuccess(recordCtx, row, statusCode); err != nil {
+					return delivered, false, err
+				}
+				delivered++
+			}
+			if attempted == d.batchSize {
+				return delivered, true, nil
+			}
+		}
+	}
+	return delivered, true, nil
+}
+
+// claim takes the delivery unless it is claimed elsewhere or no longer due.
+func (d *Dispatcher) claim(ctx context.Context, row models.WebhookDelivery, now time.Time) (bool, error) {
+	result := d.db.WithContext(ctx).
+		Model(&models.WebhookDelivery{}).
+		Where("id = ? AND status = ?", row.ID, models.WebhookDeliveryPending).
+		Where("claimed_until IS NULL OR claimed_until <= ?", now).
+		Where("next_attempt_at IS NULL OR next_attempt_at <= ?", now).
+		Update("claimed_until", now.Add(d.claimTimeout))
+	if result.Error != nil {
+		return false, fmt.Errorf("claim webhook delivery: %w", result.Error)
+	}
+	return result.RowsAffected == 1, nil
+}
+
+// release gives up a claim without an attempt, e.g. on shutdown.
+func (d *Dispatcher) release(ctx context.Context, row models.WebhookDelivery) {
+	if err := d.db.WithContext(ctx).
+		Model(&models.WebhookDelivery{}).
+		Where("id = ?", row.ID).
+		Update("claimed_until", nil).Error; err != nil {
+		d.logger.Warn("release webhook delivery", "delivery_id", row.ID, "error", err)
+	}
+}
+
+// send POSTs the event envelope, the same one the outbox sinks publish, and
+// returns the response status.
+func (d *Dispatcher) send(ctx context.Context, subscription models.WebhookSubscription, row models.WebhookDelivery) (int, error) {
+	body, err := json.Marshal(outbox.Event{
+		ID:         row.EventID,
+		Type:       row.EventType,
+		EntityType: row.EntityType,
+		EntityID:   row.EntityID,
+		Actor:      row.Actor,
+		OccurredAt: row.OccurredAt.UTC(),
+		Data:       json.RawMessage(row.Payload),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, subscription.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := d.now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Event-ID", strconv.FormatUint(uint64(row.EventID), 10))
+	req.Header.Set("X-Event-Type", row.EventType)
+	req.Header.Set(DeliveryHeader, strconv.FormatUint(uint64(row.ID), 10))
+	req.Header.Set(TimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(SignatureHeader, Sign(subscription.Secret, timestamp, body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	// The body is not kept: the delivery log is readable through the API,
+	// and the target's response is not ours to show.
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, maxDrainedBody))
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return resp.StatusCode, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return resp.StatusCode, nil
+}
+
+func (d *Dispatcher) recordSuccess(ctx context.Context, row models.WebhookDelivery, statusCode int) error {
+	if err := d.db.WithContext(ctx).
+		Model(&models.WebhookDelivery{}).
+		Where("id = ?", row.ID).
+		Updates(map[string]any{
+			"status":           models.WebhookDeliveryDelivered,
+			"attempts":         row.Attempts + 1,
+			"next_attempt_at":  nil,
+			"claimed_until":    nil,
+			"last_status_code": statusCode,
+			"last_error":       "",
+			"delivered_at":     d.now().UTC(),
+		}).Error; err != nil {
+		return fmt.Errorf("mark webhook delivered: %w", err)
+	}
+	return nil
+}
+
+// recordFailure schedules a retry or, once the attempts are used up, moves
+// the delivery to the dead letters.
+func (d *Dispatcher) recordFailure(ctx context.Context, row models.WebhookDelivery, statusCode int, cause error) (dead bool, err error) {
+	attempts := row.Attempts + 1
+	updates := map[string]any{
+		"attempts":         attempts,
+		"last_status_code": statusCode,
+		"last_error":       cause.Error(),
+		"claimed_until":    nil,
+	}
+	if attempts >= d.maxAttempts {
+		dead = true
+		updates["status"] = models.WebhookDeliveryDead
+		updates["next_attempt_at"] = nil
+		d.logger.Warn("webhook delivery dead-lettered",
+			"delivery_id", row.ID,
+			"subscription_id", row.SubscriptionID,
+			"event_id", row.EventID,
+			"attempts", attempts,
+			"error", cause,
+		)
+	} else {
+		retryAt := d.now().UTC().Add(d.backoff(attempts))
+		updates["next_attempt_at"] = retryAt
+		d.logger.Warn("webhook delivery failed",
+			"delivery_id", row.ID,
+			"subscription_id", row.SubscriptionID,
+			"event_id", row.EventID,
+			"attempts", attempts,
+			"retry_at", retryAt,
+			"error", cause,
+		)
+	}
+
+	if err := d.db.WithContext(ctx).
+		Model(&models.WebhookDelivery{}).
+		Where("id = ?", row.ID).
+		Updates(updates).Error; err != nil {
+		return false, fmt.Errorf("record webhook failure: %w", err)
+	}
+	return dead, nil
+}
+
+// backoff doubles the delay with every failed attempt up to maxBackoff.
+func (d *Dispatcher) backoff(attempts int) time.Duration {
+	delay := initialBackoff
+	for i := 1; i < attempts && delay < d.maxBackoff; i++ {
+		delay *= 2
+	}
+	return min(delay, d.maxBackoff)
+}
+
+// Purge deletes delivered and dead deliveries created before the cutoff.
+func Purge(ctx context.Context, db *gorm.DB, before time.Time) (int64, error) {
+	result := db.WithContext(ctx).
+		Where("created_at < ? AND status <> ?", before.UTC(), models.WebhookDeliveryPending).
+		Delete(&models.WebhookDelivery{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("purge webhook deliveries: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
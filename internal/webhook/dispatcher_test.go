@@ -0,0 +1,294 @@
+package webhook
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"gorm.io/gorm"
+
+	"hitalent-go-task/internal/config"
+	"hitalent-go-task/internal/db"
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/outbox"
+)
+
+func openTestDatabase(t *testing.T) *gorm.DB {
+	t.Helper()
+	database, err := db.Connect(config.Config{
+		DatabaseDriver: config.DriverSQLite,
+		DatabaseURL:    "sqlite://" + filepath.Join(t.TempDir(), "webhook.db"),
+	}, slog.New(slog.DiscardHandler))
+	if err != nil {
+		t.Fatalf("open database: %v", err)
+	}
+	migrator, err := db.NewMigrator(database, config.DriverSQLite)
+	if err != nil {
+		t.Fatalf("create migrator: %v", err)
+	}
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("apply migrations: %v", err)
+	}
+	return database
+}
+
+func createSubscription(t *testing.T, database *gorm.DB, subscription models.WebhookSubscription) models.WebhookSubscription {
+	t.Helper()
+	subscription.Secret = "whsec_test"
+	subscription.Active = true
+	subscription.CreatedBy = "system"
+	if err := database.Create(&subscription).Error; err != nil {
+		t.Fatalf("create subscription: %v", err)
+	}
+	return subscription
+}
+
+func TestFanoutMatchesTypesAndSubtree(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	subtree := uint(4)
+	all := createSubscription(t, database, models.WebhookSubscription{URL: "http://example.test/all"})
+	scoped := createSubscription(t, database, models.WebhookSubscription{URL: "http://example.test/scoped", DepartmentID: &subtree})
+	typed := createSubscription(t, database, models.WebhookSubscription{URL: "http://example.test/typed", EventTypes: "department.deleted,employee.deleted"})
+	paused := createSubscription(t, database, models.WebhookSubscription{URL: "http://example.test/paused"})
+	if err := database.Model(&paused).Update("active", false).Error; err != nil {
+		t.Fatalf("pause subscription: %v", err)
+	}
+
+	fanout := NewFanout(database)
+	events := []outbox.Event{
+		{ID: 1, Type: "department.created", EntityType: "department", EntityID: 7, Data: json.RawMessage(`{}`), Scope: []uint{7, 4, 1}},
+		{ID: 2, Type: "department.deleted", EntityType: "department", EntityID: 9, Data: json.RawMessage(`{}`), Scope: []uint{9, 1}},
+	}
+	for _, event := range events {
+		if err := fanout.Publish(ctx, event); err != nil {
+			t.Fatalf("publish event %d: %v", event.ID, err)
+		}
+	}
+	// The relay publishes again after a crash; nothing may be queued twice.
+	if err := fanout.Publish(ctx, events[0]); err != nil {
+		t.Fatalf("publish duplicate: %v", err)
+	}
+
+	var deliveries []models.WebhookDelivery
+	if err := database.Order("subscription_id, event_id").Find(&deliveries).Error; err != nil {
+		t.Fatalf("load deliveries: %v", err)
+	}
+	got := make(map[uint][]uint)
+	for _, delivery := range deliveries {
+		got[delivery.SubscriptionID] = append(got[delivery.SubscriptionID], delivery.EventID)
+	}
+	want := map[uint][]uint{all.ID: {1, 2}, scoped.ID: {1}, typed.ID: {2}}
+	if len(got) != len(want) {
+		t.Fatalf("expected deliveries %v, got %v", want, got)
+	}
+	for id, events := range want {
+		if len(got[id]) != len(events) || got[id][0] != events[0] || got[id][len(events)-1] != events[len(events)-1] {
+			t.Fatalf("expected deliveries %v, got %v", want, got)
+		}
+	}
+}
+
+type receiver struct {
+	mu       sync.Mutex
+	failures int
+	received []outbox.Event
+	invalid  int
+}
+
+func (r *receiver) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	body, _ := io.ReadAll(req.Body)
+	timestamp, _ := strconv.ParseInt(req.Header.Get(TimestampHeader), 10, 64)
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !Verify("whsec_test", timestamp, body, req.Header.Get(SignatureHeader)) {
+		r.invalid++
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if r.failures > 0 {
+		r.failures--
+		http.Error(w, "try later", http.StatusServiceUnavailable)
+		return
+	}
+	var event outbox.Event
+	_ = json.Unmarshal(body, &event)
+	r.received = append(r.received, event)
+}
+
+func queueDelivery(t *testing.T, database *gorm.DB, subscriptionID uint, eventID uint, entityID uint) {
+	t.Helper()
+	delivery := models.WebhookDelivery{
+		SubscriptionID: subscriptionID,
+		EventID:        eventID,
+		EventType:      "department.renamed",
+		EntityType:     "department",
+		EntityID:       entityID,
+		Actor:          "system",
+		OccurredAt:     time.Now(),
+		Payload:        `{"department":{"id":` + strconv.FormatUint(uint64(entityID), 10) + `}}`,
+		Status:         models.WebhookDeliveryPending,
+	}
+	if err := database.Create(&delivery).Error; err != nil {
+		t.Fatalf("queue delivery: %v", err)
+	}
+}
+
+func TestDispatcherSignsAndRetriesInOrder(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	target := &receiver{failures: 1}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	subscription := createSubscription(t, database, models.WebhookSubscription{URL: server.URL})
+	queueDelivery(t, database, subscription.ID, 1, 1)
+	queueDelivery(t, database, subscription.ID, 2, 1)
+	queueDelivery(t, database, subscription.ID, 3, 2)
+
+	dispatcher := NewDispatcher(database, server.Client(), slog.New(slog.DiscardHandler))
+	now := time.Now()
+	dispatcher.now = func() time.Time { return now }
+
+	if delivered, err := dispatcher.Flush(ctx); err != nil || delivered != 1 {
+		t.Fatalf("expected only the other entity's delivery, got %d, %v", delivered, err)
+	}
+	var failed models.WebhookDelivery
+	if err := database.First(&failed, 1).Error; err != nil || failed.Attempts != 1 || failed.LastStatusCode != http.StatusServiceUnavailable || failed.NextAttemptAt == nil {
+		t.Fatalf("expected the failure to be logged, got %+v, %v", failed, err)
+	}
+	if delivered, _ := dispatcher.Flush(ctx); delivered != 0 {
+		t.Fatalf("the retry must wait for its backoff, delivered %d", delivered)
+	}
+
+	now = now.Add(initialBackoff)
+	if delivered, err := dispatcher.Flush(ctx); err != nil || delivered != 2 {
+		t.Fatalf("expected the retry and the held back delivery, got %d, %v", delivered, err)
+	}
+	if target.invalid != 0 {
+		t.Fatalf("expected valid signatures, %d were rejected", target.invalid)
+	}
+	var order []uint
+	for _, event := range target.received {
+		order = append(order, event.ID)
+	}
+	if len(order) != 3 || order[0] != 3 || order[1] != 1 || order[2] != 2 {
+		t.Fatalf("expected events 3, 1, 2, got %v", order)
+	}
+}
+
+func TestDispatcherDeadLettersAfterMaxAttempts(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	target := &receiver{failures: 100}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	subscription := createSubscription(t, database, models.WebhookSubscription{URL: server.URL})
+	queueDelivery(t, database, subscription.ID, 1, 1)
+	queueDelivery(t, database, subscription.ID, 2, 1)
+
+	dispatcher := NewDispatcher(database, server.Client(), slog.New(slog.DiscardHandler), WithMaxAttempts(3), WithMaxBackoff(time.Second))
+	now := time.Now()
+	dispatcher.now = func() time.Time { return now }
+
+	for range 3 {
+		if _, err := dispatcher.Flush(ctx); err != nil {
+			t.Fatalf("flush: %v", err)
+		}
+		now = now.Add(time.Second)
+	}
+
+	var dead models.WebhookDelivery
+	if err := database.First(&dead, 1).Error; err != nil || dead.Status != models.WebhookDeliveryDead || dead.Attempts != 3 {
+		t.Fatalf("expected a dead letter after 3 attempts, got %+v, %v", dead, err)
+	}
+	// A dead letter no longer holds back the entity's later deliveries.
+	var next models.WebhookDelivery
+	if err := database.First(&next, 2).Error; err != nil || next.Attempts == 0 {
+		t.Fatalf("expected the next delivery to be attempted, got %+v, %v", next, err)
+	}
+}
+
+func TestDispatcherRefusesPrivateAddresses(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	target := &receiver{}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	// The subscription was accepted for a public host that now resolves to
+	// loopback; the dialer still refuses it.
+	subscription := createSubscription(t, database, models.WebhookSubscription{URL: server.URL})
+	queueDelivery(t, database, subscription.ID, 1, 1)
+
+	dispatcher := NewDispatcher(database, NewClient(time.Second, false), slog.New(slog.DiscardHandler))
+	if delivered, err := dispatcher.Flush(ctx); err != nil || delivered != 0 {
+		t.Fatalf("expected no delivery, got %d, %v", delivered, err)
+	}
+	var failed models.WebhookDelivery
+	if err := database.First(&failed, 1).Error; err != nil || !strings.Contains(failed.LastError, ErrForbiddenAddress.Error()) {
+		t.Fatalf("expected the address to be refused, got %+v, %v", failed, err)
+	}
+	if len(target.received) != 0 {
+		t.Fatalf("expected nothing to reach the target, got %d events", len(target.received))
+	}
+
+	allowed := NewDispatcher(database, NewClient(time.Second, true), slog.New(slog.DiscardHandler))
+	allowed.now = func() time.Time { return time.Now().Add(time.Hour) }
+	if delivered, err := allowed.Flush(ctx); err != nil || delivered != 1 {
+		t.Fatalf("expected the delivery with private networks allowed, got %d, %v", delivered, err)
+	}
+}
+
+func TestDispatcherSkipsDeliveriesClaimedElsewhere(t *testing.T) {
+	database := openTestDatabase(t)
+	ctx := context.Background()
+	target := &receiver{}
+	server := httptest.NewServer(target)
+	defer server.Close()
+
+	subscription := createSubscription(t, database, models.WebhookSubscription{URL: server.URL})
+	queueDelivery(t, database, subscription.ID, 1, 1)
+	queueDelivery(t, database, subscription.ID, 2, 1)
+	queueDelivery(t, database, subscription.ID, 3, 2)
+
+	// Another instance is sending the first delivery of entity 1.
+	now := time.Now().UTC()
+	if err := database.Model(&models.WebhookDelivery{}).Where("id = ?", 1).Update("claimed_until", now.Add(time.Minute)).Error; err != nil {
+		t.Fatalf("claim delivery: %v", err)
+	}
+
+	dispatcher := NewDispatcher(database, server.Client(), slog.New(slog.DiscardHandler))
+	dispatcher.now = func() time.Time { return now }
+	if delivered, err := dispatcher.Flush(ctx); err != nil || delivered != 1 {
+		t.Fatalf("expected only the other entity's delivery, got %d, %v", delivered, err)
+	}
+
+	// The instance stopped; its claim expires and the entity goes on in order.
+	now = now.Add(2 * time.Minute)
+	if delivered, err := dispatcher.Flush(ctx); err != nil || delivered != 2 {
+		t.Fatalf("expected the expired claim to be taken over, got %d, %v", delivered, err)
+	}
+	var order []uint
+	for _, event := range target.received {
+		order = append(order, event.ID)
+	}
+	if len(order) != 3 || order[0] != 3 || order[1] != 1 || order[2] != 2 {
+		t.Fatalf("expected events 3, 1, 2, got %v", order)
+	}
+	var claimed int64
+	if err := database.Model(&models.WebhookDelivery{}).Where("claimed_until IS NOT NULL").Count(&claimed).Error; err != nil || claimed != 0 {
+		t.Fatalf("expected no claims left, got %d, %v", claimed, err)
+	}
+}
@@ -0,0 +1,64 @@
+package webhook
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"hitalent-go-task/internal/models"
+	"hitalent-go-task/internal/outbox"
+)
+
+// Fanout is an outbox sink that queues every event for the active
+// subscriptions it matches. Deliveries are unique per subscription and event,
+// so an event published again by the relay is not queued twice.
+type Fanout struct {
+	db *gorm.DB
+}
+
+func NewFanout(db *gorm.DB) *Fanout {
+	return &Fanout{db: db}
+}
+
+func (f *Fanout) Publish(ctx context.Context, event outbox.Event) error {
+	var subscriptions []models.WebhookSubscription
+	if err := f.db.WithContext(ctx).Where("active = ?", true).Find(&subscriptions).Error; err != nil {
+		return fmt.Errorf("load webhook subscriptions: %w", err)
+	}
+
+	var deliveries []models.WebhookDelivery
+	for _, subscription := range subscriptions {
+		if !matches(subscription, event) {
+			continue
+		}
+		deliveries = append(deliveries, models.WebhookDelivery{
+			SubscriptionID: subscription.ID,
+			EventID:        event.ID,
+			EventType:      event.Type,
+			EntityType:     event.EntityType,
+			EntityID:       event.EntityID,
+			Actor:          event.Actor,
+			OccurredAt:     event.OccurredAt,
+			Payload:        string(event.Data),
+			Status:         models.WebhookDeliveryPending,
+		})
+	}
+	if len(deliveries) == 0 {
+		return nil
+	}
+	if err := f.db.WithContext(ctx).Clauses(clause.OnConflict{DoNothing: true}).Create(&deliveries).Error; err != nil {
+		return fmt.Errorf("queue webhook deliveries: %w", err)
+	}
+	return nil
+}
+
+func matches(subscription models.WebhookSubscription, event outbox.Event) bool {
+	if subscription.EventTypes != "" && !slices.Contains(strings.Split(subscription.EventTypes, ","), event.Type) {
+		return false
+	}
+	return subscription.DepartmentID == nil || event.InScope(*subscription.DepartmentID)
+}
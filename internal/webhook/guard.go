@@ -0,0 +1,96 @@
+package webhook
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"syscall"
+	"time"
+)
+
+// ErrForbiddenAddress is returned for targets inside the service's own
+// network: subscriptions are managed by API users, who must not be able to
+// reach internal services or cloud metadata endpoints through the dispatcher.
+var ErrForbiddenAddress = errors.New("webhook target address is not allowed")
+
+// Resolver looks up the addresses of a host; *net.Resolver implements it.
+type Resolver interface {
+	LookupIPAddr(ctx context.Context, host string) ([]net.IPAddr, error)
+}
+
+// internalPrefixes are not covered by the netip predicates: "this network"
+// and carrier-grade NAT, which many clouds use for internal services.
+var internalPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+}
+
+// CheckAddress rejects loopback, private, carrier-grade NAT, link-local,
+// multicast and unspecified addresses.
+func CheckAddress(addr netip.Addr) error {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsLoopback() || addr.IsPrivate() || addr.IsUnspecified() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() ||
+		inInternalPrefix(addr) {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, addr)
+	}
+	return nil
+}
+
+func inInternalPrefix(addr netip.Addr) bool {
+	for _, prefix := range internalPrefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// CheckHost resolves host and checks every address it resolves to. It is
+// only an early answer for the API: the addresses may change before delivery,
+// so NewClient checks them again when connecting.
+func CheckHost(ctx context.Context, resolver Resolver, host string) error {
+	if addr, err := netip.ParseAddr(host); err == nil {
+		return CheckAddress(addr)
+	}
+	addrs, err := resolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, ip := range addrs {
+		addr, ok := netip.AddrFromSlice(ip.IP)
+		if !ok {
+			return fmt.Errorf("%w: %s", ErrForbiddenAddress, ip)
+		}
+		if err := CheckAddress(addr); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// NewClient returns the client the dispatcher delivers with. Unless
+// allowPrivate is set, every connection is checked after DNS resolution, so a
+// host that later resolves to an internal address is still refused. The
+// environment proxy is not used: it would resolve the host itself.
+func NewClient(timeout time.Duration, allowPrivate bool) *http.Client {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if !allowPrivate {
+		dialer.Control = dialControl
+	}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = dialer.DialContext
+	return &http.Client{Timeout: timeout, Transport: transport}
+}
+
+func dialControl(network string, address string, _ syscall.RawConn) error {
+	addrPort, err := netip.ParseAddrPort(address)
+	if err != nil {
+		return fmt.Errorf("%w: %s", ErrForbiddenAddress, address)
+	}
+	return CheckAddress(addrPort.Addr())
+}
@@ -0,0 +1,8 @@
+// Package migrations embeds the goose SQL migrations so the server binary can
+// apply them without the source tree or the goose CLI.
+package migrations
+
+import "embed"
+
+//go:embed postgres/*.sql sqlite/*.sql
+var FS embed.FS
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE departments ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+ALTER TABLE employees ADD COLUMN version BIGINT NOT NULL DEFAULT 1;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE employees DROP COLUMN IF EXISTS version;
+ALTER TABLE departments DROP COLUMN IF EXISTS version;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE department_closures (
+    ancestor_id BIGINT NOT NULL REFERENCES departments(id) ON DELETE CASCADE,
+    descendant_id BIGINT NOT NULL REFERENCES departments(id) ON DELETE CASCADE,
+    depth INTEGER NOT NULL CHECK (depth >= 0),
+    PRIMARY KEY (ancestor_id, descendant_id)
+);
+
+CREATE INDEX idx_department_closures_descendant ON department_closures (descendant_id, depth);
+
+WITH RECURSIVE tree AS (
+    SELECT id AS ancestor_id, id AS descendant_id, 0 AS depth FROM departments
+    UNION ALL
+    SELECT t.ancestor_id, d.id, t.depth + 1
+    FROM tree t JOIN departments d ON d.parent_id = t.descendant_id
+)
+INSERT INTO department_closures (ancestor_id, descendant_id, depth)
+SELECT ancestor_id, descendant_id, depth FROM tree;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS department_closures;
+-- +goose StatementEnd
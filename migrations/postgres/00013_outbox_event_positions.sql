@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE outbox_events ADD COLUMN feed_position BIGINT NULL;
+-- Events stored so far have committed; they keep their order.
+UPDATE outbox_events SET feed_position = id;
+CREATE UNIQUE INDEX idx_outbox_events_feed_position ON outbox_events (feed_position);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX idx_outbox_events_feed_position;
+ALTER TABLE outbox_events DROP COLUMN feed_position;
+-- +goose StatementEnd
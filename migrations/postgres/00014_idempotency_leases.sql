@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE idempotency_keys ADD COLUMN response_headers TEXT NULL;
+ALTER TABLE idempotency_keys ADD COLUMN lease_expires_at TIMESTAMPTZ NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE idempotency_keys DROP COLUMN lease_expires_at;
+ALTER TABLE idempotency_keys DROP COLUMN response_headers;
+-- +goose StatementEnd
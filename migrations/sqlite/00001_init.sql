@@ -0,0 +1,29 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE departments (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    name VARCHAR(200) NOT NULL CHECK (length(trim(name)) BETWEEN 1 AND 200),
+    parent_id INTEGER REFERENCES departments(id) ON DELETE CASCADE,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX uniq_departments_parent_name
+    ON departments (COALESCE(parent_id, 0), LOWER(name));
+
+CREATE TABLE employees (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    department_id INTEGER NOT NULL REFERENCES departments(id) ON DELETE CASCADE,
+    full_name VARCHAR(200) NOT NULL CHECK (length(trim(full_name)) BETWEEN 1 AND 200),
+    position VARCHAR(200) NOT NULL CHECK (length(trim(position)) BETWEEN 1 AND 200),
+    hired_at DATE NULL,
+    created_at DATETIME NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_employees_department_id ON employees (department_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS employees;
+DROP TABLE IF EXISTS departments;
+-- +goose StatementEnd
@@ -0,0 +1,13 @@
+-- +goose Up
+-- +goose StatementBegin
+-- Keys issued before roles existed had full access; keep it that way.
+ALTER TABLE api_keys ADD COLUMN role VARCHAR(20) NOT NULL DEFAULT 'admin'
+    CHECK (role IN ('viewer', 'editor', 'admin'));
+ALTER TABLE api_keys ADD COLUMN scope_department_id INTEGER NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE api_keys DROP COLUMN scope_department_id;
+ALTER TABLE api_keys DROP COLUMN role;
+-- +goose StatementEnd